@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
 	"mjpclab.dev/ghfs/src/goVirtualHost"
 	"mjpclab.dev/ghfs/src/param"
 	"mjpclab.dev/ghfs/src/serverError"
@@ -10,6 +11,7 @@ import (
 	"mjpclab.dev/ghfs/src/setting"
 	"mjpclab.dev/ghfs/src/tpl/defaultTheme"
 	"mjpclab.dev/ghfs/src/tpl/theme"
+	"mjpclab.dev/ghfs/src/util"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -103,14 +105,31 @@ func NewApp(params param.Params, setting *setting.Setting) (*App, []error) {
 			}
 		}
 
+		clientAuth := tls.NoClientCert
+		if p.ClientCAs != nil {
+			if p.ClientAuth == "require" {
+				clientAuth = tls.RequireAndVerifyClientCert
+			} else {
+				clientAuth = tls.VerifyClientCertIfGiven
+			}
+		}
+
+		// errors are reported by vhost handler
+		proxyProtocolPeers, _ := util.ParseIPNets(p.TrustedProxies)
+
 		var warns []error
 		errs, warns = vhSvc.Add(&goVirtualHost.HostInfo{
 			Listens:      listens,
 			ListensPlain: p.ListensPlain,
 			ListensTLS:   p.ListensTLS,
 			Certs:        p.Certificates,
+			ClientCAs:    p.ClientCAs,
+			ClientAuth:   clientAuth,
 			HostNames:    p.HostNames,
 			Handler:      vhHandler,
+
+			ProxyProtocol:      p.ProxyProtocol,
+			ProxyProtocolPeers: proxyProtocolPeers,
 		})
 		if len(warns) > 0 {
 			logger.LogErrors(warns...)
@@ -2,13 +2,16 @@ package goVirtualHost
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"os"
 )
 
 var MissingCertFileAndKeyFile = errors.New("missing certificate file and key file")
 var MissingCertFile = errors.New("missing certificate file")
 var MissingKeyFile = errors.New("missing key file")
 var CertKeyFileCountNotMatch = errors.New("certificate file count and key file count not match")
+var NoCertificateInCAFile = errors.New("no certificate found in CA file")
 
 func LoadCertificate(certFile, keyFile string) (cert tls.Certificate, err error) {
 	if len(certFile) == 0 && len(keyFile) == 0 {
@@ -67,3 +70,23 @@ func LoadCertificatesFromEntries(certKeyFileEntries [][2]string) (certs []tls.Ce
 	}
 	return
 }
+
+// LoadCertPool loads PEM encoded CA certificates, e.g. for verifying client certificates.
+func LoadCertPool(caFiles []string) (pool *x509.CertPool, errs []error) {
+	if len(caFiles) == 0 {
+		return
+	}
+
+	pool = x509.NewCertPool()
+	for _, caFile := range caFiles {
+		content, err := os.ReadFile(caFile)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !pool.AppendCertsFromPEM(content) {
+			errs = append(errs, wrapError(NoCertificateInCAFile, "no certificate found in CA file: "+caFile))
+		}
+	}
+	return
+}
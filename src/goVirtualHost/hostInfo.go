@@ -15,6 +15,9 @@ func (info *HostInfo) toParam(listen string, useTLS bool) *param {
 		port:   port,
 		useTLS: useTLS,
 		certs:  certs,
+
+		proxyProtocol:      info.ProxyProtocol,
+		proxyProtocolPeers: info.ProxyProtocolPeers,
 	}
 
 	return param
@@ -25,6 +25,9 @@ func (listener *listener) open() error {
 	}
 
 	netListener, err := net.Listen(listener.proto, addr)
+	if err == nil && listener.proxyProtocol {
+		netListener = proxyProtocolListener{netListener, listener.proxyProtocolPeers}
+	}
 	listener.netListener = netListener
 
 	if listener.proto == "unix" && err == nil {
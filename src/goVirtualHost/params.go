@@ -7,6 +7,8 @@ import (
 
 var ConflictIPAddress = errors.New("conflict IP address")
 var ConflictTLSMode = errors.New("cannot serve for both Plain and TLS mode")
+var ConflictProxyProtocol = errors.New("cannot serve for both with and without PROXY protocol")
+var ConflictProxyProtocolPeers = errors.New("cannot serve PROXY protocol for different peers")
 var DuplicatedAddressHostname = errors.New("duplicated address and hostname")
 
 func (params params) validateParam(param *param) (errs []error) {
@@ -37,6 +39,13 @@ func (params params) validateParam(param *param) (errs []error) {
 				err := wrapError(ConflictTLSMode, fmt.Sprintf("cannot serve for both Plain and TLS mode: %+v, %+v", ownParam, param))
 				errs = append(errs, err)
 			}
+			if ownParam.proxyProtocol != param.proxyProtocol {
+				err := wrapError(ConflictProxyProtocol, fmt.Sprintf("cannot serve for both with and without PROXY protocol: %+v, %+v", ownParam, param))
+				errs = append(errs, err)
+			} else if param.proxyProtocol && !ipNetsEqual(ownParam.proxyProtocolPeers, param.proxyProtocolPeers) {
+				err := wrapError(ConflictProxyProtocolPeers, fmt.Sprintf("cannot serve PROXY protocol for different peers: %+v, %+v", ownParam, param))
+				errs = append(errs, err)
+			}
 		}
 	}
 
@@ -0,0 +1,241 @@
+package goVirtualHost
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var InvalidProxyHeader = errors.New("invalid PROXY protocol header")
+
+// max time for peer to send PROXY protocol header
+const proxyHeaderTimeout = 5 * time.Second
+
+const proxyV1Prefix = "PROXY "
+const proxyV1MaxLen = 107
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtocolListener accepts connections that starts with PROXY protocol v1 or v2 header,
+// which provides address of original client.
+// Header is only expected from trusted peers, since it can forge any address.
+type proxyProtocolListener struct {
+	net.Listener
+	peers []*net.IPNet
+}
+
+func (l proxyProtocolListener) isTrustedPeer(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, peer := range l.peers {
+		if peer.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.isTrustedPeer(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return newProxyProtocolConn(conn), nil
+}
+
+// proxyProtocolConn reads PROXY protocol header lazily on first Read or RemoteAddr,
+// so that slow peer does not block accepting other connections.
+// Read deadline set by user, e.g. net/http or TLS, is kept while reading the header,
+// and takes effect if it is earlier than the header timeout.
+type proxyProtocolConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+
+	deadlineMu     sync.Mutex
+	readDeadline   time.Time
+	headerDeadline time.Time
+}
+
+func newProxyProtocolConn(conn net.Conn) *proxyProtocolConn {
+	return &proxyProtocolConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+}
+
+// earlierDeadline returns the earlier one of two deadlines, zero value means no deadline.
+func earlierDeadline(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+func (c *proxyProtocolConn) setHeaderDeadline(t time.Time) {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.headerDeadline = t
+	c.Conn.SetReadDeadline(earlierDeadline(c.headerDeadline, c.readDeadline))
+}
+
+func (c *proxyProtocolConn) init() {
+	c.once.Do(func() {
+		c.setHeaderDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.remoteAddr, c.err = readProxyHeader(c.reader)
+		c.setHeaderDeadline(time.Time{})
+	})
+}
+
+func (c *proxyProtocolConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(earlierDeadline(c.headerDeadline, c.readDeadline))
+}
+
+func (c *proxyProtocolConn) SetDeadline(t time.Time) error {
+	if err := c.Conn.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	return c.SetReadDeadline(t)
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads PROXY protocol header of v1 or v2,
+// returns nil address if header does not provide one, e.g. "UNKNOWN" or "LOCAL".
+func readProxyHeader(reader *bufio.Reader) (net.Addr, error) {
+	prefix, err := reader.Peek(len(proxyV1Prefix))
+	if err != nil {
+		return nil, err
+	}
+	if string(prefix) == proxyV1Prefix {
+		return readProxyV1Header(reader)
+	}
+
+	prefix, err = reader.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(prefix, proxyV2Signature) {
+		return readProxyV2Header(reader)
+	}
+
+	return nil, InvalidProxyHeader
+}
+
+// readProxyV1Header reads human-readable header,
+// e.g. "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func readProxyV1Header(reader *bufio.Reader) (net.Addr, error) {
+	line := make([]byte, 0, proxyV1MaxLen)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyV1MaxLen {
+			return nil, InvalidProxyHeader
+		}
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, InvalidProxyHeader
+	}
+
+	fields := strings.Split(string(line[len(proxyV1Prefix):len(line)-2]), " ")
+	switch fields[0] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, InvalidProxyHeader
+	}
+	if len(fields) != 5 {
+		return nil, InvalidProxyHeader
+	}
+	ip := net.ParseIP(fields[1])
+	if ip == nil || (fields[0] == "TCP4") != (ip.To4() != nil) {
+		return nil, InvalidProxyHeader
+	}
+	port, err := strconv.ParseUint(fields[3], 10, 16)
+	if err != nil {
+		return nil, InvalidProxyHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2Header reads binary header,
+// signature(12 bytes), version and command(1 byte), family and protocol(1 byte), length(2 bytes),
+// then addresses and optional TLVs.
+func readProxyV2Header(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(proxyV2Signature)+4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	verCmd := header[12]
+	family := header[13]
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+	if verCmd>>4 != 2 {
+		return nil, InvalidProxyHeader
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+
+	switch verCmd & 0xf {
+	case 0: // LOCAL
+		return nil, nil
+	case 1: // PROXY
+	default:
+		return nil, InvalidProxyHeader
+	}
+
+	switch family >> 4 {
+	case 1: // AF_INET
+		if length < 12 {
+			return nil, InvalidProxyHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 2: // AF_INET6
+		if length < 36 {
+			return nil, InvalidProxyHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default: // AF_UNSPEC, AF_UNIX
+		return nil, nil
+	}
+}
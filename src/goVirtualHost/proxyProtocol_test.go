@@ -0,0 +1,115 @@
+package goVirtualHost
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadProxyHeader(t *testing.T) {
+	v2Header := func(verCmd, family byte, payload string) string {
+		return string(proxyV2Signature) + string([]byte{verCmd, family, 0, byte(len(payload))}) + payload
+	}
+
+	cases := []struct {
+		input string
+		addr  string
+		ok    bool
+	}{
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\nGET", "192.0.2.1:56324", true},
+		{"PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\nGET", "[2001:db8::1]:56324", true},
+		{"PROXY UNKNOWN\r\nGET", "", true},
+		{"PROXY TCP4 2001:db8::1 192.0.2.2 56324 443\r\nGET", "", false},
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324\r\nGET", "", false},
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\nGET", "", false},
+		{"PROXY TCP4 " + strings.Repeat("1", 200) + "\r\nGET", "", false},
+		{"GET / HTTP/1.1\r\n", "", false},
+		{v2Header(0x21, 0x11, "\xc0\x00\x02\x01\xc0\x00\x02\x02\xdc\x04\x01\xbb") + "GET", "192.0.2.1:56324", true},
+		{v2Header(0x21, 0x21, "\x20\x01\x0d\xb8"+strings.Repeat("\x00", 11)+"\x01"+strings.Repeat("\x00", 16)+"\xdc\x04\x01\xbb") + "GET", "[2001:db8::1]:56324", true},
+		{v2Header(0x20, 0x00, "") + "GET", "", true},
+		{v2Header(0x21, 0x11, "\xc0\x00\x02\x01") + "GET", "", false},
+		{v2Header(0x11, 0x11, "\xc0\x00\x02\x01\xc0\x00\x02\x02\xdc\x04\x01\xbb") + "GET", "", false},
+	}
+
+	for _, c := range cases {
+		reader := bufio.NewReader(strings.NewReader(c.input))
+		addr, err := readProxyHeader(reader)
+		if (err == nil) != c.ok {
+			t.Errorf("%q: %v", c.input, err)
+			continue
+		}
+		if !c.ok {
+			continue
+		}
+		if (addr == nil && len(c.addr) > 0) || (addr != nil && addr.String() != c.addr) {
+			t.Errorf("%q: %v", c.input, addr)
+		}
+		if rest, _ := io.ReadAll(reader); string(rest) != "GET" {
+			t.Errorf("%q: rest %q", c.input, rest)
+		}
+	}
+}
+
+func TestProxyProtocolConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go client.Write([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\nhello"))
+
+	conn := newProxyProtocolConn(server)
+	if addr := conn.RemoteAddr().String(); addr != "192.0.2.1:56324" {
+		t.Error(addr)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Error(string(buf), err)
+	}
+}
+
+func TestProxyProtocolConnDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go client.Write([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"))
+
+	// deadline set before header is read should be kept after that
+	conn := newProxyProtocolConn(server)
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	conn.RemoteAddr()
+	buf := make([]byte, 1)
+	if _, err := conn.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error(err)
+	}
+
+	// deadline set after header is read
+	conn.SetDeadline(time.Time{})
+	go client.Write([]byte("h"))
+	if _, err := conn.Read(buf); err != nil || buf[0] != 'h' {
+		t.Error(string(buf), err)
+	}
+	conn.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conn.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error(err)
+	}
+}
+
+func TestProxyProtocolListenerTrustedPeer(t *testing.T) {
+	_, peers, _ := net.ParseCIDR("192.0.2.0/24")
+	l := proxyProtocolListener{peers: []*net.IPNet{peers}}
+	if !l.isTrustedPeer(&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324}) {
+		t.Error("peer in trusted network should be trusted")
+	}
+	if l.isTrustedPeer(&net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 56324}) {
+		t.Error("peer out of trusted network should not be trusted")
+	}
+	if l.isTrustedPeer(&net.UnixAddr{Name: "/tmp/sock", Net: "unix"}) {
+		t.Error("non-TCP peer should not be trusted")
+	}
+}
@@ -18,16 +18,27 @@ func newServer(useTLS bool) *server {
 	return server
 }
 
-func (server *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	hostname := extractHostName(r.Host)
+func (server *server) getVhost(hostname string) *vhost {
 	for i := range server.vhosts {
 		if server.vhosts[i].matchHostName(hostname) {
-			server.vhosts[i].handler.ServeHTTP(w, r)
-			return
+			return server.vhosts[i]
 		}
 	}
 
-	server.defaultVhost.handler.ServeHTTP(w, r)
+	return server.defaultVhost
+}
+
+func (server *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vhost := server.getVhost(extractHostName(r.Host))
+
+	// client certificate is verified by vhost selected by SNI,
+	// which should be the same one selected by Host header
+	if r.TLS != nil && vhost.clientAuth != tls.NoClientCert && server.getVhost(r.TLS.ServerName) != vhost {
+		w.WriteHeader(http.StatusMisdirectedRequest)
+		return
+	}
+
+	vhost.handler.ServeHTTP(w, r)
 }
 
 func (server *server) getDefaultVhost() *vhost {
@@ -48,6 +59,15 @@ func (server *server) updateDefaultVhost() {
 	server.defaultVhost = server.getDefaultVhost()
 }
 
+func (server *server) hasClientAuth() bool {
+	for _, vhost := range server.vhosts {
+		if vhost.clientAuth != tls.NoClientCert {
+			return true
+		}
+	}
+	return false
+}
+
 func (server *server) updateHttpServerTLSConfig() {
 	var tlsConfig *tls.Config
 
@@ -61,6 +81,20 @@ func (server *server) updateHttpServerTLSConfig() {
 		tlsConfig = &tls.Config{
 			Certificates: certs,
 		}
+
+		if server.hasClientAuth() {
+			// config returned by GetConfigForClient is used as is,
+			// protocols are not appended by http.Server
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+			tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				vhost := server.getVhost(hello.ServerName)
+				config := tlsConfig.Clone()
+				config.GetConfigForClient = nil
+				config.ClientCAs = vhost.clientCAs
+				config.ClientAuth = vhost.clientAuth
+				return config, nil
+			}
+		}
 	}
 
 	server.httpServer.TLSConfig = tlsConfig
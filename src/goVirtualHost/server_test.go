@@ -0,0 +1,45 @@
+package goVirtualHost
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerServeHTTPClientAuth(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	public := newVhost(nil, []string{"public.example.com"}, handler)
+	private := newVhost(nil, []string{"private.example.com"}, handler)
+	private.clientAuth = tls.RequireAndVerifyClientCert
+
+	server := newServer(true)
+	server.vhosts = vhosts{public, private}
+	server.updateDefaultVhost()
+
+	if server.getVhost("private.example.com") != private {
+		t.Error("should get private vhost")
+	}
+	if server.getVhost("unknown.example.com") != public {
+		t.Error("should get default vhost")
+	}
+
+	serve := func(serverName, host string) int {
+		r := httptest.NewRequest(http.MethodGet, "https://"+host+"/", nil)
+		r.TLS = &tls.ConnectionState{ServerName: serverName}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve("private.example.com", "private.example.com"); code != http.StatusOK {
+		t.Error(code)
+	}
+	if code := serve("public.example.com", "public.example.com"); code != http.StatusOK {
+		t.Error(code)
+	}
+	if code := serve("public.example.com", "private.example.com"); code != http.StatusMisdirectedRequest {
+		t.Error(code)
+	}
+}
@@ -31,6 +31,8 @@ func (svc *Service) addVhostToServers(vhost *vhost, params params) {
 		} else {
 			server = newServer(param.useTLS)
 			listener = newListener(param.proto, param.ip, param.port)
+			listener.proxyProtocol = param.proxyProtocol
+			listener.proxyProtocolPeers = param.proxyProtocolPeers
 			listener.server = server
 
 			svc.listeners = append(svc.listeners, listener)
@@ -60,6 +62,10 @@ func (svc *Service) Add(info *HostInfo) (errs, warns []error) {
 	svc.params = append(svc.params, vhostParams...)
 
 	vhost := newVhost(certs, hostNames, info.Handler)
+	if info.ClientCAs != nil {
+		vhost.clientCAs = info.ClientCAs
+		vhost.clientAuth = info.ClientAuth
+	}
 	svc.vhosts = append(svc.vhosts, vhost)
 
 	svc.addVhostToServers(vhost, vhostParams)
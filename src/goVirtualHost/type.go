@@ -2,6 +2,7 @@ package goVirtualHost
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"net"
 	"net/http"
 	"sync"
@@ -15,29 +16,40 @@ type HostInfo struct {
 	Certs        []tls.Certificate
 	HostNames    []string
 	Handler      http.Handler
+	// verify TLS client certificates by CAs, with mode like tls.VerifyClientCertIfGiven
+	ClientCAs  *x509.CertPool
+	ClientAuth tls.ClientAuthType
+	// listeners expect PROXY protocol header from peers in ProxyProtocolPeers,
+	// connections from other peers are served as is
+	ProxyProtocol      bool
+	ProxyProtocolPeers []*net.IPNet
 }
 
 type certs []tls.Certificate
 
 // normalized HostInfo Param
 type param struct {
-	proto     string // "tcp", "tcp4", "tcp6"
-	ip        string
-	port      string
-	useTLS    bool
-	certs     certs
-	hostNames []string
+	proto              string // "tcp", "tcp4", "tcp6"
+	ip                 string
+	port               string
+	useTLS             bool
+	proxyProtocol      bool
+	proxyProtocolPeers []*net.IPNet
+	certs              certs
+	hostNames          []string
 }
 
 type params []*param
 
 // wrapper of net.Listener
 type listener struct {
-	proto       string // "tcp", "tcp4", "tcp6"
-	ip          string
-	port        string
-	netListener net.Listener
-	server      *server
+	proto              string // "tcp", "tcp4", "tcp6"
+	ip                 string
+	port               string
+	proxyProtocol      bool
+	proxyProtocolPeers []*net.IPNet
+	netListener        net.Listener
+	server             *server
 }
 
 type listeners []*listener
@@ -54,9 +66,11 @@ type servers []*server
 
 // virtual host
 type vhost struct {
-	certs     certs
-	hostNames []string
-	handler   http.Handler
+	certs      certs
+	hostNames  []string
+	handler    http.Handler
+	clientCAs  *x509.CertPool
+	clientAuth tls.ClientAuthType
 }
 
 type vhosts []*vhost
@@ -227,3 +227,15 @@ func contains(list []string, item string) bool {
 	}
 	return false
 }
+
+func ipNetsEqual(a, b []*net.IPNet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
@@ -1,8 +1,11 @@
 package i18n
 
 type Translation struct {
-	LoginLabel string
-	MkdirLabel string
+	LoginLabel    string
+	LogoutLabel   string
+	UsernameLabel string
+	PasswordLabel string
+	MkdirLabel    string
 
 	UploadFilesLabel       string
 	UploadFilesHint        string
@@ -40,8 +43,11 @@ type Translation struct {
 }
 
 var translationEnUs = Translation{
-	LoginLabel: "Login",
-	MkdirLabel: "Create dir",
+	LoginLabel:    "Login",
+	LogoutLabel:   "Logout",
+	UsernameLabel: "Username",
+	PasswordLabel: "Password",
+	MkdirLabel:    "Create dir",
 
 	UploadFilesLabel:       "Files",
 	UploadFilesHint:        "Upload files",
@@ -79,8 +85,11 @@ var translationEnUs = Translation{
 }
 
 var translationZhSimp = Translation{
-	LoginLabel: "登录",
-	MkdirLabel: "建目录",
+	LoginLabel:    "登录",
+	LogoutLabel:   "退出",
+	UsernameLabel: "用户名",
+	PasswordLabel: "密码",
+	MkdirLabel:    "建目录",
 
 	UploadFilesLabel:       "文件",
 	UploadFilesHint:        "上传文件",
@@ -118,8 +127,11 @@ var translationZhSimp = Translation{
 }
 
 var translationZhTrad = Translation{
-	LoginLabel: "登入",
-	MkdirLabel: "建目錄",
+	LoginLabel:    "登入",
+	LogoutLabel:   "登出",
+	UsernameLabel: "使用者名稱",
+	PasswordLabel: "密碼",
+	MkdirLabel:    "建目錄",
 
 	UploadFilesLabel:       "檔案",
 	UploadFilesHint:        "上傳檔案",
@@ -5,6 +5,7 @@ import (
 	"mjpclab.dev/ghfs/src/goNixArgParser"
 	"mjpclab.dev/ghfs/src/goVirtualHost"
 	"mjpclab.dev/ghfs/src/serverError"
+	"mjpclab.dev/ghfs/src/util"
 	"net/http"
 	"os"
 	"strings"
@@ -51,6 +52,48 @@ func NewCliCmd() *goNixArgParser.Command {
 	err = options.AddFlagValues("restrictaccessdirs", "--restrict-access-dir", "", []string{}, "restrict access to specific file system paths from current host, with optional extra allow list")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValues("globalallowips", "--global-allow-ip", "GHFS_GLOBAL_ALLOW_IP", nil, "allow access to all url paths from IP or CIDR")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("allowipsurls", "--allow-ip", "", nil, "url path that allows access from IP or CIDR, <sep><url><sep><ip|cidr>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("allowipsdirs", "--allow-ip-dir", "", nil, "file system path that allows access from IP or CIDR, <sep><dir><sep><ip|cidr>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("globalmutateallowips", "--global-mutate-allow-ip", "GHFS_GLOBAL_MUTATE_ALLOW_IP", nil, "allow mutating operations to all url paths from IP or CIDR")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("mutateallowipsurls", "--mutate-allow-ip", "", nil, "url path that allows mutating operations from IP or CIDR, <sep><url><sep><ip|cidr>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("mutateallowipsdirs", "--mutate-allow-ip-dir", "", nil, "file system path that allows mutating operations from IP or CIDR, <sep><dir><sep><ip|cidr>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("globaldenyips", "--global-deny-ip", "GHFS_GLOBAL_DENY_IP", nil, "deny access to all url paths from IP or CIDR")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("denyipsurls", "--deny-ip", "", nil, "url path that denys access from IP or CIDR, <sep><url><sep><ip|cidr>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("denyipsdirs", "--deny-ip-dir", "", nil, "file system path that denys access from IP or CIDR, <sep><dir><sep><ip|cidr>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("globalmutatedenyips", "--global-mutate-deny-ip", "GHFS_GLOBAL_MUTATE_DENY_IP", nil, "deny mutating operations to all url paths from IP or CIDR")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("mutatedenyipsurls", "--mutate-deny-ip", "", nil, "url path that denys mutating operations from IP or CIDR, <sep><url><sep><ip|cidr>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("mutatedenyipsdirs", "--mutate-deny-ip-dir", "", nil, "file system path that denys mutating operations from IP or CIDR, <sep><dir><sep><ip|cidr>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("rules", "--rule", "", nil, "ordered access rule, first matched rule decides, <allow|deny> <capability>,... [<condition>[!]=<value>,...]...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlag("ruleexplain", "--rule-explain", "GHFS_RULE_EXPLAIN", "show which rules decide the request by query string ?explain to client allowed to access the path, for debugging")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlagValues("globalheaders", "--global-header", "GHFS_GLOBAL_HEADER", []string{}, "custom headers for all url paths, e.g. <name>:<value>")
 	serverError.CheckFatal(err)
 
@@ -69,6 +112,45 @@ func NewCliCmd() *goNixArgParser.Command {
 	err = options.AddFlagsValues("uploaddirs", []string{"-p", "--upload-dir"}, "", nil, "file system path that allow upload files")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValue("uploadmaxfilesize", "--upload-max-file-size", "GHFS_UPLOAD_MAX_FILE_SIZE", "", "max size of each uploaded file, e.g. 100M")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("uploadmaxbodysize", "--upload-max-body-size", "GHFS_UPLOAD_MAX_BODY_SIZE", "", "max size of upload request body, e.g. 1G")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("uploadminfreespace", "--upload-min-free-space", "GHFS_UPLOAD_MIN_FREE_SPACE", "", "min free space of file system to keep when uploading, e.g. 10G")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("uploadquotadirs", "--upload-quota-dir", "", nil, "max total size of file system path for uploading, <sep><dir><sep><size>")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("uploadallows", "--upload-allow", "GHFS_UPLOAD_ALLOW", nil, "only allow uploading files whose name match wildcard")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("uploaddenies", "--upload-deny", "GHFS_UPLOAD_DENY", nil, "deny uploading files whose name match wildcard")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlag("uploadsnifftype", "--upload-sniff-type", "GHFS_UPLOAD_SNIFF_TYPE", "reject uploaded file whose content does not match type of its extension")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlag("uploadsanitizename", "--upload-sanitize-name", "GHFS_UPLOAD_SANITIZE_NAME", "strip control characters, trailing dots and spaces from uploaded file name, and shorten overlong name")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("globaluploadconflict", "--global-upload-conflict", "GHFS_GLOBAL_UPLOAD_CONFLICT", "", "policy when uploading file already exists for all url paths, rename|overwrite|reject|newer")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("uploadconflicturls", "--upload-conflict", "", nil, "url path for upload conflict policy, <sep><url><sep><policy>")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("uploadconflictdirs", "--upload-conflict-dir", "", nil, "file system path for upload conflict policy, <sep><dir><sep><policy>")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("uploaduserurls", "--upload-user", "", nil, "url path that allow specific users or @groups to upload files, <sep><url><sep><user>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("uploaduserdirs", "--upload-user-dir", "", nil, "file system path that allow specific users or @groups to upload files, <sep><dir><sep><user>...")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlag("globalmkdir", "--global-mkdir", "", "allow mkdir files for all url paths")
 	serverError.CheckFatal(err)
 
@@ -78,6 +160,12 @@ func NewCliCmd() *goNixArgParser.Command {
 	err = options.AddFlagValues("mkdirdirs", "--mkdir-dir", "", nil, "file system path that allow mkdir files")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValues("mkdiruserurls", "--mkdir-user", "", nil, "url path that allow specific users or @groups to mkdir, <sep><url><sep><user>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("mkdiruserdirs", "--mkdir-user-dir", "", nil, "file system path that allow specific users or @groups to mkdir, <sep><dir><sep><user>...")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlag("globaldelete", "--global-delete", "", "allow delete files for all url paths")
 	serverError.CheckFatal(err)
 
@@ -87,6 +175,12 @@ func NewCliCmd() *goNixArgParser.Command {
 	err = options.AddFlagValues("deletedirs", "--delete-dir", "", nil, "file system path that allow delete files")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValues("deleteuserurls", "--delete-user", "", nil, "url path that allow specific users or @groups to delete files, <sep><url><sep><user>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("deleteuserdirs", "--delete-user-dir", "", nil, "file system path that allow specific users or @groups to delete files, <sep><dir><sep><user>...")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlags("globalarchive", []string{"-A", "--global-archive"}, "GHFS_GLOBAL_ARCHIVE", "enable download archive for all directories")
 	serverError.CheckFatal(err)
 
@@ -96,6 +190,12 @@ func NewCliCmd() *goNixArgParser.Command {
 	err = options.AddFlagValues("archivedirs", "--archive-dir", "", nil, "file system path that enable download as archive for specific directories")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValues("archiveuserurls", "--archive-user", "", nil, "url path that allow specific users or @groups to download as archive, <sep><url><sep><user>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("archiveuserdirs", "--archive-user-dir", "", nil, "file system path that allow specific users or @groups to download as archive, <sep><dir><sep><user>...")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlag("globalcors", "--global-cors", "GHFS_GLOBAL_CORS", "enable CORS headers for all directories")
 	serverError.CheckFatal(err)
 
@@ -105,6 +205,15 @@ func NewCliCmd() *goNixArgParser.Command {
 	err = options.AddFlagValues("corsdirs", "--cors-dir", "", nil, "file system path that enable CORS headers")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValues("dropboxurls", "--dropbox", "", nil, "url path that is write-only drop box, items cannot be listed or read")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("dropboxdirs", "--dropbox-dir", "", nil, "file system path that is write-only drop box, items cannot be listed or read")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlag("webdav", "--webdav", "GHFS_WEBDAV", "serve WebDAV methods, permissions follow upload/mkdir/delete options")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlag("globalauth", "--global-auth", "GHFS_GLOBAL_AUTH", "require Basic Auth for all directories")
 	serverError.CheckFatal(err)
 
@@ -114,6 +223,12 @@ func NewCliCmd() *goNixArgParser.Command {
 	err = options.AddFlagValues("authdirs", "--auth-dir", "", nil, "file system path that require Basic Auth")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValues("authuserurls", "--auth-user", "", nil, "url path that only allow specific users or @groups to access, <sep><url><sep><user>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("authuserdirs", "--auth-user-dir", "", nil, "file system path that only allow specific users or @groups to access, <sep><dir><sep><user>...")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlagValues("users", "--user", "", nil, "user info: <username>:<password>")
 	serverError.CheckFatal(err)
 
@@ -132,15 +247,96 @@ func NewCliCmd() *goNixArgParser.Command {
 	err = options.AddFlagValues("userssha512", "--user-sha512", "", nil, "user info: <username>:<sha512-password>")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValues("userfiles", "--user-file", "GHFS_USER_FILE", nil, "Apache htpasswd file of users, supports bcrypt, apr1, sha512 crypt and {SHA} hashes")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlag("usermatchcase", "--user-match-case", "GHFS_USER_MATCH_CASE", "username should be case sensitive")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValues("usergroups", "--user-group", "", nil, "user group for permission options: <sep><group><sep><user>...")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("tokens", "--token", "", nil, "API token for bearer auth: <sep><name><sep><token>[<sep>path=<url>][<sep>perm=read,upload,delete][<sep>expires=<time>]")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("tokenfiles", "--token-file", "GHFS_TOKEN_FILE", nil, "file of API tokens, each line contains whitespace separated name, token and optional scopes")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlag("formlogin", "--form-login", "GHFS_FORM_LOGIN", "enable login page and session cookie, besides Basic Auth")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("sessionsecret", "--session-secret", "GHFS_SESSION_SECRET", "", "secret for signing session cookie, random if not specified")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("sessionmaxage", "--session-max-age", "GHFS_SESSION_MAX_AGE", "86400", "max age in seconds of login session")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("trustedproxies", "--trusted-proxy", "GHFS_TRUSTED_PROXY", nil, "IP or CIDR of trusted reverse proxy, whose X-Forwarded-* or Forwarded headers are used")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlag("proxyprotocol", "--proxy-protocol", "GHFS_PROXY_PROTOCOL", "listeners expect PROXY protocol v1 or v2 header from trusted proxies")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("forwardauth", "--forward-auth", "GHFS_FORWARD_AUTH", "", "url of external authorizer, which is requested before serving each request")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("forwardauthuserheader", "--forward-auth-user-header", "GHFS_FORWARD_AUTH_USER_HEADER", "X-Auth-Request-User", "response header of external authorizer that provides username")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("oidcissuer", "--oidc-issuer", "GHFS_OIDC_ISSUER", "", "OpenID Connect issuer url, enables login by OIDC provider")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("oidcclientid", "--oidc-client-id", "GHFS_OIDC_CLIENT_ID", "", "OIDC client id")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("oidcclientsecret", "--oidc-client-secret", "GHFS_OIDC_CLIENT_SECRET", "", "OIDC client secret")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("oidcredirecturl", "--oidc-redirect-url", "GHFS_OIDC_REDIRECT_URL", "", "OIDC redirect url registered at issuer, \"<origin>/?oidc-callback\" of request if not specified")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("oidcscope", "--oidc-scope", "GHFS_OIDC_SCOPE", "openid email profile", "OIDC scopes to request, separated by space")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("oidcuserclaim", "--oidc-user-claim", "GHFS_OIDC_USER_CLAIM", "email", "ID token claim as username, e.g. email or preferred_username")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("oidcgroupsclaim", "--oidc-groups-claim", "GHFS_OIDC_GROUPS_CLAIM", "groups", "ID token claim as user groups, matches \"@<group>\" of permission options")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("authfaildelayafter", "--auth-fail-delay-after", "GHFS_AUTH_FAIL_DELAY_AFTER", "5", "delay response after specific times of auth failures from same IP or for same user, 0 to disable")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("authfaillockoutafter", "--auth-fail-lockout-after", "GHFS_AUTH_FAIL_LOCKOUT_AFTER", "10", "lock out IP or user after specific times of auth failures, 0 to disable")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("authfaillockouttime", "--auth-fail-lockout-time", "GHFS_AUTH_FAIL_LOCKOUT_TIME", "600", "seconds of lockout, and to remember auth failures")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlag("share", "--share", "GHFS_SHARE", "allow authenticated users to create signed share links")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("sharesecret", "--share-secret", "GHFS_SHARE_SECRET", "", "secret for signing share links, random if not specified")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("sharemaxage", "--share-max-age", "GHFS_SHARE_MAX_AGE", "604800", "max age in seconds of share links")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlagsValues("certs", []string{"-c", "--cert"}, "GHFS_CERT", nil, "TLS certificate path")
 	serverError.CheckFatal(err)
 
 	err = options.AddFlagsValues("keys", []string{"-k", "--key"}, "GHFS_KEY", nil, "TLS certificate key path")
 	serverError.CheckFatal(err)
 
+	err = options.AddFlagValues("clientcas", "--client-ca", "GHFS_CLIENT_CA", nil, "CA certificate path for verifying TLS client certificates")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValue("clientauth", "--client-auth", "GHFS_CLIENT_AUTH", "request", "TLS client certificate verify mode: request|require")
+	serverError.CheckFatal(err)
+
+	err = options.AddFlagValues("clientcertusers", "--client-cert-user", "", nil, "map TLS client certificate subject CN or SAN to user: <sep><cert-name><sep><username>")
+	serverError.CheckFatal(err)
+
 	err = options.AddFlagsValues("listens", []string{"-l", "--listen"}, "GHFS_LISTEN", nil, "address and port to listen")
 	serverError.CheckFatal(err)
 
@@ -280,6 +476,18 @@ func ArgsToCmdResults(cmd *goNixArgParser.Command, args []string) (results []*go
 	return
 }
 
+func getSizeValue(result *goNixArgParser.ParseResult, key string) (size int64, errs []error) {
+	str, _ := result.GetString(key)
+	if len(str) == 0 {
+		return 0, nil
+	}
+	size, err := util.ParseSize(str)
+	if err != nil {
+		return 0, []error{err}
+	}
+	return size, nil
+}
+
 func CmdResultsToParams(results []*goNixArgParser.ParseResult) (params Params, errs []error) {
 	// init param data
 	params = make(Params, 0, len(results))
@@ -327,6 +535,38 @@ func CmdResultsToParams(results []*goNixArgParser.ParseResult) (params Params, e
 		restrictAccessDirs, _ := result.GetStrings("restrictaccessdirs")
 		param.RestrictAccessDirs = SplitAllKeyValues(restrictAccessDirs)
 
+		// allow ips
+		param.GlobalAllowIPs, _ = result.GetStrings("globalallowips")
+		allowIPsUrls, _ := result.GetStrings("allowipsurls")
+		param.AllowIPsUrls = SplitAllKeyValues(allowIPsUrls)
+		allowIPsDirs, _ := result.GetStrings("allowipsdirs")
+		param.AllowIPsDirs = SplitAllKeyValues(allowIPsDirs)
+
+		// deny ips
+		param.GlobalDenyIPs, _ = result.GetStrings("globaldenyips")
+		denyIPsUrls, _ := result.GetStrings("denyipsurls")
+		param.DenyIPsUrls = SplitAllKeyValues(denyIPsUrls)
+		denyIPsDirs, _ := result.GetStrings("denyipsdirs")
+		param.DenyIPsDirs = SplitAllKeyValues(denyIPsDirs)
+
+		// mutate allow ips
+		param.GlobalMutateAllowIPs, _ = result.GetStrings("globalmutateallowips")
+		mutateAllowIPsUrls, _ := result.GetStrings("mutateallowipsurls")
+		param.MutateAllowIPsUrls = SplitAllKeyValues(mutateAllowIPsUrls)
+		mutateAllowIPsDirs, _ := result.GetStrings("mutateallowipsdirs")
+		param.MutateAllowIPsDirs = SplitAllKeyValues(mutateAllowIPsDirs)
+
+		// mutate deny ips
+		param.GlobalMutateDenyIPs, _ = result.GetStrings("globalmutatedenyips")
+		mutateDenyIPsUrls, _ := result.GetStrings("mutatedenyipsurls")
+		param.MutateDenyIPsUrls = SplitAllKeyValues(mutateDenyIPsUrls)
+		mutateDenyIPsDirs, _ := result.GetStrings("mutatedenyipsdirs")
+		param.MutateDenyIPsDirs = SplitAllKeyValues(mutateDenyIPsDirs)
+
+		// rules
+		param.Rules, _ = result.GetStrings("rules")
+		param.RuleExplain = result.HasKey("ruleexplain")
+
 		// global headers
 		globalHeaders, _ := result.GetStrings("globalheaders")
 		param.GlobalHeaders = EntriesToKVs(globalHeaders)
@@ -346,30 +586,81 @@ func CmdResultsToParams(results []*goNixArgParser.ParseResult) (params Params, e
 		errs = append(errs, es...)
 		param.Certificates = certs
 
+		// client certificate
+		clientCAFiles, _ := result.GetStrings("clientcas")
+		param.ClientCAs, es = goVirtualHost.LoadCertPool(clientCAFiles)
+		errs = append(errs, es...)
+		param.ClientAuth, _ = result.GetString("clientauth")
+		clientCertUsers, _ := result.GetStrings("clientcertusers")
+		param.ClientCertUsers = SplitAllKeyValue(clientCertUsers)
+
 		// upload/mkdir/delete/archive/cors/auth urls/dirs
 		param.GlobalUpload = result.HasKey("globalupload")
 		param.UploadUrls, _ = result.GetStrings("uploadurls")
 		param.UploadDirs, _ = result.GetStrings("uploaddirs")
+		uploadUserUrls, _ := result.GetStrings("uploaduserurls")
+		param.UploadUserUrls = SplitAllKeyValues(uploadUserUrls)
+		uploadUserDirs, _ := result.GetStrings("uploaduserdirs")
+		param.UploadUserDirs = SplitAllKeyValues(uploadUserDirs)
+
+		param.UploadMaxFileSize, es = getSizeValue(result, "uploadmaxfilesize")
+		errs = append(errs, es...)
+		param.UploadMaxBodySize, es = getSizeValue(result, "uploadmaxbodysize")
+		errs = append(errs, es...)
+		param.UploadMinFreeSpace, es = getSizeValue(result, "uploadminfreespace")
+		errs = append(errs, es...)
+		uploadQuotaDirs, _ := result.GetStrings("uploadquotadirs")
+		param.UploadQuotaDirs = SplitAllKeyValue(uploadQuotaDirs)
+		param.UploadAllows, _ = result.GetStrings("uploadallows")
+		param.UploadDenies, _ = result.GetStrings("uploaddenies")
+		param.UploadSniffType = result.HasKey("uploadsnifftype")
+		param.UploadSanitizeName = result.HasKey("uploadsanitizename")
+		param.GlobalUploadConflict, _ = result.GetString("globaluploadconflict")
+		uploadConflictUrls, _ := result.GetStrings("uploadconflicturls")
+		param.UploadConflictUrls = SplitAllKeyValue(uploadConflictUrls)
+		uploadConflictDirs, _ := result.GetStrings("uploadconflictdirs")
+		param.UploadConflictDirs = SplitAllKeyValue(uploadConflictDirs)
 
 		param.GlobalMkdir = result.HasKey("globalmkdir")
 		param.MkdirUrls, _ = result.GetStrings("mkdirurls")
 		param.MkdirDirs, _ = result.GetStrings("mkdirdirs")
+		mkdirUserUrls, _ := result.GetStrings("mkdiruserurls")
+		param.MkdirUserUrls = SplitAllKeyValues(mkdirUserUrls)
+		mkdirUserDirs, _ := result.GetStrings("mkdiruserdirs")
+		param.MkdirUserDirs = SplitAllKeyValues(mkdirUserDirs)
 
 		param.GlobalDelete = result.HasKey("globaldelete")
 		param.DeleteUrls, _ = result.GetStrings("deleteurls")
 		param.DeleteDirs, _ = result.GetStrings("deletedirs")
+		deleteUserUrls, _ := result.GetStrings("deleteuserurls")
+		param.DeleteUserUrls = SplitAllKeyValues(deleteUserUrls)
+		deleteUserDirs, _ := result.GetStrings("deleteuserdirs")
+		param.DeleteUserDirs = SplitAllKeyValues(deleteUserDirs)
 
 		param.GlobalArchive = result.HasKey("globalarchive")
 		param.ArchiveUrls, _ = result.GetStrings("archiveurls")
 		param.ArchiveDirs, _ = result.GetStrings("archivedirs")
+		archiveUserUrls, _ := result.GetStrings("archiveuserurls")
+		param.ArchiveUserUrls = SplitAllKeyValues(archiveUserUrls)
+		archiveUserDirs, _ := result.GetStrings("archiveuserdirs")
+		param.ArchiveUserDirs = SplitAllKeyValues(archiveUserDirs)
 
 		param.GlobalCors = result.HasKey("globalcors")
 		param.CorsUrls, _ = result.GetStrings("corsurls")
 		param.CorsDirs, _ = result.GetStrings("corsdirs")
 
+		param.DropboxUrls, _ = result.GetStrings("dropboxurls")
+		param.DropboxDirs, _ = result.GetStrings("dropboxdirs")
+
+		param.Webdav = result.HasKey("webdav")
+
 		param.GlobalAuth = result.HasKey("globalauth")
 		param.AuthUrls, _ = result.GetStrings("authurls")
 		param.AuthDirs, _ = result.GetStrings("authdirs")
+		authUserUrls, _ := result.GetStrings("authuserurls")
+		param.AuthUserUrls = SplitAllKeyValues(authUserUrls)
+		authUserDirs, _ := result.GetStrings("authuserdirs")
+		param.AuthUserDirs = SplitAllKeyValues(authUserDirs)
 
 		// users
 		arrUsersPlain, _ := result.GetStrings("users")
@@ -384,6 +675,46 @@ func CmdResultsToParams(results []*goNixArgParser.ParseResult) (params Params, e
 		param.UsersSha256 = entriesToUsers(arrUsersSha256)
 		arrUsersSha512, _ := result.GetStrings("userssha512")
 		param.UsersSha512 = entriesToUsers(arrUsersSha512)
+		param.UserFiles, _ = result.GetStrings("userfiles")
+		userGroups, _ := result.GetStrings("usergroups")
+		param.UserGroups = SplitAllKeyValues(userGroups)
+
+		// tokens
+		tokens, _ := result.GetStrings("tokens")
+		param.Tokens = SplitAllKeyValues(tokens)
+		param.TokenFiles, _ = result.GetStrings("tokenfiles")
+
+		// form login
+		param.FormLogin = result.HasKey("formlogin")
+		param.SessionSecret, _ = result.GetString("sessionsecret")
+		param.SessionMaxAge, _ = result.GetInt("sessionmaxage")
+
+		// reverse proxy
+		param.TrustedProxies, _ = result.GetStrings("trustedproxies")
+		param.ProxyProtocol = result.HasKey("proxyprotocol")
+
+		// forward auth
+		param.ForwardAuth, _ = result.GetString("forwardauth")
+		param.ForwardAuthUserHeader, _ = result.GetString("forwardauthuserheader")
+
+		// OIDC
+		param.OidcIssuer, _ = result.GetString("oidcissuer")
+		param.OidcClientId, _ = result.GetString("oidcclientid")
+		param.OidcClientSecret, _ = result.GetString("oidcclientsecret")
+		param.OidcRedirectUrl, _ = result.GetString("oidcredirecturl")
+		param.OidcScope, _ = result.GetString("oidcscope")
+		param.OidcUserClaim, _ = result.GetString("oidcuserclaim")
+		param.OidcGroupsClaim, _ = result.GetString("oidcgroupsclaim")
+
+		// auth failures
+		param.AuthFailDelayAfter, _ = result.GetInt("authfaildelayafter")
+		param.AuthFailLockoutAfter, _ = result.GetInt("authfaillockoutafter")
+		param.AuthFailLockoutTime, _ = result.GetInt("authfaillockouttime")
+
+		// share
+		param.Share = result.HasKey("share")
+		param.ShareSecret, _ = result.GetString("sharesecret")
+		param.ShareMaxAge, _ = result.GetInt("sharemaxage")
 
 		// listen
 		listens, _ := result.GetStrings("listens")
@@ -1,7 +1,9 @@
 package param
 
 import (
+	"errors"
 	"mjpclab.dev/ghfs/src/util"
+	"net/url"
 	"path/filepath"
 	"strings"
 )
@@ -134,6 +136,163 @@ eachInput:
 	return
 }
 
+// normalizePathSizes
+// input element: [2]string{"fs-path", "size"}
+func normalizePathSizes(inputs [][2]string) (results [][2]string, errs []error) {
+	results = make([][2]string, 0, len(inputs))
+
+	for i := range inputs {
+		fsPath, err := filepath.Abs(inputs[i][0])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err = util.ParseSize(inputs[i][1]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		results = append(results, [2]string{fsPath, inputs[i][1]})
+	}
+
+	return
+}
+
+func normalizeForwardAuth(param *Param) error {
+	if len(param.ForwardAuthUserHeader) == 0 {
+		param.ForwardAuthUserHeader = "X-Auth-Request-User"
+	}
+	authUrl, err := url.Parse(param.ForwardAuth)
+	if err != nil || (authUrl.Scheme != "http" && authUrl.Scheme != "https") || len(authUrl.Host) == 0 {
+		return errors.New("invalid forward auth url: " + param.ForwardAuth)
+	}
+	return nil
+}
+
+func normalizeOidc(param *Param) (errs []error) {
+	issuer, err := url.Parse(param.OidcIssuer)
+	if err != nil || (issuer.Scheme != "http" && issuer.Scheme != "https") || len(issuer.Host) == 0 {
+		errs = append(errs, errors.New("invalid OIDC issuer url: "+param.OidcIssuer))
+	}
+	if len(param.OidcClientId) == 0 {
+		errs = append(errs, errors.New("OIDC client id is required for issuer: "+param.OidcIssuer))
+	}
+	if len(param.OidcScope) == 0 {
+		param.OidcScope = "openid email profile"
+	} else if !util.Contains(strings.Fields(param.OidcScope), "openid") {
+		param.OidcScope = "openid " + param.OidcScope
+	}
+	if len(param.OidcUserClaim) == 0 {
+		param.OidcUserClaim = "email"
+	}
+	return
+}
+
+var clientAuths = []string{"request", "require"}
+
+func normalizeClientAuth(input string) (string, error) {
+	if len(input) == 0 {
+		return clientAuths[0], nil
+	}
+	mode := strings.ToLower(input)
+	if !util.Contains(clientAuths, mode) {
+		return "", errors.New("unknown client auth mode: " + input)
+	}
+	return mode, nil
+}
+
+var uploadConflicts = []string{"rename", "overwrite", "reject", "newer"}
+
+func normalizeUploadConflict(input string) (string, error) {
+	if len(input) == 0 {
+		return "", nil
+	}
+	policy := strings.ToLower(input)
+	if !util.Contains(uploadConflicts, policy) {
+		return "", errors.New("unknown upload conflict policy: " + input)
+	}
+	return policy, nil
+}
+
+// normalizeUploadConflicts
+// input element: [2]string{"path", "policy"}
+func normalizeUploadConflicts(inputs [][2]string, normalizePath func(string) (string, error)) (results [][2]string, errs []error) {
+	results = make([][2]string, 0, len(inputs))
+
+	for i := range inputs {
+		reqPath, err := normalizePath(inputs[i][0])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		policy, err := normalizeUploadConflict(inputs[i][1])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(policy) == 0 {
+			continue
+		}
+		results = append(results, [2]string{reqPath, policy})
+	}
+
+	return
+}
+
+func normalizeUsernames(inputs []string) []string {
+	outputs := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		if len(input) > 0 {
+			outputs = append(outputs, input)
+		}
+	}
+	return outputs
+}
+
+func normalizePathUsers(inputs [][]string, normalizePath func(string) (string, error)) (results [][]string, errs []error) {
+	results, errs = normalizeAllPathValues(inputs, false, normalizePath, normalizeUsernames)
+	if len(errs) == 0 {
+		dedupAllPathValues(results)
+	}
+	return
+}
+
+// normalizePathIPs normalizes paths, IP or CIDR values are validated when parsed by handler.
+func normalizePathIPs(inputs [][]string, normalizePath func(string) (string, error)) (results [][]string, errs []error) {
+	results, errs = normalizeAllPathValues(inputs, false, normalizePath, nil)
+	if len(errs) == 0 {
+		dedupAllPathValues(results)
+	}
+	return
+}
+
+func normalizeUserGroups(inputs [][]string) [][]string {
+	results := make([][]string, 0, len(inputs))
+
+eachInput:
+	for i := range inputs {
+		if len(inputs[i]) == 0 || len(inputs[i][0]) == 0 {
+			continue
+		}
+		group := inputs[i][0]
+		users := normalizeUsernames(inputs[i][1:])
+
+		for j := range results {
+			if results[j][0] == group {
+				results[j] = append(results[j], users...)
+				continue eachInput
+			}
+		}
+
+		entry := make([]string, 1+len(users))
+		entry[0] = group
+		copy(entry[1:], users)
+		results = append(results, entry)
+	}
+
+	dedupAllPathValues(results)
+	return results
+}
+
 func normalizeHeaders(inputs []string) []string {
 	if len(inputs) != 2 {
 		return nil
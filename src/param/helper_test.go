@@ -270,3 +270,37 @@ func TestNormalizeHttpsPort(t *testing.T) {
 		t.Error("16")
 	}
 }
+
+func TestNormalizePathUsers(t *testing.T) {
+	results, _ := normalizePathUsers([][]string{
+		{"/foo", "alice", "@staff"},
+		{"/foo/", "alice", "bob", ""},
+		{"/bar"},
+	}, util.NormalizeUrlPath)
+
+	if len(results) != 1 {
+		t.Error(results)
+	}
+	if !expectStrings(results[0], "/foo", "alice", "@staff", "bob") {
+		t.Error(results[0])
+	}
+}
+
+func TestNormalizeUserGroups(t *testing.T) {
+	results := normalizeUserGroups([][]string{
+		{"staff", "alice", "bob"},
+		{""},
+		{"staff", "carol", "alice"},
+		{"guest"},
+	})
+
+	if len(results) != 2 {
+		t.Error(results)
+	}
+	if !expectStrings(results[0], "staff", "alice", "bob", "carol") {
+		t.Error(results[0])
+	}
+	if !expectStrings(results[1], "guest") {
+		t.Error(results[1])
+	}
+}
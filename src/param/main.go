@@ -2,6 +2,8 @@ package param
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"mjpclab.dev/ghfs/src/middleware"
 	"mjpclab.dev/ghfs/src/serverError"
 	"mjpclab.dev/ghfs/src/util"
@@ -26,6 +28,25 @@ type Param struct {
 	RestrictAccessUrls [][]string
 	RestrictAccessDirs [][]string
 
+	// value: IP or CIDR
+	GlobalAllowIPs       []string
+	GlobalDenyIPs        []string
+	GlobalMutateAllowIPs []string
+	GlobalMutateDenyIPs  []string
+	// value: [path, IPs or CIDRs...]
+	AllowIPsUrls       [][]string
+	AllowIPsDirs       [][]string
+	DenyIPsUrls        [][]string
+	DenyIPsDirs        [][]string
+	MutateAllowIPsUrls [][]string
+	MutateAllowIPsDirs [][]string
+	MutateDenyIPsUrls  [][]string
+	MutateDenyIPsDirs  [][]string
+
+	// value: "<allow|deny> <capability>[,<capability>...] [<condition>[!]=<value>[,<value>...]]..."
+	Rules       []string
+	RuleExplain bool
+
 	// value: [name, value]
 	GlobalHeaders [][2]string
 	// value: [path, (name, value)...]
@@ -35,26 +56,62 @@ type Param struct {
 	GlobalUpload bool
 	UploadUrls   []string
 	UploadDirs   []string
+	// value: [path, users...]
+	UploadUserUrls [][]string
+	UploadUserDirs [][]string
+
+	UploadMaxFileSize  int64
+	UploadMaxBodySize  int64
+	UploadMinFreeSpace int64
+	// value: [fs-path, size]
+	UploadQuotaDirs [][2]string
+
+	UploadAllows       []string
+	UploadDenies       []string
+	UploadSniffType    bool
+	UploadSanitizeName bool
+
+	GlobalUploadConflict string
+	// value: [path, policy]
+	UploadConflictUrls [][2]string
+	UploadConflictDirs [][2]string
 
 	GlobalMkdir bool
 	MkdirUrls   []string
 	MkdirDirs   []string
+	// value: [path, users...]
+	MkdirUserUrls [][]string
+	MkdirUserDirs [][]string
 
 	GlobalDelete bool
 	DeleteUrls   []string
 	DeleteDirs   []string
+	// value: [path, users...]
+	DeleteUserUrls [][]string
+	DeleteUserDirs [][]string
 
 	GlobalArchive bool
 	ArchiveUrls   []string
 	ArchiveDirs   []string
+	// value: [path, users...]
+	ArchiveUserUrls [][]string
+	ArchiveUserDirs [][]string
 
 	GlobalCors bool
 	CorsUrls   []string
 	CorsDirs   []string
 
+	DropboxUrls []string
+	DropboxDirs []string
+
+	Webdav bool
+
 	GlobalAuth bool
 	AuthUrls   []string
 	AuthDirs   []string
+	// value: [path, users...]
+	AuthUserUrls [][]string
+	AuthUserDirs [][]string
 	// value: [username, password]
 	UsersPlain    [][2]string
 	UsersBase64   [][2]string
@@ -62,15 +119,52 @@ type Param struct {
 	UsersSha1     [][2]string
 	UsersSha256   [][2]string
 	UsersSha512   [][2]string
+	UserFiles     []string
 	UserMatchCase bool
+	// value: [group, users...]
+	UserGroups [][]string
+	// value: [name, token, scopes...]
+	Tokens     [][]string
+	TokenFiles []string
+
+	FormLogin     bool
+	SessionSecret string
+	SessionMaxAge int
+
+	// value: IP or CIDR
+	TrustedProxies []string
+	ProxyProtocol  bool
+
+	ForwardAuth           string
+	ForwardAuthUserHeader string
+
+	OidcIssuer       string
+	OidcClientId     string
+	OidcClientSecret string
+	OidcRedirectUrl  string
+	OidcScope        string
+	OidcUserClaim    string
+	OidcGroupsClaim  string
+
+	AuthFailDelayAfter   int
+	AuthFailLockoutAfter int
+	AuthFailLockoutTime  int
+
+	Share       bool
+	ShareSecret string
+	ShareMaxAge int
 
 	Certificates []tls.Certificate
-	Listens      []string
-	ListensPlain []string
-	ListensTLS   []string
-	HostNames    []string
-	Theme        string
-	ThemeDir     string
+	ClientCAs    *x509.CertPool
+	ClientAuth   string
+	// value: [cert-name, username]
+	ClientCertUsers [][2]string
+	Listens         []string
+	ListensPlain    []string
+	ListensTLS      []string
+	HostNames       []string
+	Theme           string
+	ThemeDir        string
 
 	Hsts        bool
 	HstsMaxAge  int
@@ -154,6 +248,24 @@ func (param *Param) normalize() (errs []error) {
 		errs = append(errs, es...)
 	}
 
+	// ip access lists
+	param.AllowIPsUrls, es = normalizePathIPs(param.AllowIPsUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.AllowIPsDirs, es = normalizePathIPs(param.AllowIPsDirs, filepath.Abs)
+	errs = append(errs, es...)
+	param.DenyIPsUrls, es = normalizePathIPs(param.DenyIPsUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.DenyIPsDirs, es = normalizePathIPs(param.DenyIPsDirs, filepath.Abs)
+	errs = append(errs, es...)
+	param.MutateAllowIPsUrls, es = normalizePathIPs(param.MutateAllowIPsUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.MutateAllowIPsDirs, es = normalizePathIPs(param.MutateAllowIPsDirs, filepath.Abs)
+	errs = append(errs, es...)
+	param.MutateDenyIPsUrls, es = normalizePathIPs(param.MutateDenyIPsUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.MutateDenyIPsDirs, es = normalizePathIPs(param.MutateDenyIPsDirs, filepath.Abs)
+	errs = append(errs, es...)
+
 	// headers
 	param.HeadersUrls, es = normalizeAllPathValues(param.HeadersUrls, false, util.NormalizeUrlPath, normalizeHeaders)
 	errs = append(errs, es...)
@@ -161,7 +273,7 @@ func (param *Param) normalize() (errs []error) {
 	param.HeadersDirs, es = normalizeAllPathValues(param.HeadersDirs, false, filepath.Abs, normalizeHeaders)
 	errs = append(errs, es...)
 
-	// upload/mkdir/delete/archive/cors/auth urls/dirs
+	// upload/mkdir/delete/archive/cors/dropbox/auth urls/dirs
 	param.UploadUrls = NormalizeUrlPaths(param.UploadUrls)
 	param.UploadDirs = NormalizeFsPaths(param.UploadDirs)
 	param.MkdirUrls = NormalizeUrlPaths(param.MkdirUrls)
@@ -172,8 +284,81 @@ func (param *Param) normalize() (errs []error) {
 	param.ArchiveDirs = NormalizeFsPaths(param.ArchiveDirs)
 	param.CorsUrls = NormalizeUrlPaths(param.CorsUrls)
 	param.CorsDirs = NormalizeFsPaths(param.CorsDirs)
+	param.DropboxUrls = NormalizeUrlPaths(param.DropboxUrls)
+	param.DropboxDirs = NormalizeFsPaths(param.DropboxDirs)
 	param.AuthUrls = NormalizeUrlPaths(param.AuthUrls)
 	param.AuthDirs = NormalizeFsPaths(param.AuthDirs)
+	param.UserFiles = NormalizeFsPaths(param.UserFiles)
+	param.TokenFiles = NormalizeFsPaths(param.TokenFiles)
+
+	// upload/mkdir/delete/archive/auth users
+	param.UploadUserUrls, es = normalizePathUsers(param.UploadUserUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.UploadUserDirs, es = normalizePathUsers(param.UploadUserDirs, filepath.Abs)
+	errs = append(errs, es...)
+	param.MkdirUserUrls, es = normalizePathUsers(param.MkdirUserUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.MkdirUserDirs, es = normalizePathUsers(param.MkdirUserDirs, filepath.Abs)
+	errs = append(errs, es...)
+	param.DeleteUserUrls, es = normalizePathUsers(param.DeleteUserUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.DeleteUserDirs, es = normalizePathUsers(param.DeleteUserDirs, filepath.Abs)
+	errs = append(errs, es...)
+	param.ArchiveUserUrls, es = normalizePathUsers(param.ArchiveUserUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.ArchiveUserDirs, es = normalizePathUsers(param.ArchiveUserDirs, filepath.Abs)
+	errs = append(errs, es...)
+	param.AuthUserUrls, es = normalizePathUsers(param.AuthUserUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.AuthUserDirs, es = normalizePathUsers(param.AuthUserDirs, filepath.Abs)
+	errs = append(errs, es...)
+	param.UserGroups = normalizeUserGroups(param.UserGroups)
+
+	// session
+	if param.SessionMaxAge <= 0 {
+		param.SessionMaxAge = 86400
+	}
+
+	// forward auth
+	if len(param.ForwardAuth) > 0 {
+		errs = serverError.AppendError(errs, normalizeForwardAuth(param))
+	}
+
+	// OIDC
+	if len(param.OidcIssuer) > 0 {
+		errs = append(errs, normalizeOidc(param)...)
+	}
+
+	// client certificate
+	param.ClientAuth, err = normalizeClientAuth(param.ClientAuth)
+	errs = serverError.AppendError(errs, err)
+
+	// auth failures
+	if param.AuthFailLockoutTime <= 0 {
+		param.AuthFailLockoutTime = 600
+	}
+
+	// share
+	if param.ShareMaxAge <= 0 {
+		param.ShareMaxAge = 604800
+	}
+
+	// upload quota
+	param.UploadQuotaDirs, es = normalizePathSizes(param.UploadQuotaDirs)
+	errs = append(errs, es...)
+
+	// upload conflict
+	param.GlobalUploadConflict, err = normalizeUploadConflict(param.GlobalUploadConflict)
+	errs = serverError.AppendError(errs, err)
+	param.UploadConflictUrls, es = normalizeUploadConflicts(param.UploadConflictUrls, util.NormalizeUrlPath)
+	errs = append(errs, es...)
+	param.UploadConflictDirs, es = normalizeUploadConflicts(param.UploadConflictDirs, filepath.Abs)
+	errs = append(errs, es...)
+
+	// PROXY protocol
+	if param.ProxyProtocol && len(param.TrustedProxies) == 0 {
+		errs = append(errs, errors.New("PROXY protocol requires trusted proxies"))
+	}
 
 	// hsts & https
 	if param.Hsts {
@@ -10,15 +10,11 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var defaultHandler = http.NotFoundHandler()
 
-type pathStrings struct {
-	path    string
-	strings []string
-}
-
 type aliasHandler struct {
 	root          string
 	emptyRoot     bool
@@ -31,6 +27,7 @@ type aliasHandler struct {
 	aliasPrefix   string
 
 	users  *user.List
+	tokens *user.TokenList
 	theme  theme.Theme
 	logger *serverLog.Logger
 
@@ -43,39 +40,51 @@ type aliasHandler struct {
 
 	dirIndexes []string
 	aliases    aliases
+	allAliases aliases // aliases of whole vhost, except empty root
+
+	policy      *policy
+	ruleExplain bool
+
+	dropboxUrls []string
+	dropboxDirs []string
+
+	clientCertUsers [][2]string
+
+	formLogin     bool
+	oidc          *oidcProvider
+	sessionKey    []byte
+	sessionMaxAge time.Duration
+
+	share       bool
+	shareKey    []byte
+	shareMaxAge time.Duration
 
-	globalAuth bool
-	authUrls   []string
-	authDirs   []string
+	authFailures *authFailures
 
-	restrictAccess       bool
-	globalRestrictAccess []string
-	restrictAccessUrls   []pathStrings
-	restrictAccessDirs   []pathStrings
+	restrictAccess bool
 
 	globalHeaders [][2]string
 	headersUrls   []pathHeaders
 	headersDirs   []pathHeaders
 
-	globalUpload bool
-	uploadUrls   []string
-	uploadDirs   []string
+	uploadMaxFileSize  int64
+	uploadMaxBodySize  int64
+	uploadMinFreeSpace int64
+	uploadQuotaDirs    []pathSize
 
-	globalMkdir bool
-	mkdirUrls   []string
-	mkdirDirs   []string
+	uploadAllows       *regexp.Regexp
+	uploadDenies       *regexp.Regexp
+	uploadSniffType    bool
+	uploadSanitizeName bool
 
-	globalDelete bool
-	deleteUrls   []string
-	deleteDirs   []string
+	globalUploadConflict uploadConflict
+	uploadConflictUrls   []pathUploadConflict
+	uploadConflictDirs   []pathUploadConflict
 
-	globalArchive bool
-	archiveUrls   []string
-	archiveDirs   []string
+	webdav   bool
+	davLocks *davLocks
 
-	globalCors bool
-	corsUrls   []string
-	corsDirs   []string
+	tusUploads *tusUploads
 
 	vary string
 
@@ -103,8 +112,15 @@ func (h *aliasHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// data
+	if h.oidc != nil {
+		r = withSessionGroups(r)
+	}
 	data, fsPath := h.getResponseData(r)
+	setAccessLogUser(r, data.AuthUserName)
 	h.logErrors(data.errors)
+	if !data.authLockedUntil.IsZero() {
+		setRetryAfter(w, data.authLockedUntil)
+	}
 	if data.File != nil {
 		defer func() {
 			data.File.Close()
@@ -122,7 +138,33 @@ func (h *aliasHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if data.IsOidcCallback {
+		h.oidcCallback(w, r, data)
+		return
+	}
+
+	if data.IsLogin {
+		h.login(w, r, data)
+		return
+	}
+
+	if data.IsLogout {
+		h.logout(w, r, data)
+		return
+	}
+
 	if data.NeedAuth {
+		if !data.AuthSuccess && wantLoginPage(r, data) {
+			// redirect to issuer only if not logged in, otherwise it loops
+			if h.oidc != nil && len(data.AuthUserName) == 0 && data.authLockedUntil.IsZero() {
+				h.oidcLogin(w, r, data)
+				return
+			}
+			if h.formLogin {
+				h.loginPage(w, r, data, data.Status)
+				return
+			}
+		}
 		h.notifyAuth(w, r)
 	}
 
@@ -132,6 +174,12 @@ func (h *aliasHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// explain only to client that is already allowed to access
+		if data.IsExplain {
+			h.explain(w, r, data, fsPath)
+			return
+		}
+
 		if data.NeedDirSlashRedirect {
 			h.redirectWithSlashSuffix(w, r, data.prefixReqPath)
 			return
@@ -143,6 +191,30 @@ func (h *aliasHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		header(w, data.Headers)
 
+		if data.IsShare {
+			h.createShare(w, r, data)
+			return
+		}
+
+		if h.share {
+			h.keepShare(w, r, data)
+		}
+
+		if data.IsTus {
+			h.tus(w, r, data)
+			return
+		}
+
+		if data.IsPut {
+			h.put(w, r, data)
+			return
+		}
+
+		if data.IsWebdav {
+			h.serveWebdav(w, r, data)
+			return
+		}
+
 		if data.IsMutate {
 			h.mutate(w, r, data)
 			return
@@ -164,6 +236,10 @@ func (h *aliasHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if data.CanUpload || data.CanMkdir || data.CanDelete {
+		data.CsrfToken = h.getCsrfToken(w, r, data.AuthUserName)
+	}
+
 	if h.applyMiddlewares(h.postMiddlewares, w, r, data, fsPath) {
 		return
 	}
@@ -193,6 +269,13 @@ func newAliasHandler(
 		}
 	}
 
+	vhostAliases := make([]alias, 0, len(allAliases))
+	for _, alias := range allAliases {
+		if !p.EmptyRoot || alias.url != "/" {
+			vhostAliases = append(vhostAliases, alias)
+		}
+	}
+
 	h := &aliasHandler{
 		root:          currentAlias.fs,
 		emptyRoot:     emptyRoot,
@@ -205,44 +288,57 @@ func newAliasHandler(
 		aliasPrefix:   currentAlias.url,
 
 		users:  vhostCtx.users,
+		tokens: vhostCtx.tokens,
 		theme:  vhostCtx.theme,
 		logger: vhostCtx.logger,
 
 		dirIndexes: p.DirIndexes,
 		aliases:    aliases,
+		allAliases: vhostAliases,
+
+		policy:      vhostCtx.policy,
+		ruleExplain: p.RuleExplain,
+
+		dropboxUrls: p.DropboxUrls,
+		dropboxDirs: p.DropboxDirs,
+
+		clientCertUsers: p.ClientCertUsers,
+
+		formLogin:     p.FormLogin,
+		oidc:          vhostCtx.oidc,
+		sessionKey:    vhostCtx.sessionKey,
+		sessionMaxAge: time.Duration(p.SessionMaxAge) * time.Second,
+
+		share:       p.Share,
+		shareKey:    vhostCtx.shareKey,
+		shareMaxAge: time.Duration(p.ShareMaxAge) * time.Second,
 
-		globalAuth: p.GlobalAuth,
-		authUrls:   p.AuthUrls,
-		authDirs:   p.AuthDirs,
+		authFailures: vhostCtx.authFailures,
 
-		restrictAccess:       vhostCtx.restrictAccess,
-		globalRestrictAccess: p.GlobalRestrictAccess,
-		restrictAccessUrls:   vhostCtx.restrictAccessUrls,
-		restrictAccessDirs:   vhostCtx.restrictAccessDirs,
+		restrictAccess: vhostCtx.restrictAccess,
 
 		globalHeaders: p.GlobalHeaders,
 		headersUrls:   vhostCtx.headersUrls,
 		headersDirs:   vhostCtx.headersDirs,
 
-		globalUpload: p.GlobalUpload,
-		uploadUrls:   p.UploadUrls,
-		uploadDirs:   p.UploadDirs,
+		uploadMaxFileSize:  p.UploadMaxFileSize,
+		uploadMaxBodySize:  p.UploadMaxBodySize,
+		uploadMinFreeSpace: p.UploadMinFreeSpace,
+		uploadQuotaDirs:    vhostCtx.uploadQuotaDirs,
 
-		globalMkdir: p.GlobalMkdir,
-		mkdirUrls:   p.MkdirUrls,
-		mkdirDirs:   p.MkdirDirs,
+		uploadAllows:       vhostCtx.uploadAllows,
+		uploadDenies:       vhostCtx.uploadDenies,
+		uploadSniffType:    p.UploadSniffType,
+		uploadSanitizeName: p.UploadSanitizeName,
 
-		globalDelete: p.GlobalDelete,
-		deleteUrls:   p.DeleteUrls,
-		deleteDirs:   p.DeleteDirs,
+		globalUploadConflict: parseUploadConflict(p.GlobalUploadConflict),
+		uploadConflictUrls:   vhostCtx.uploadConflictUrls,
+		uploadConflictDirs:   vhostCtx.uploadConflictDirs,
 
-		globalArchive: p.GlobalArchive,
-		archiveUrls:   p.ArchiveUrls,
-		archiveDirs:   p.ArchiveDirs,
+		webdav:   p.Webdav,
+		davLocks: vhostCtx.davLocks,
 
-		globalCors: p.GlobalCors,
-		corsUrls:   p.CorsUrls,
-		corsDirs:   p.CorsDirs,
+		tusUploads: vhostCtx.tusUploads,
 
 		shows:     vhostCtx.shows,
 		showDirs:  vhostCtx.showDirs,
@@ -167,3 +167,31 @@ func TestAliasNextPartOf(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestAliasesByReqPath(t *testing.T) {
+	aliases := newAliases([][2]string{
+		{"/", "/tmp/root"},
+		{"/hello/world", "/tmp/world"},
+		{"/hello", "/tmp/hello"},
+	})
+
+	alias, ok := aliases.byReqPath("/hello/world/foo")
+	if !ok || alias.url != "/hello/world" {
+		t.Error(alias)
+	}
+
+	alias, ok = aliases.byReqPath("/hello/worldfoo")
+	if !ok || alias.url != "/hello" {
+		t.Error(alias)
+	}
+
+	alias, ok = aliases.byReqPath("/foo")
+	if !ok || alias.url != "/" {
+		t.Error(alias)
+	}
+
+	_, ok = aliases[:2].byReqPath("/foo")
+	if ok {
+		t.Error()
+	}
+}
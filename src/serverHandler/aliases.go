@@ -26,6 +26,17 @@ func (aliases aliases) byUrlPath(urlPath string) (aliasItem alias, ok bool) {
 	return alias{}, false
 }
 
+// byReqPath finds the alias that serves rawReqPath,
+// which is the longest one matches or is predecessor of it.
+func (aliases aliases) byReqPath(rawReqPath string) (aliasItem alias, ok bool) {
+	for _, alias := range aliases {
+		if alias.isMatch(rawReqPath) || alias.isPredecessorOf(rawReqPath) {
+			return alias, true
+		}
+	}
+	return alias{}, false
+}
+
 func (aliases aliases) Len() int {
 	return len(aliases)
 }
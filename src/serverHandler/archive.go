@@ -43,8 +43,10 @@ func matchSelection(info os.FileInfo, selections []string) (matchName, matchPref
 }
 
 func (h *aliasHandler) visitTreeNode(
+	r *http.Request,
 	fsPath, rawReqPath, relPath string,
 	statNode bool,
+	authUserName string,
 	childSelections []string,
 	archiveCallback archiveCallback,
 ) {
@@ -97,6 +99,7 @@ func (h *aliasHandler) visitTreeNode(
 	if fInfo.IsDir() {
 		childInfos, _, _ := h.mergeAlias(rawReqPath, fInfo, childInfos, true)
 		childInfos = h.FilterItems(childInfos)
+		childInfos = h.filterAllowedItems(r, rawReqPath, fsPath, childInfos, authUserName)
 
 		// childInfo can be regular dir/file, or aliased item that shadows regular dir/file
 		for _, childInfo := range childInfos {
@@ -111,9 +114,15 @@ func (h *aliasHandler) visitTreeNode(
 			childRelPath := relPath + childPath
 
 			if childAlias, hasChildAlias := h.aliases.byUrlPath(childRawReqPath); hasChildAlias {
-				h.visitTreeNode(childAlias.fs, childRawReqPath, childRelPath, true, childChildSelections, archiveCallback)
+				if h.isDropbox(childRawReqPath, childAlias.fs) {
+					continue
+				}
+				h.visitTreeNode(r, childAlias.fs, childRawReqPath, childRelPath, true, authUserName, childChildSelections, archiveCallback)
 			} else {
-				h.visitTreeNode(childFsPath, childRawReqPath, childRelPath, statNode, childChildSelections, archiveCallback)
+				if h.isDropbox(childRawReqPath, childFsPath) {
+					continue
+				}
+				h.visitTreeNode(r, childFsPath, childRawReqPath, childRelPath, statNode, authUserName, childChildSelections, archiveCallback)
 			}
 		}
 	}
@@ -145,10 +154,12 @@ func (h *aliasHandler) archive(
 	}
 
 	h.visitTreeNode(
+		r,
 		path.Clean(h.root+pageData.handlerReqPath),
 		pageData.rawReqPath,
 		"",
 		pageData.Item != nil, // not empty root
+		pageData.AuthUserName,
 		selections,
 		func(f *os.File, fInfo os.FileInfo, relPath string) error {
 			h.logArchive(targetFilename, relPath, r)
@@ -2,37 +2,133 @@ package serverHandler
 
 import (
 	"errors"
+	"mjpclab.dev/ghfs/src/user"
+	"mjpclab.dev/ghfs/src/util"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
 const authQueryParam = "auth"
 
-func (h *aliasHandler) needAuth(rawQuery, rawReqPath, reqFsPath string) (need, force bool) {
+// needAuth checks if anonymous user is denied to access by rules that depend on user.
+func (h *aliasHandler) needAuth(r *http.Request, rawQuery, rawReqPath, reqFsPath string) (need, force bool) {
 	if strings.HasPrefix(rawQuery, authQueryParam) {
 		return true, true
 	}
 
-	if h.globalAuth {
-		return true, false
+	decision := h.policy.decide(policyCapAccess, newPolicySubject(r, rawReqPath, reqFsPath, ""))
+	return !decision.allow && decision.userDependent, false
+}
+
+func (h *aliasHandler) isUserAllowed(r *http.Request, rawReqPath, reqFsPath, username string) bool {
+	return h.policy.allows(policyCapAccess, newPolicySubject(r, rawReqPath, reqFsPath, username))
+}
+
+// isAuthorized checks if user or token that authenticated the request can access another path,
+// without side effects of verifyAuth, e.g. counting auth failures.
+func (h *aliasHandler) isAuthorized(r *http.Request, rawReqPath, reqFsPath, username string, token *user.Token) bool {
+	if token != nil && !token.AllowsPath(rawReqPath) {
+		return false
+	}
+	return h.isUserAllowed(r, rawReqPath, reqFsPath, username)
+}
+
+// filterAllowedItems removes sub items that user is not allowed to access.
+// For anonymous user, items that any authenticated user can access are kept, so that user can log in to access them.
+func (h *aliasHandler) filterAllowedItems(r *http.Request, rawReqPath, reqFsPath string, items []os.FileInfo, username string) []os.FileInfo {
+	if !h.policy.has(policyCapAccess) {
+		return items
 	}
 
-	return hasUrlOrDirPrefix(h.authUrls, rawReqPath, h.authDirs, reqFsPath), false
+	filtered := items[:0]
+	for _, item := range items {
+		childRawReqPath := util.CleanUrlPath(rawReqPath + "/" + item.Name())
+		childFsPath := filepath.Join(reqFsPath, item.Name())
+		if childAlias, hasChildAlias := h.aliases.byUrlPath(childRawReqPath); hasChildAlias {
+			childFsPath = childAlias.fs
+		}
+		subject := newPolicySubject(r, childRawReqPath, childFsPath, username)
+		subject.authenticated = true
+		if h.policy.allows(policyCapAccess, subject) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
 }
 
 func (h *aliasHandler) notifyAuth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("WWW-Authenticate", "Basic realm=\"files\"")
 }
 
-func (h *aliasHandler) verifyAuth(r *http.Request, needAuth bool) (username string, success bool, err error) {
+// verifyAuth authenticates request by Basic Auth, API token, TLS client certificate, login session or share link in order.
+// User provided by forward auth takes precedence over all of them.
+// Returned token is the scope of request authenticated by API token or share link.
+func (h *aliasHandler) verifyAuth(r *http.Request, needAuth bool, rawReqPath, reqFsPath string) (username string, token *user.Token, success bool, err error) {
+	if username, ok := getForwardAuthUser(r); ok {
+		if !h.isUserAllowed(r, rawReqPath, reqFsPath, username) {
+			return username, nil, false, errors.New(r.RemoteAddr + " user " + username + " not allowed")
+		}
+		return username, nil, true, nil
+	}
+
 	user, pass, hasAuthReq := r.BasicAuth()
+	bearer, hasBearer := "", false
+	if !hasAuthReq {
+		bearer, hasBearer = getBearerToken(r)
+	}
+
+	// credentials that can be guessed
+	guessing := hasAuthReq || hasBearer || (h.share && len(r.URL.Query().Get(shareKeyQueryParam)) > 0)
+	if guessing {
+		if err = h.checkAuthLockout(r, user); err != nil {
+			return "", nil, false, err
+		}
+	}
+
+	authenticated := hasAuthReq && h.users.Auth(user, pass)
+	if !hasAuthReq {
+		if hasBearer {
+			hasAuthReq = true
+			token = h.tokens.Auth(bearer)
+		} else if user, authenticated = h.getClientCertUser(r); authenticated {
+			hasAuthReq = true
+		} else if h.formLogin || h.oidc != nil {
+			user, authenticated = h.getSessionUser(r)
+			hasAuthReq = authenticated
+		}
+		if !hasAuthReq && h.share {
+			token, hasAuthReq = h.getShareToken(r, rawReqPath)
+			if token != nil {
+				h.logMutate(token.Name, "share access", rawReqPath, r)
+			}
+		}
+		if token != nil {
+			user, authenticated = token.Name, true
+		}
+	}
+
+	if guessing {
+		if authenticated {
+			h.resetAuthFailure(user)
+		} else {
+			h.addAuthFailure(r, user)
+		}
+	}
 
-	if hasAuthReq && h.users.Auth(user, pass) {
-		return user, true, nil
+	if authenticated {
+		if token != nil && !token.AllowsPath(rawReqPath) {
+			return user, token, false, errors.New(r.RemoteAddr + " token " + user + " not allowed")
+		}
+		if !h.isUserAllowed(r, rawReqPath, reqFsPath, user) {
+			return user, token, false, errors.New(r.RemoteAddr + " user " + user + " not allowed")
+		}
+		return user, token, true, nil
 	}
 
 	if !needAuth {
-		return "", true, nil
+		return "", nil, true, nil
 	}
 
 	if !hasAuthReq {
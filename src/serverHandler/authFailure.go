@@ -0,0 +1,176 @@
+package serverHandler
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const authFailMaxDelay = 5 * time.Second
+
+type authLockedError struct {
+	remoteAddr string
+	until      time.Time
+}
+
+func (e *authLockedError) Error() string {
+	return e.remoteAddr + " auth locked out until " + e.until.Format(time.RFC3339)
+}
+
+type authFailure struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// authFailures tracks failed authentications per remote ip and per username,
+// failures are forgotten after lockout time since the last one.
+type authFailures struct {
+	delayAfter   int
+	lockoutAfter int
+	lockoutTime  time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*authFailure
+}
+
+func newAuthFailures(delayAfter, lockoutAfter int, lockoutTime time.Duration) *authFailures {
+	return &authFailures{
+		delayAfter:   delayAfter,
+		lockoutAfter: lockoutAfter,
+		lockoutTime:  lockoutTime,
+		failures:     map[string]*authFailure{},
+	}
+}
+
+func getRemoteIp(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+func getAuthFailureKeys(ip, username string) []string {
+	keys := []string{"ip:" + ip}
+	if len(username) > 0 {
+		keys = append(keys, "user:"+strings.ToLower(username))
+	}
+	return keys
+}
+
+func (fails *authFailures) enabled() bool {
+	return fails.delayAfter > 0 || fails.lockoutAfter > 0
+}
+
+func (fails *authFailures) removeExpired(now time.Time) {
+	for key, failure := range fails.failures {
+		if now.After(failure.lockedUntil) && now.Sub(failure.last) >= fails.lockoutTime {
+			delete(fails.failures, key)
+		}
+	}
+}
+
+// lockedUntil gets the end time of lockout of remote ip or username, zero if not locked out.
+func (fails *authFailures) lockedUntil(ip, username string, now time.Time) (until time.Time) {
+	if fails.lockoutAfter <= 0 {
+		return
+	}
+
+	fails.mu.Lock()
+	defer fails.mu.Unlock()
+
+	for _, key := range getAuthFailureKeys(ip, username) {
+		if failure := fails.failures[key]; failure != nil && now.Before(failure.lockedUntil) && failure.lockedUntil.After(until) {
+			until = failure.lockedUntil
+		}
+	}
+	return
+}
+
+// add records a failed authentication,
+// returns delay before responding, and keys that become locked out.
+func (fails *authFailures) add(ip, username string, now time.Time) (delay time.Duration, lockedKeys []string) {
+	fails.mu.Lock()
+	defer fails.mu.Unlock()
+
+	fails.removeExpired(now)
+
+	maxCount := 0
+	for _, key := range getAuthFailureKeys(ip, username) {
+		failure := fails.failures[key]
+		if failure == nil {
+			failure = &authFailure{}
+			fails.failures[key] = failure
+		}
+		failure.count++
+		failure.last = now
+		if failure.count > maxCount {
+			maxCount = failure.count
+		}
+
+		if fails.lockoutAfter > 0 && failure.count >= fails.lockoutAfter {
+			failure.count = 0
+			failure.lockedUntil = now.Add(fails.lockoutTime)
+			lockedKeys = append(lockedKeys, key)
+		}
+	}
+
+	if len(lockedKeys) == 0 && fails.delayAfter > 0 && maxCount > fails.delayAfter {
+		delay = time.Duration(maxCount-fails.delayAfter) * time.Second
+		if delay > authFailMaxDelay {
+			delay = authFailMaxDelay
+		}
+	}
+	return
+}
+
+// reset clears failures of username after it is authenticated successfully.
+func (fails *authFailures) reset(username string) {
+	fails.mu.Lock()
+	defer fails.mu.Unlock()
+
+	delete(fails.failures, "user:"+strings.ToLower(username))
+}
+
+// checkAuthLockout returns error if remote ip or username is locked out.
+func (h *aliasHandler) checkAuthLockout(r *http.Request, username string) error {
+	if !h.authFailures.enabled() {
+		return nil
+	}
+	if until := h.authFailures.lockedUntil(getRemoteIp(r.RemoteAddr), username, time.Now()); !until.IsZero() {
+		return &authLockedError{r.RemoteAddr, until}
+	}
+	return nil
+}
+
+// addAuthFailure records failed authentication, logs lockout in a fail2ban friendly format,
+// and delays the response if failed too many times.
+func (h *aliasHandler) addAuthFailure(r *http.Request, username string) {
+	if !h.authFailures.enabled() {
+		return
+	}
+
+	ip := getRemoteIp(r.RemoteAddr)
+	delay, lockedKeys := h.authFailures.add(ip, username, time.Now())
+	for _, key := range lockedKeys {
+		h.logger.LogErrorString("auth lockout: host=" + ip + " user=" + strconv.Quote(username) + " target=" + key[:strings.IndexByte(key, ':')] +
+			" duration=" + strconv.Itoa(int(h.authFailures.lockoutTime/time.Second)) + "s")
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+func (h *aliasHandler) resetAuthFailure(username string) {
+	if h.authFailures.enabled() && len(username) > 0 {
+		h.authFailures.reset(username)
+	}
+}
+
+func setRetryAfter(w http.ResponseWriter, until time.Time) {
+	seconds := int(time.Until(until)/time.Second) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
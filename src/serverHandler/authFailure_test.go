@@ -0,0 +1,67 @@
+package serverHandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuthFailures(t *testing.T) {
+	fails := newAuthFailures(2, 4, time.Minute)
+	now := time.Unix(1000000000, 0)
+
+	// delay
+	for i := 1; i <= 2; i++ {
+		if delay, locked := fails.add("1.2.3.4", "alice", now); delay != 0 || len(locked) != 0 {
+			t.Error(i, delay, locked)
+		}
+	}
+	if delay, locked := fails.add("1.2.3.4", "ALICE", now); delay != time.Second || len(locked) != 0 {
+		t.Error(delay, locked)
+	}
+	if !fails.lockedUntil("1.2.3.4", "alice", now).IsZero() {
+		t.Error("should not be locked")
+	}
+
+	// lockout
+	if _, locked := fails.add("1.2.3.4", "alice", now); len(locked) != 2 {
+		t.Error(locked)
+	}
+	if until := fails.lockedUntil("5.6.7.8", "alice", now); !until.Equal(now.Add(time.Minute)) {
+		t.Error("user should be locked", until)
+	}
+	if until := fails.lockedUntil("1.2.3.4", "", now); !until.Equal(now.Add(time.Minute)) {
+		t.Error("ip should be locked", until)
+	}
+	if !fails.lockedUntil("5.6.7.8", "bob", now).IsZero() {
+		t.Error("others should not be locked")
+	}
+	if !fails.lockedUntil("1.2.3.4", "alice", now.Add(time.Minute)).IsZero() {
+		t.Error("lockout should be expired")
+	}
+
+	// reset
+	fails.add("5.6.7.8", "bob", now)
+	fails.reset("Bob")
+	if _, ok := fails.failures["user:bob"]; ok {
+		t.Error("bob should be reset")
+	}
+
+	// forget
+	fails.add("5.6.7.8", "", now.Add(2*time.Minute))
+	if len(fails.failures) != 1 || fails.failures["ip:5.6.7.8"].count != 1 {
+		t.Error(fails.failures)
+	}
+}
+
+func TestGetRemoteIp(t *testing.T) {
+	for input, expect := range map[string]string{
+		"1.2.3.4:5678":   "1.2.3.4",
+		"[::1]:5678":     "::1",
+		"@":              "@",
+		"/tmp/ghfs.sock": "/tmp/ghfs.sock",
+	} {
+		if ip := getRemoteIp(input); ip != expect {
+			t.Error(input, ip)
+		}
+	}
+}
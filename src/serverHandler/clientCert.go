@@ -0,0 +1,41 @@
+package serverHandler
+
+import (
+	"crypto/x509"
+	"net/http"
+)
+
+// getCertNames gets subject common name and subject alternative names of certificate.
+func getCertNames(cert *x509.Certificate) []string {
+	names := make([]string, 0, 1+len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.IPAddresses)+len(cert.URIs))
+	if len(cert.Subject.CommonName) > 0 {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// getClientCertUser maps verified TLS client certificate to username,
+// by the first mapping that matches its subject CN or SAN.
+func (h *aliasHandler) getClientCertUser(r *http.Request) (username string, ok bool) {
+	if len(h.clientCertUsers) == 0 || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return
+	}
+
+	names := getCertNames(r.TLS.VerifiedChains[0][0])
+	for _, mapping := range h.clientCertUsers {
+		for _, name := range names {
+			if name == mapping[0] {
+				return mapping[1], true
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,46 @@
+package serverHandler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetClientCertUser(t *testing.T) {
+	h := &aliasHandler{clientCertUsers: [][2]string{
+		{"mirror1.example.com", "machine1"},
+		{"mirror2", "machine2"},
+	}}
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "mirror1"},
+		DNSNames: []string{"mirror1.example.com"},
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, ok := h.getClientCertUser(r); ok {
+		t.Error("plain request should not have cert user")
+	}
+
+	r.TLS = &tls.ConnectionState{}
+	if _, ok := h.getClientCertUser(r); ok {
+		t.Error("unverified certificate should not have cert user")
+	}
+
+	r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	if username, ok := h.getClientCertUser(r); !ok || username != "machine1" {
+		t.Error(username, ok)
+	}
+
+	cert.Subject.CommonName = "mirror2"
+	cert.DNSNames = nil
+	if username, ok := h.getClientCertUser(r); !ok || username != "machine2" {
+		t.Error(username, ok)
+	}
+
+	cert.Subject.CommonName = "mirror3"
+	if username, ok := h.getClientCertUser(r); ok {
+		t.Error(username)
+	}
+}
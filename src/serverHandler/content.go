@@ -3,8 +3,14 @@ package serverHandler
 import (
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 )
 
+func getETag(info os.FileInfo) string {
+	return `"` + strconv.FormatInt(info.ModTime().UnixNano(), 16) + "-" + strconv.FormatInt(info.Size(), 16) + `"`
+}
+
 func (h *aliasHandler) content(w http.ResponseWriter, r *http.Request, data *responseData) {
 	header := w.Header()
 	header.Set("Vary", h.vary)
@@ -15,6 +21,7 @@ func (h *aliasHandler) content(w http.ResponseWriter, r *http.Request, data *res
 
 	item := data.Item
 	file := data.File
+	header.Set("ETag", getETag(item))
 
 	http.ServeContent(w, r, item.Name(), item.ModTime(), file)
 }
@@ -0,0 +1,168 @@
+package serverHandler
+
+import (
+	"errors"
+	"fmt"
+	"mjpclab.dev/ghfs/src/user"
+	"mjpclab.dev/ghfs/src/util"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+type copyResult struct {
+	Name    string `json:"name"`
+	To      string `json:"to,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+type copyTarget struct {
+	rawReqPath string
+	fsPath     string
+	info       os.FileInfo
+	canMkdir   bool
+}
+
+// getCopyTarget resolves destination directory to the alias that serves it,
+// which may differ from current alias, and checks if it is writable.
+func (h *aliasHandler) getCopyTarget(authUserName string, authToken *user.Token, currentRawReqPath, to string, r *http.Request) (target *copyTarget, err error) {
+	destRawReqPath := to
+	if len(destRawReqPath) == 0 || destRawReqPath[0] != '/' {
+		destRawReqPath = path.Join(currentRawReqPath, destRawReqPath)
+	}
+	destRawReqPath = util.CleanUrlPath(destRawReqPath)
+
+	alias, ok := h.allAliases.byReqPath(destRawReqPath)
+	if !ok {
+		return nil, errors.New("copy: target not writable " + destRawReqPath)
+	}
+	destFsPath := filepath.Clean(alias.fs + util.CleanUrlPath(destRawReqPath[len(alias.url):]))
+
+	if !h.canMutate(r, destRawReqPath, destFsPath) {
+		return nil, errors.New("copy: target blocked by rule " + destRawReqPath)
+	}
+
+	if !h.isAuthorized(r, destRawReqPath, destFsPath, authUserName, authToken) {
+		return nil, errors.New("copy: target not authorized " + destRawReqPath)
+	}
+
+	if !tokenAllows(authToken, user.TokenPermUpload) {
+		return nil, errors.New("copy: target not writable " + destRawReqPath)
+	}
+
+	destInfo, _ := os.Stat(destFsPath)
+	if !h.getCanUpload(r, destInfo, destRawReqPath, destFsPath, authUserName) {
+		return nil, errors.New("copy: target not writable " + destRawReqPath)
+	}
+
+	return &copyTarget{
+		rawReqPath: destRawReqPath,
+		fsPath:     destFsPath,
+		info:       destInfo,
+		canMkdir:   h.getCanMkdir(r, destInfo, destRawReqPath, destFsPath, authUserName),
+	}, nil
+}
+
+// isShadowedBy reports whether name under target directory is shadowed by an alias.
+func (target *copyTarget) isShadowedBy(aliases aliases, name string) bool {
+	for _, alias := range aliases {
+		if subName, _, ok := alias.nextPartOf(target.rawReqPath); ok && subName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *aliasHandler) copyItem(authUserName string, authToken *user.Token, rawReqPath, fsPrefix, inputName string, target *copyTarget, aliasSubItems []os.FileInfo, r *http.Request) (result copyResult, errs []error) {
+	result.Name = inputName
+
+	name, ok := getCleanFilePath(inputName)
+	if !ok {
+		result.Error = "illegal name"
+		return result, []error{errors.New("copy: illegal item name " + inputName)}
+	}
+	if containsItem(aliasSubItems, name) {
+		result.Error = "shadowed by alias"
+		return result, []error{errors.New("copy: ignore item shadowed by alias " + name)}
+	}
+
+	fsPath := filepath.Join(fsPrefix, name)
+	itemRawReqPath := path.Join(rawReqPath, name)
+	if h.containsDropbox(itemRawReqPath, fsPath) {
+		result.Error = "not readable"
+		return result, []error{errors.New("copy: ignore item containing drop box " + fsPath)}
+	}
+
+	info, err := os.Lstat(fsPath)
+	if err != nil || len(h.FilterItems([]os.FileInfo{info})) == 0 {
+		result.Error = "not found"
+		if err == nil {
+			err = errors.New("copy: ignore hidden item " + fsPath)
+		}
+		return result, []error{err}
+	}
+	if !h.isAuthorized(r, itemRawReqPath, fsPath, authUserName, authToken) {
+		result.Error = "not authorized"
+		return result, []error{errors.New("copy: item not authorized " + fsPath)}
+	}
+
+	if info.IsDir() {
+		if !target.canMkdir {
+			result.Error = "mkdir not allowed"
+			return result, []error{errors.New("copy: mkdir is not enabled for " + target.fsPath)}
+		}
+		if util.HasFsPrefixDir(target.fsPath, fsPath) {
+			result.Error = "copy into itself"
+			return result, []error{errors.New("copy: cannot copy into itself " + fsPath)}
+		}
+		if err = h.checkSubItemsReadable(r, itemRawReqPath, fsPath, authUserName, authToken); err != nil {
+			result.Error = "contains restricted item"
+			return result, []error{errors.New("copy: " + err.Error())}
+		}
+	}
+
+	destName := getAvailableFilename(target.fsPath, name, target.isShadowedBy(h.allAliases, name) || h.isDropbox(target.rawReqPath, target.fsPath))
+	if !info.IsDir() {
+		if err = h.checkUploadFilePath(destName); err != nil {
+			result.Error = err.Error()
+			return result, []error{fmt.Errorf("copy: %w %s", err, destName)}
+		}
+	}
+	destFsPath := filepath.Join(target.fsPath, destName)
+	h.logMutate(authUserName, "copy", fsPath+" -> "+destFsPath, r)
+	errs = copyFsItem(fsPath, destFsPath, true)
+	if len(errs) > 0 {
+		result.Error = "copy failed"
+		return
+	}
+
+	result.To = path.Join(target.rawReqPath, destName)
+	result.Success = true
+	return
+}
+
+func (h *aliasHandler) copyItems(authUserName string, authToken *user.Token, rawReqPath, fsPrefix string, names []string, to string, aliasSubItems []os.FileInfo, r *http.Request) (results []copyResult, success bool) {
+	target, err := h.getCopyTarget(authUserName, authToken, rawReqPath, to, r)
+	if h.logError(err) {
+		return nil, false
+	}
+
+	var errs []error
+	results = make([]copyResult, 0, len(names))
+	for _, name := range names {
+		if len(name) == 0 {
+			continue
+		}
+		result, itemErrs := h.copyItem(authUserName, authToken, rawReqPath, fsPrefix, name, target, aliasSubItems, r)
+		results = append(results, result)
+		errs = append(errs, itemErrs...)
+	}
+
+	if h.logErrors(errs) {
+		return results, false
+	}
+
+	return results, true
+}
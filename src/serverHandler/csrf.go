@@ -0,0 +1,103 @@
+package serverHandler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"mjpclab.dev/ghfs/src/util"
+	"net/http"
+	"strings"
+)
+
+const csrfCookieName = "ghfs_csrf"
+const csrfQueryParam = "csrf"
+const csrfHeaderName = "X-Csrf-Token"
+
+// newCsrfToken signs token for browser session id and user.
+func newCsrfToken(key []byte, sessionId, username string) string {
+	return base64.RawURLEncoding.EncodeToString(signSession(key, "csrf."+sessionId+"."+username))
+}
+
+// getCsrfToken gets CSRF token of current browser session,
+// session id is generated and saved into cookie if not exists.
+func (h *aliasHandler) getCsrfToken(w http.ResponseWriter, r *http.Request, username string) string {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && len(cookie.Value) > 0 {
+		return newCsrfToken(h.sessionKey, cookie.Value, username)
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); h.logError(err) {
+		return ""
+	}
+	sessionId := base64.RawURLEncoding.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    sessionId,
+		Path:     "/",
+		Secure:   isHttps(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return newCsrfToken(h.sessionKey, sessionId, username)
+}
+
+// getRequestCsrfToken gets CSRF token from request header, query string or url-encoded form.
+// Multipart form is not parsed, since its body is consumed by uploading,
+// neither is body of other methods than POST, which could be content of PUT file.
+func getRequestCsrfToken(r *http.Request) string {
+	if token := r.Header.Get(csrfHeaderName); len(token) > 0 {
+		return token
+	}
+	if token := r.URL.Query().Get(csrfQueryParam); len(token) > 0 {
+		return token
+	}
+	if r.Method == http.MethodPost && r.ParseForm() == nil {
+		return r.PostForm.Get(csrfQueryParam)
+	}
+	return ""
+}
+
+// isFromBrowser checks if request carries anything a browser sends automatically,
+// requests from command line tools and scripts usually have none of them.
+func isFromBrowser(r *http.Request) bool {
+	header := r.Header
+	return len(header.Get("Sec-Fetch-Site")) > 0 || len(header.Get("Origin")) > 0 || len(header.Get("Cookie")) > 0
+}
+
+// checkCsrf rejects cross site mutating requests by "Sec-Fetch-Site" and "Origin" headers,
+// and requires CSRF token for requests from browser.
+// Requests authenticated by bearer token are exempt, unless session cookie is also sent,
+// which browser attaches automatically.
+func (h *aliasHandler) checkCsrf(r *http.Request, data *responseData) error {
+	_, sessionErr := r.Cookie(sessionCookieName)
+	if data.authToken != nil && sessionErr != nil {
+		if _, ok := getBearerToken(r); ok {
+			return nil
+		}
+	}
+
+	switch fetchSite := r.Header.Get("Sec-Fetch-Site"); fetchSite {
+	case "", "same-origin", "none":
+	default:
+		return errors.New(r.RemoteAddr + " csrf: reject " + fetchSite + " request " + data.rawReqPath)
+	}
+
+	if origin := r.Header.Get("Origin"); len(origin) > 0 && util.ExtractHostFromUrl(origin) != strings.ToLower(r.Host) {
+		return errors.New(r.RemoteAddr + " csrf: reject request from origin " + origin + " " + data.rawReqPath)
+	}
+
+	if !isFromBrowser(r) {
+		return nil
+	}
+
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || len(cookie.Value) == 0 {
+		return errors.New(r.RemoteAddr + " csrf: missing session " + data.rawReqPath)
+	}
+	token := getRequestCsrfToken(r)
+	if !hmac.Equal([]byte(token), []byte(newCsrfToken(h.sessionKey, cookie.Value, data.AuthUserName))) {
+		return errors.New(r.RemoteAddr + " csrf: invalid token " + data.rawReqPath)
+	}
+	return nil
+}
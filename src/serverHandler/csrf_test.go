@@ -0,0 +1,105 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/user"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckCsrf(t *testing.T) {
+	h := &aliasHandler{sessionKey: []byte("key")}
+	data := &responseData{rawReqPath: "/", AuthUserName: "alice"}
+
+	w := httptest.NewRecorder()
+	token := h.getCsrfToken(w, httptest.NewRequest(http.MethodGet, "/", nil), "alice")
+	cookies := w.Result().Cookies()
+	if len(token) == 0 || len(cookies) != 1 || cookies[0].Name != csrfCookieName {
+		t.Fatal(token, cookies)
+	}
+	cookie := cookies[0]
+
+	newRequest := func(body string, headers map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/?mkdir", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for key, value := range headers {
+			r.Header.Set(key, value)
+		}
+		return r
+	}
+
+	// not from browser
+	if err := h.checkCsrf(newRequest("name=a", nil), data); err != nil {
+		t.Error(err)
+	}
+
+	// token from form, header
+	r := newRequest("name=a&csrf="+token, nil)
+	r.AddCookie(cookie)
+	if err := h.checkCsrf(r, data); err != nil {
+		t.Error(err)
+	}
+	r = newRequest("name=a", map[string]string{csrfHeaderName: token, "Sec-Fetch-Site": "same-origin"})
+	r.AddCookie(cookie)
+	if err := h.checkCsrf(r, data); err != nil {
+		t.Error(err)
+	}
+
+	// token of other user or session
+	r = newRequest("name=a&csrf="+token, nil)
+	r.AddCookie(cookie)
+	if err := h.checkCsrf(r, &responseData{rawReqPath: "/", AuthUserName: "bob"}); err == nil {
+		t.Error("token of other user should be rejected")
+	}
+	r = newRequest("name=a&csrf="+token, nil)
+	r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "other"})
+	if err := h.checkCsrf(r, data); err == nil {
+		t.Error("token of other session should be rejected")
+	}
+
+	// missing token
+	r = newRequest("name=a", map[string]string{"Origin": "http://example.com"})
+	r.AddCookie(cookie)
+	if err := h.checkCsrf(r, data); err == nil {
+		t.Error("missing token should be rejected")
+	}
+
+	// cross site
+	r = newRequest("name=a&csrf="+token, map[string]string{"Sec-Fetch-Site": "cross-site"})
+	r.AddCookie(cookie)
+	if err := h.checkCsrf(r, data); err == nil {
+		t.Error("cross site request should be rejected")
+	}
+	if err := h.checkCsrf(newRequest("name=a", map[string]string{"Origin": "http://evil.example"}), data); err == nil {
+		t.Error("request from other origin should be rejected")
+	}
+
+	// session cookie without origin
+	r = newRequest("name=a", nil)
+	r.AddCookie(cookie)
+	r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "session"})
+	if err := h.checkCsrf(r, data); err == nil {
+		t.Error("missing token with session cookie should be rejected")
+	}
+
+	// bearer token
+	r = newRequest("name=a", map[string]string{"Authorization": "Bearer secret", "Origin": "http://evil.example"})
+	if err := h.checkCsrf(r, &responseData{rawReqPath: "/", authToken: &user.Token{}}); err != nil {
+		t.Error(err)
+	}
+	r = newRequest("name=a", map[string]string{"Authorization": "Bearer secret"})
+	r.AddCookie(cookie)
+	r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "session"})
+	if err := h.checkCsrf(r, &responseData{rawReqPath: "/", authToken: &user.Token{}}); err == nil {
+		t.Error("bearer token with session cookie should not be exempt")
+	}
+
+	// body of PUT is not parsed
+	r = httptest.NewRequest(http.MethodPut, "http://example.com/a.txt", strings.NewReader("csrf="+token))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(cookie)
+	if err := h.checkCsrf(r, data); err == nil {
+		t.Error("token in PUT body should not be accepted")
+	}
+}
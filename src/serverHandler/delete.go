@@ -4,10 +4,11 @@ import (
 	"errors"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 )
 
-func (h *aliasHandler) deleteItems(authUserName, fsPrefix string, files []string, aliasSubItems []os.FileInfo, r *http.Request) bool {
+func (h *aliasHandler) deleteItems(authUserName, rawReqPath, fsPrefix string, files []string, aliasSubItems []os.FileInfo, r *http.Request) bool {
 	var errs []error
 
 	for _, inputFilename := range files {
@@ -21,10 +22,14 @@ func (h *aliasHandler) deleteItems(authUserName, fsPrefix string, files []string
 			errs = append(errs, errors.New("delete: illegal item name "+inputFilename))
 			continue
 		}
-		if containsItem(aliasSubItems, filename) {
+		if containsItem(aliasSubItems, filename) || isReservedName(filename) {
 			continue
 		}
 		fsPath := filepath.Join(fsPrefix, filename)
+		if h.containsDropbox(path.Join(rawReqPath, filename), fsPath) {
+			errs = append(errs, errors.New("delete: ignore item containing drop box "+fsPath))
+			continue
+		}
 		h.logMutate(authUserName, "delete", fsPath, r)
 		err := os.RemoveAll(fsPath)
 		if err != nil {
@@ -0,0 +1,46 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/util"
+)
+
+// isDropbox checks if path is in a write-only drop box,
+// whose items cannot be listed or read, and uploaded files are always renamed.
+func (h *aliasHandler) isDropbox(rawReqPath, reqFsPath string) bool {
+	for _, dropboxUrl := range h.dropboxUrls {
+		if util.HasUrlPrefixDir(rawReqPath, dropboxUrl) {
+			return true
+		}
+	}
+	for _, dropboxDir := range h.dropboxDirs {
+		if util.HasFsPrefixDir(reqFsPath, dropboxDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsDropbox checks if path is in a drop box, or any drop box lies under it,
+// including drop boxes mapped into the path by aliases.
+// Such a tree cannot be archived, copied, moved or deleted as a whole.
+func (h *aliasHandler) containsDropbox(rawReqPath, reqFsPath string) bool {
+	if h.isDropbox(rawReqPath, reqFsPath) {
+		return true
+	}
+	for _, dropboxUrl := range h.dropboxUrls {
+		if util.HasUrlPrefixDir(dropboxUrl, rawReqPath) {
+			return true
+		}
+	}
+	for _, dropboxDir := range h.dropboxDirs {
+		if util.HasFsPrefixDir(dropboxDir, reqFsPath) {
+			return true
+		}
+	}
+	for _, alias := range h.aliases {
+		if alias.isSuccessorOf(rawReqPath) && h.containsDropbox(alias.url, alias.fs) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package serverHandler
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+)
+
+const explainQueryParam = "explain"
+
+// explain outputs decision of each capability for current request,
+// and the rule that decides it.
+func (h *aliasHandler) explain(w http.ResponseWriter, r *http.Request, data *responseData, fsPath string) {
+	subject := newPolicySubject(r, data.rawReqPath, fsPath, data.AuthUserName)
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("url: " + subject.urlPath + "\n")
+	buf.WriteString("fs: " + subject.fsPath + "\n")
+	if subject.authenticated {
+		buf.WriteString("user: " + subject.username + "\n")
+	} else {
+		buf.WriteString("user: (anonymous)\n")
+	}
+	buf.WriteString("method: " + subject.method + "\n")
+	buf.WriteString("ip: " + getRemoteIp(r.RemoteAddr) + "\n")
+	if len(subject.referer) > 0 {
+		buf.WriteString("referer: " + subject.referer + "\n")
+	} else {
+		buf.WriteString("referer: (none)\n")
+	}
+	buf.WriteString("\n")
+
+	for _, capName := range policyCapNames {
+		decision := h.policy.decide(capName.cap, subject)
+		buf.WriteString(capName.name + ": ")
+		if decision.allow {
+			buf.WriteString("allow")
+		} else {
+			buf.WriteString("deny")
+		}
+		if decision.rule != nil {
+			buf.WriteString(" by rule #" + strconv.Itoa(decision.index+1) + " " + decision.rule.String() + " (" + decision.rule.origin + ")")
+		} else {
+			buf.WriteString(" by default")
+		}
+		buf.WriteByte('\n')
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if NeedResponseBody(r.Method) {
+		w.Write(buf.Bytes())
+	}
+}
@@ -1,6 +1,39 @@
 package serverHandler
 
-import "os"
+import (
+	"os"
+	"strings"
+)
+
+// isReservedName reports whether name is used internally by server,
+// like staging area of resumable uploads, or temporary file of uploading.
+func isReservedName(name string) bool {
+	return name == tusStagingDir || isTempFileName(name)
+}
+
+// hasReservedName reports whether any segment of slash separated path is reserved,
+// such path cannot be created or written by clients.
+func hasReservedName(filePath string) bool {
+	for _, segment := range strings.Split(filePath, "/") {
+		if isReservedName(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInternalItem(item os.FileInfo) bool {
+	return isReservedName(item.Name())
+}
+
+func hasInternalItem(items []os.FileInfo) bool {
+	for _, item := range items {
+		if isInternalItem(item) {
+			return true
+		}
+	}
+	return false
+}
 
 func (h *aliasHandler) FilterItems(items []os.FileInfo) []os.FileInfo {
 	if h.shows == nil &&
@@ -8,7 +41,8 @@ func (h *aliasHandler) FilterItems(items []os.FileInfo) []os.FileInfo {
 		h.showFiles == nil &&
 		h.hides == nil &&
 		h.hideDirs == nil &&
-		h.hideFiles == nil {
+		h.hideFiles == nil &&
+		!hasInternalItem(items) {
 		return items
 	}
 
@@ -17,6 +51,10 @@ func (h *aliasHandler) FilterItems(items []os.FileInfo) []os.FileInfo {
 	for _, item := range items {
 		name := item.Name()
 
+		if isInternalItem(item) {
+			continue
+		}
+
 		if h.hides != nil && h.hides.MatchString(name) {
 			continue
 		}
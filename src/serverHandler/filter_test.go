@@ -97,3 +97,20 @@ func TestHandler_FilterItems(t *testing.T) {
 	}
 
 }
+
+func TestHandler_FilterItemsInternal(t *testing.T) {
+	now := time.Now()
+	h := &aliasHandler{}
+
+	dir1 := dummyFileInfo{"dir1", 0, now, true}
+	file1 := dummyFileInfo{"file1", 0, now, false}
+	staging := dummyFileInfo{tusStagingDir, 0, now, true}
+	temp := dummyFileInfo{tempFilePrefix + "0123456789abcdef", 0, now, false}
+	stagingFile := dummyFileInfo{tusStagingDir, 0, now, false}
+	tempDir := dummyFileInfo{tempFilePrefix + "dir", 0, now, true}
+
+	items := h.FilterItems([]os.FileInfo{dir1, staging, file1, temp, stagingFile, tempDir})
+	if !expectItems(items, dir1, file1) {
+		t.Errorf("%+v\n", items)
+	}
+}
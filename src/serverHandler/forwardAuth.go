@@ -0,0 +1,117 @@
+package serverHandler
+
+import (
+	"context"
+	"io"
+	"mjpclab.dev/ghfs/src/serverLog"
+	"net/http"
+	"time"
+)
+
+// max size of response body from authorizer that passed back to client
+const forwardAuthMaxBodySize = 64 * 1024
+
+// headers of authorizer's denial response that passed back to client
+var forwardAuthDenyHeaders = []string{"Cache-Control", "Content-Type", "Location", "Set-Cookie", "WWW-Authenticate"}
+
+type forwardAuthUserKey struct{}
+
+// forwardAuth authorizes requests by subrequest to an external endpoint, like nginx `auth_request`.
+type forwardAuth struct {
+	url        string
+	userHeader string
+	client     *http.Client
+}
+
+func newForwardAuth(url, userHeader string) *forwardAuth {
+	if len(url) == 0 {
+		return nil
+	}
+	return &forwardAuth{
+		url:        url,
+		userHeader: userHeader,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (fa *forwardAuth) newRequest(r *http.Request) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, fa.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	proto := "http"
+	if isHttps(r) {
+		proto = "https"
+	}
+	uri := r.URL.RawPath // init by pathTransformHandler
+	if len(r.URL.RawQuery) > 0 {
+		uri += "?" + r.URL.RawQuery
+	}
+
+	header := req.Header
+	header.Set("X-Forwarded-Method", r.Method)
+	header.Set("X-Forwarded-Proto", proto)
+	header.Set("X-Forwarded-Host", r.Host)
+	header.Set("X-Forwarded-Uri", uri)
+	header.Set("X-Forwarded-For", getRemoteIp(r.RemoteAddr))
+	if cookie := r.Header.Get("Cookie"); len(cookie) > 0 {
+		header.Set("Cookie", cookie)
+	}
+	if authorization := r.Header.Get("Authorization"); len(authorization) > 0 {
+		header.Set("Authorization", authorization)
+	}
+	return req, nil
+}
+
+// check sends subrequest to authorizer, and returns username from its response header if allowed.
+// If authorizer denies by 401 or 403, the response is passed back to client,
+// other response or failure is responded as internal server error.
+func (fa *forwardAuth) check(w http.ResponseWriter, r *http.Request, logger *serverLog.Logger) (username string, allowed bool) {
+	req, err := fa.newRequest(r)
+	if err != nil {
+		logger.LogErrorString("forward auth: " + err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res, err := fa.client.Do(req)
+	if err != nil {
+		logger.LogErrorString("forward auth: " + err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode <= 299:
+		return res.Header.Get(fa.userHeader), true
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		header := w.Header()
+		for _, name := range forwardAuthDenyHeaders {
+			if values := res.Header.Values(name); len(values) > 0 {
+				header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		w.WriteHeader(res.StatusCode)
+		io.Copy(w, io.LimitReader(res.Body, forwardAuthMaxBodySize))
+	default:
+		logger.LogErrorString("forward auth: " + r.RemoteAddr + " " + r.URL.RawPath + " unexpected response " + res.Status)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	return
+}
+
+func withForwardAuthUser(r *http.Request, username string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), forwardAuthUserKey{}, username))
+}
+
+// getForwardAuthUser gets username provided by authorizer for current request.
+func getForwardAuthUser(r *http.Request) (username string, ok bool) {
+	username, ok = r.Context().Value(forwardAuthUserKey{}).(string)
+	return
+}
@@ -0,0 +1,63 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/serverLog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardAuth(t *testing.T) {
+	authorizer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Forwarded-Method") != http.MethodPut ||
+			r.Header.Get("X-Forwarded-Uri") != "/prefix/a/b?upload" ||
+			r.Header.Get("X-Forwarded-For") != "192.0.2.1" ||
+			r.Header.Get("X-Forwarded-Host") != "example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.Header.Get("Cookie") {
+		case "session=alice":
+			w.Header().Set("X-Auth-Request-User", "alice")
+		case "session=guest":
+		default:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("denied"))
+		}
+	}))
+	defer authorizer.Close()
+
+	fa := newForwardAuth(authorizer.URL, "X-Auth-Request-User")
+	logger, _ := serverLog.NewFileMan().NewLogger("", "")
+
+	newRequest := func(cookie string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "http://example.com/a/b?upload", nil)
+		r.URL.RawPath = "/prefix/a/b"
+		r.RemoteAddr = "192.0.2.1:1234"
+		if len(cookie) > 0 {
+			r.Header.Set("Cookie", cookie)
+		}
+		return r
+	}
+
+	w := httptest.NewRecorder()
+	if username, allowed := fa.check(w, newRequest("session=alice"), logger); !allowed || username != "alice" {
+		t.Error(username, allowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	if username, allowed := fa.check(w, newRequest("session=guest"), logger); !allowed || username != "" {
+		t.Error(username, allowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	if _, allowed := fa.check(w, newRequest(""), logger); allowed || w.Code != http.StatusForbidden || w.Header().Get("WWW-Authenticate") != "Bearer" || w.Body.String() != "denied" {
+		t.Error(allowed, w.Code, w.Header(), w.Body.String())
+	}
+
+	r := withForwardAuthUser(newRequest(""), "bob")
+	if username, ok := getForwardAuthUser(r); !ok || username != "bob" {
+		t.Error(username, ok)
+	}
+}
@@ -0,0 +1,163 @@
+package serverHandler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const tempFilePrefix = ".ghfs-upload-"
+
+func isTempFileName(name string) bool {
+	return strings.HasPrefix(name, tempFilePrefix)
+}
+
+func createTempFile(dir string) (*os.File, error) {
+	buf := make([]byte, 8)
+	for {
+		rand.Read(buf)
+		file, err := os.OpenFile(filepath.Join(dir, tempFilePrefix+hex.EncodeToString(buf)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if !os.IsExist(err) {
+			return file, err
+		}
+	}
+}
+
+// writeFsTempFile writes content into a hidden temporary file in dir,
+// the file is removed if content cannot be read completely.
+func writeFsTempFile(dir string, content io.Reader) (string, error) {
+	file, err := createTempFile(dir)
+	if err != nil {
+		return "", err
+	}
+	tempPath := file.Name()
+
+	_, err = io.Copy(file, content)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	return tempPath, nil
+}
+
+// linkFsFile gives file at oldPath the new name newPath, fails if newPath already exists.
+// Fall back to create newPath exclusively then rename onto it,
+// if file system does not support hard link.
+func linkFsFile(oldPath, newPath string) error {
+	err := os.Link(oldPath, newPath)
+	if err == nil || os.IsExist(err) {
+		return err
+	}
+
+	file, err := os.OpenFile(newPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Rename(oldPath, newPath)
+}
+
+func copyFsFile(srcPath, destPath string, srcInfo os.FileInfo) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, srcInfo.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dest, src)
+	closeErr := dest.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(destPath)
+		return err
+	}
+
+	return os.Chtimes(destPath, srcInfo.ModTime(), srcInfo.ModTime())
+}
+
+// copyFsItem copies a file, a symbol link or a whole directory tree to destPath.
+// If recursive is false, only the directory itself is created.
+func copyFsItem(srcPath, destPath string, recursive bool) (errs []error) {
+	srcInfo, err := os.Lstat(srcPath)
+	if err != nil {
+		return []error{err}
+	}
+
+	switch {
+	case srcInfo.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(srcPath)
+		if err != nil {
+			return []error{err}
+		}
+		if err = os.Symlink(target, destPath); err != nil {
+			return []error{err}
+		}
+	case srcInfo.IsDir():
+		if err = os.Mkdir(destPath, srcInfo.Mode().Perm()|0700); err != nil {
+			return []error{err}
+		}
+		if !recursive {
+			return
+		}
+
+		dir, err := os.Open(srcPath)
+		if err != nil {
+			return []error{err}
+		}
+		names, err := dir.Readdirnames(0)
+		dir.Close()
+		if err != nil {
+			return []error{err}
+		}
+
+		for _, name := range names {
+			errs = append(errs, copyFsItem(filepath.Join(srcPath, name), filepath.Join(destPath, name), true)...)
+		}
+	case srcInfo.Mode().IsRegular():
+		if err = copyFsFile(srcPath, destPath, srcInfo); err != nil {
+			return []error{err}
+		}
+	default:
+		return []error{errors.New("copy: unsupported file type " + srcPath)}
+	}
+
+	return
+}
+
+// moveFsItem renames srcPath to destPath,
+// falls back to copy and remove if they are on different devices.
+func moveFsItem(srcPath, destPath string) (errs []error) {
+	err := os.Rename(srcPath, destPath)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return []error{err}
+	}
+
+	errs = copyFsItem(srcPath, destPath, true)
+	if len(errs) > 0 {
+		return
+	}
+
+	if err = os.RemoveAll(srcPath); err != nil {
+		errs = append(errs, err)
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package serverHandler
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type failReader struct{}
+
+func (failReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestWriteUploadFile(t *testing.T) {
+	dir := t.TempDir()
+	destPath := filepath.Join(dir, "file.txt")
+
+	_, err := writeUploadFile(destPath, uploadCommitReplace, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(destPath)
+	if string(content) != "hello" {
+		t.Error(string(content))
+	}
+
+	_, err = writeUploadFile(destPath, uploadCommitReplace, io.MultiReader(strings.NewReader("partial"), failReader{}))
+	if err == nil {
+		t.Error("expect error")
+	}
+	content, _ = os.ReadFile(destPath)
+	if string(content) != "hello" {
+		t.Error(string(content))
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Error(entries)
+	}
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func (h *aliasHandler) tryHsts(w http.ResponseWriter, r *http.Request) (needRedirect bool) {
-	if r.TLS != nil {
+	if isHttps(r) {
 		w.Header().Set("Strict-Transport-Security", "max-age="+h.hstsMaxAge)
 		return
 	}
@@ -17,14 +17,15 @@ func (h *aliasHandler) tryHsts(w http.ResponseWriter, r *http.Request) (needRedi
 }
 
 func (h *aliasHandler) tryToHttps(w http.ResponseWriter, r *http.Request) (needRedirect bool) {
-	if r.TLS != nil {
+	if isHttps(r) {
 		return
 	}
 
 	hostname, _ := util.ExtractHostnamePort(r.Host)
 
+	// TLS port of trusted proxy is unknown, assume the standard one
 	var targetPort string
-	if len(h.toHttpsPort) > 0 && h.toHttpsPort != ":443" {
+	if _, forwarded := getForwardedProto(r); !forwarded && len(h.toHttpsPort) > 0 && h.toHttpsPort != ":443" {
 		targetPort = h.toHttpsPort
 	}
 
@@ -28,6 +28,7 @@ type jsonResponseData struct {
 	CanDelete          bool        `json:"canDelete"`
 	CanArchive         bool        `json:"canArchive"`
 	CanCors            bool        `json:"canCors"`
+	CsrfToken          string      `json:"csrfToken,omitempty"`
 
 	Item     *jsonItem   `json:"item"`
 	SubItems []*jsonItem `json:"subItems"`
@@ -69,6 +70,7 @@ func getJsonData(data *responseData) *jsonResponseData {
 		CanDelete:          data.CanDelete,
 		CanArchive:         data.CanArchive,
 		CanCors:            data.CanCors,
+		CsrfToken:          data.CsrfToken,
 
 		Item:     item,
 		SubItems: subItems,
@@ -0,0 +1,175 @@
+package serverHandler
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+var errJwtInvalid = errors.New("invalid JWT")
+var errJwtUnsupportedAlg = errors.New("unsupported JWT algorithm")
+var errJwtKeyNotFound = errors.New("JWT signing key not found")
+var errJwtSignature = errors.New("invalid JWT signature")
+
+// jwk is a JSON Web Key of RSA or EC public key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+func decodeBigInt(input string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(input)
+	if err != nil || len(b) == 0 {
+		return nil, errJwtInvalid
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errJwtInvalid
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errJwtUnsupportedAlg
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errJwtInvalid
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, errJwtUnsupportedAlg
+	}
+}
+
+// publicKeys gets signing keys by key id, keys for other uses or of unsupported types are skipped.
+func (set *jwkSet) publicKeys() map[string]crypto.PublicKey {
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for i := range set.Keys {
+		if len(set.Keys[i].Use) > 0 && set.Keys[i].Use != "sig" {
+			continue
+		}
+		if key, err := set.Keys[i].publicKey(); err == nil {
+			keys[set.Keys[i].Kid] = key
+		}
+	}
+	return keys
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// parseJwt splits compact serialized JWT, "<base64-header>.<base64-claims>.<base64-signature>",
+// claims are not verified until verifyJwt is called.
+func parseJwt(token string) (header *jwtHeader, claims map[string]interface{}, signingInput string, signature []byte, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, "", nil, errJwtInvalid
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, "", nil, errJwtInvalid
+	}
+	header = &jwtHeader{}
+	if json.Unmarshal(headerBytes, header) != nil {
+		return nil, nil, "", nil, errJwtInvalid
+	}
+
+	claimsBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, "", nil, errJwtInvalid
+	}
+	if json.Unmarshal(claimsBytes, &claims) != nil || claims == nil {
+		return nil, nil, "", nil, errJwtInvalid
+	}
+
+	signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, "", nil, errJwtInvalid
+	}
+
+	return header, claims, parts[0] + "." + parts[1], signature, nil
+}
+
+// verifyJwtSignature verifies signature of JWT by public key, according to algorithm in header.
+func verifyJwtSignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	hash, ok := jwtHashes[alg]
+	if !ok {
+		return errJwtUnsupportedAlg
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' || rsa.VerifyPKCS1v15(k, hash, digest, signature) != nil {
+			return errJwtSignature
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || len(signature) != 2*size {
+			return errJwtSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errJwtSignature
+		}
+	default:
+		return errJwtUnsupportedAlg
+	}
+	return nil
+}
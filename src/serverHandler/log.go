@@ -1,6 +1,7 @@
 package serverHandler
 
 import (
+	"context"
 	"mjpclab.dev/ghfs/src/serverLog"
 	"mjpclab.dev/ghfs/src/util"
 	"net/http"
@@ -8,26 +9,40 @@ import (
 	"strconv"
 )
 
+type accessLogUserKey struct{}
+
 type loggableResponseWriter struct {
 	http.ResponseWriter
-	request *http.Request
-	logger  *serverLog.Logger
+	request  *http.Request
+	logger   *serverLog.Logger
+	username *string
 }
 
 func (w loggableResponseWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
-	logRequest(w.logger, w.request, statusCode)
+	logRequest(w.logger, w.request, *w.username, statusCode)
 }
 
-func tryGetLoggableResponseWriter(w http.ResponseWriter, r *http.Request, logger *serverLog.Logger) http.ResponseWriter {
+// tryGetLoggableResponseWriter wraps writer to log access,
+// and returns request with a place to record authenticated username.
+func tryGetLoggableResponseWriter(w http.ResponseWriter, r *http.Request, logger *serverLog.Logger) (http.ResponseWriter, *http.Request) {
 	if logger.CanLogAccess() {
-		return loggableResponseWriter{w, r, logger}
+		username := new(string)
+		r = r.WithContext(context.WithValue(r.Context(), accessLogUserKey{}, username))
+		return loggableResponseWriter{w, r, logger, username}, r
 	} else {
-		return w
+		return w, r
+	}
+}
+
+// setAccessLogUser records authenticated username for access log of request.
+func setAccessLogUser(r *http.Request, username string) {
+	if p, ok := r.Context().Value(accessLogUserKey{}).(*string); ok {
+		*p = username
 	}
 }
 
-func logRequest(logger *serverLog.Logger, r *http.Request, statusCode int) {
+func logRequest(logger *serverLog.Logger, r *http.Request, username string, statusCode int) {
 	if !logger.CanLogAccess() {
 		return
 	}
@@ -46,14 +61,19 @@ func logRequest(logger *serverLog.Logger, r *http.Request, statusCode int) {
 
 	uri := util.EscapeControllingRune(r.RequestURI)
 
-	buf := serverLog.NewBuffer(3 + len(r.RemoteAddr) + len(code) + len(r.Method) + unescapedLen + len(uri))
+	buf := serverLog.NewBuffer(6 + len(r.RemoteAddr) + len(username) + len(code) + len(r.Method) + unescapedLen + len(uri))
 
 	buf = append(buf, []byte(r.RemoteAddr)...) // ~ 9-47 bytes, mainly 21 bytes
-	buf = append(buf, ' ')                     // 1 byte
-	buf = append(buf, []byte(code)...)         // 3 bytes
-	buf = append(buf, ' ')                     // 1 byte
-	buf = append(buf, []byte(r.Method)...)     // ~ 3-4 bytes
-	buf = append(buf, ' ')                     // 1 byte
+	if len(username) > 0 {
+		buf = append(buf, ' ', '(') // 2 bytes
+		buf = append(buf, []byte(username)...)
+		buf = append(buf, ')') // 1 byte
+	}
+	buf = append(buf, ' ')                 // 1 byte
+	buf = append(buf, []byte(code)...)     // 3 bytes
+	buf = append(buf, ' ')                 // 1 byte
+	buf = append(buf, []byte(r.Method)...) // ~ 3-4 bytes
+	buf = append(buf, ' ')                 // 1 byte
 	if unescapedLen > 0 {
 		buf = append(buf, unescapedUri...)
 		buf = append(buf, ' ', '<', '=', '>', ' ') // 5 bytes
@@ -121,6 +141,22 @@ func (h *aliasHandler) logArchive(filename, relPath string, r *http.Request) {
 	go h.logger.LogAccess(buf)
 }
 
+// newReloadErrorsLogger creates callback for logging errors of files reloaded automatically,
+// e.g. user file or token file.
+func newReloadErrorsLogger(logger *serverLog.Logger) func(errs []error) {
+	return func(errs []error) {
+		if !logger.CanLogError() {
+			return
+		}
+		for i := range errs {
+			errBytes := util.EscapeControllingRune(errs[i].Error())
+			buf := serverLog.NewBuffer(len(errBytes))
+			buf = append(buf, errBytes...)
+			logger.LogError(buf)
+		}
+	}
+}
+
 func (h *aliasHandler) logErrors(errs []error) (hasError bool) {
 	if len(errs) == 0 {
 		return false
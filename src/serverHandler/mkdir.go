@@ -22,6 +22,10 @@ func (h *aliasHandler) mkdirs(authUserName, fsPrefix string, files []string, ali
 			errs = append(errs, errors.New("mkdir: illegal directory path "+inputFilename))
 			continue
 		}
+		if hasReservedName(filename) {
+			errs = append(errs, errors.New("mkdir: reserved directory path "+filename))
+			continue
+		}
 
 		filenamePart1 := filename
 		if prefixEndIndex := strings.IndexByte(filenamePart1, '/'); prefixEndIndex > 0 {
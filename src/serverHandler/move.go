@@ -0,0 +1,153 @@
+package serverHandler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mjpclab.dev/ghfs/src/user"
+	"mjpclab.dev/ghfs/src/util"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type moveItem struct {
+	Name string `json:"name"`
+	To   string `json:"to"`
+}
+
+// getMoveItems reads move items from JSON request body,
+// or pairs of "name" and "to" form fields.
+func getMoveItems(r *http.Request) ([]moveItem, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var raw json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			return nil, err
+		}
+
+		var items []moveItem
+		if len(raw) > 0 && raw[0] == '[' {
+			err := json.Unmarshal(raw, &items)
+			return items, err
+		}
+
+		var item moveItem
+		err := json.Unmarshal(raw, &item)
+		return []moveItem{item}, err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	names := r.Form["name"]
+	tos := r.Form["to"]
+	if len(names) != len(tos) {
+		return nil, errors.New("move: count of name and to not match")
+	}
+
+	items := make([]moveItem, len(names))
+	for i := range names {
+		items[i] = moveItem{names[i], tos[i]}
+	}
+	return items, nil
+}
+
+// canMoveTo checks if items can be moved into sub directory of current directory.
+// Drop box is refused, whose existing items should not be revealed.
+func (h *aliasHandler) canMoveTo(authUserName string, authToken *user.Token, rawReqPath, fsPath string, r *http.Request) bool {
+	if h.isDropbox(rawReqPath, fsPath) || !h.canMutate(r, rawReqPath, fsPath) || !h.isAuthorized(r, rawReqPath, fsPath, authUserName, authToken) {
+		return false
+	}
+	info, _ := os.Stat(fsPath)
+	return h.getCanUpload(r, info, rawReqPath, fsPath, authUserName)
+}
+
+func (h *aliasHandler) moveItems(authUserName string, authToken *user.Token, rawReqPath, fsPrefix string, items []moveItem, aliasSubItems []os.FileInfo, r *http.Request) bool {
+	var errs []error
+
+	for _, item := range items {
+		if len(item.Name) == 0 || len(item.To) == 0 {
+			continue
+		}
+
+		name, ok := getCleanFilePath(item.Name)
+		if !ok {
+			errs = append(errs, errors.New("move: illegal item name "+item.Name))
+			continue
+		}
+		if containsItem(aliasSubItems, name) {
+			errs = append(errs, errors.New("move: ignore item shadowed by alias "+name))
+			continue
+		}
+
+		to, ok := getCleanDirFilePath(item.To)
+		if !ok {
+			errs = append(errs, errors.New("move: illegal target path "+item.To))
+			continue
+		}
+		toPart1 := to
+		if slashIndex := strings.IndexByte(toPart1, '/'); slashIndex > 0 {
+			toPart1 = toPart1[:slashIndex]
+		}
+		if containsItem(aliasSubItems, toPart1) {
+			errs = append(errs, errors.New("move: ignore path shadowed by alias "+to))
+			continue
+		}
+
+		fsPath := filepath.Join(fsPrefix, name)
+		toFsPath := filepath.Join(fsPrefix, to)
+		if toDir := path.Dir(to); toDir != "." {
+			// target in sub directory may be granted differently from current directory
+			toDirRawReqPath := path.Join(rawReqPath, toDir)
+			toDirFsPath := filepath.Join(fsPrefix, toDir)
+			if !h.canMoveTo(authUserName, authToken, toDirRawReqPath, toDirFsPath, r) {
+				errs = append(errs, errors.New("move: target not writable "+to))
+				continue
+			}
+		}
+		if util.HasFsPrefixDir(toFsPath, fsPath) {
+			errs = append(errs, errors.New("move: cannot move into itself "+to))
+			continue
+		}
+		info, err := os.Lstat(fsPath)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		itemRawReqPath := path.Join(rawReqPath, name)
+		if h.containsDropbox(itemRawReqPath, fsPath) || len(h.FilterItems([]os.FileInfo{info})) == 0 || !h.isAuthorized(r, itemRawReqPath, fsPath, authUserName, authToken) {
+			errs = append(errs, errors.New("move: item not authorized "+fsPath))
+			continue
+		}
+		if !info.IsDir() {
+			if err = h.checkUploadFilePath(to); err != nil {
+				errs = append(errs, fmt.Errorf("move: %w %s", err, to))
+				continue
+			}
+		} else {
+			if hasReservedName(to) {
+				errs = append(errs, errors.New("move: reserved target path "+to))
+				continue
+			}
+			if err = h.checkSubItemsReadable(r, itemRawReqPath, fsPath, authUserName, authToken); err != nil {
+				errs = append(errs, errors.New("move: "+err.Error()))
+				continue
+			}
+		}
+		if _, err := os.Lstat(toFsPath); !os.IsNotExist(err) {
+			errs = append(errs, errors.New("move: target already exists "+to))
+			continue
+		}
+
+		h.logMutate(authUserName, "move", fsPath+" -> "+toFsPath, r)
+		errs = append(errs, moveFsItem(fsPath, toFsPath)...)
+	}
+
+	if h.logErrors(errs) {
+		return false
+	}
+
+	return true
+}
@@ -1,22 +1,42 @@
 package serverHandler
 
 import (
+	"encoding/json"
 	"net/http"
 	"strings"
 )
 
+type mutateResult struct {
+	Success bool        `json:"success"`
+	Error   string      `json:"error,omitempty"`
+	Items   interface{} `json:"items,omitempty"`
+}
+
 func (h *aliasHandler) mutate(w http.ResponseWriter, r *http.Request, data *responseData) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
+	if h.logError(h.checkCsrf(r, data)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	success := false
+	var results interface{}
+	var uploadErr *uploadError
 
 	switch {
 	case data.IsUpload:
 		if data.CanUpload {
-			success = h.saveUploadFiles(data.AuthUserName, h.root+data.handlerReqPath, data.CanMkdir, data.CanDelete, data.AliasSubItems, r)
+			if h.limitUploadBody(w, r) {
+				fsPrefix := h.root + data.handlerReqPath
+				conflict := h.getUploadConflict(data.rawReqPath, fsPrefix, data.CanDelete)
+				results, success, uploadErr = h.saveUploadFiles(data.AuthUserName, data.rawReqPath, fsPrefix, data.CanMkdir, conflict, data.AliasSubItems, r)
+			} else {
+				uploadErr = errUploadBodyTooLarge
+			}
 		}
 	case data.IsMkdir:
 		if data.CanMkdir && !h.logError(r.ParseForm()) {
@@ -24,7 +44,18 @@ func (h *aliasHandler) mutate(w http.ResponseWriter, r *http.Request, data *resp
 		}
 	case data.IsDelete:
 		if data.CanDelete && !h.logError(r.ParseForm()) {
-			success = h.deleteItems(data.AuthUserName, h.root+data.handlerReqPath, r.Form["name"], data.AliasSubItems, r)
+			success = h.deleteItems(data.AuthUserName, data.rawReqPath, h.root+data.handlerReqPath, r.Form["name"], data.AliasSubItems, r)
+		}
+	case data.IsMove:
+		if data.CanUpload && data.CanDelete {
+			items, err := getMoveItems(r)
+			if !h.logError(err) {
+				success = h.moveItems(data.AuthUserName, data.authToken, data.rawReqPath, h.root+data.handlerReqPath, items, data.AliasSubItems, r)
+			}
+		}
+	case data.IsCopy:
+		if data.Item != nil && data.Item.IsDir() && !h.logError(r.ParseForm()) {
+			results, success = h.copyItems(data.AuthUserName, data.authToken, data.rawReqPath, h.root+data.handlerReqPath, r.Form["name"], r.Form.Get("to"), data.AliasSubItems, r)
 		}
 	}
 
@@ -33,13 +64,18 @@ func (h *aliasHandler) mutate(w http.ResponseWriter, r *http.Request, data *resp
 		header.Set("Content-Type", "application/json; charset=utf-8")
 		header.Set("Cache-Control", "public, max-age=0")
 
-		if success {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"success":true}`))
-		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(`{"success":false}`))
+		status := http.StatusOK
+		errMsg := ""
+		if !success {
+			status = http.StatusInternalServerError
+			if uploadErr != nil {
+				status = uploadErr.status
+				errMsg = uploadErr.message
+			}
 		}
+		body, _ := json.Marshal(&mutateResult{success, errMsg, results})
+		w.WriteHeader(status)
+		w.Write(body)
 	} else {
 		reqPath := r.RequestURI
 		qsIndex := strings.IndexByte(reqPath, '?')
@@ -58,8 +94,19 @@ func (h *aliasHandler) mutate(w http.ResponseWriter, r *http.Request, data *resp
 
 		if success {
 			http.Redirect(w, r, reqPath, http.StatusFound)
+			return
+		}
+
+		status := http.StatusInternalServerError
+		if uploadErr != nil {
+			status = uploadErr.status
+		}
+		if uploadResults, ok := results.([]uploadResult); ok && len(uploadResults) > 0 {
+			http.Error(w, getUploadResultsText(uploadResults), status)
+		} else if uploadErr != nil {
+			http.Error(w, uploadErr.message, status)
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(status)
 		}
 	}
 }
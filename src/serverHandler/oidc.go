@@ -0,0 +1,467 @@
+package serverHandler
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const oidcCallbackQueryParam = "oidc-callback"
+const oidcStateCookieName = "ghfs_oidc_state"
+const oidcSessionPrefix = "oidc."
+
+// max time for user to finish login at issuer
+const oidcStateMaxAge = 10 * time.Minute
+
+// min interval of reloading JWKS for unknown key id
+const oidcKeysReloadInterval = time.Minute
+
+// tolerance of clock difference between issuer and server
+const oidcClockSkew = time.Minute
+
+var errOidcState = errors.New("invalid OIDC state")
+
+// oidcConfig is the part of OpenID Provider metadata that is used.
+type oidcConfig struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// oidcProvider logs in users by OpenID Connect authorization code flow.
+// Provider metadata and signing keys are fetched on first use, so that issuer is not required at startup.
+type oidcProvider struct {
+	issuer       string
+	clientId     string
+	clientSecret string
+	redirectUrl  string
+	scope        string
+	userClaim    string
+	groupsClaim  string
+	client       *http.Client
+
+	mu         sync.Mutex
+	config     *oidcConfig
+	keys       map[string]crypto.PublicKey
+	keysLoaded time.Time
+}
+
+func newOidcProvider(issuer, clientId, clientSecret, redirectUrl, scope, userClaim, groupsClaim string) *oidcProvider {
+	if len(issuer) == 0 {
+		return nil
+	}
+	return &oidcProvider{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		redirectUrl:  redirectUrl,
+		scope:        scope,
+		userClaim:    userClaim,
+		groupsClaim:  groupsClaim,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *oidcProvider) getJson(url string, result interface{}) error {
+	res, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.New("OIDC: " + url + " responded " + res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(result)
+}
+
+// getConfig gets provider metadata from issuer discovery document.
+func (p *oidcProvider) getConfig() (*oidcConfig, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.config != nil {
+		return p.config, nil
+	}
+
+	config := &oidcConfig{}
+	err := p.getJson(p.issuer+"/.well-known/openid-configuration", config)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(config.Issuer, "/") != p.issuer {
+		return nil, errors.New("OIDC: issuer mismatch: " + config.Issuer)
+	}
+	if len(config.AuthorizationEndpoint) == 0 || len(config.TokenEndpoint) == 0 || len(config.JwksUri) == 0 {
+		return nil, errors.New("OIDC: incomplete provider metadata of " + p.issuer)
+	}
+	p.config = config
+	return config, nil
+}
+
+// getKey gets signing key by key id, reloads JWKS if key id is unknown, as issuer may rotate keys.
+func (p *oidcProvider) getKey(config *oidcConfig, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysLoaded) < oidcKeysReloadInterval {
+		return nil, errJwtKeyNotFound
+	}
+
+	set := &jwkSet{}
+	err := p.getJson(config.JwksUri, set)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = set.publicKeys()
+	p.keysLoaded = time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errJwtKeyNotFound
+}
+
+func (p *oidcProvider) getRedirectUrl(r *http.Request, data *responseData) string {
+	if len(p.redirectUrl) > 0 {
+		return p.redirectUrl
+	}
+
+	scheme := "http"
+	if isHttps(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + getUrlPrefix(data.prefixReqPath, data.rawReqPath) + "/?" + oidcCallbackQueryParam
+}
+
+func (p *oidcProvider) authCodeUrl(config *oidcConfig, redirectUrl, state, nonce, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientId},
+		"redirect_uri":          {redirectUrl},
+		"scope":                 {p.scope},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if strings.IndexByte(config.AuthorizationEndpoint, '?') >= 0 {
+		return config.AuthorizationEndpoint + "&" + query.Encode()
+	}
+	return config.AuthorizationEndpoint + "?" + query.Encode()
+}
+
+// exchangeCode redeems authorization code for ID token at token endpoint.
+func (p *oidcProvider) exchangeCode(config *oidcConfig, redirectUrl, code, verifier string) (idToken string, err error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectUrl},
+		"code_verifier": {verifier},
+	}
+	if len(p.clientSecret) == 0 {
+		form.Set("client_id", p.clientId)
+	}
+	req, err := http.NewRequest(http.MethodPost, config.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if len(p.clientSecret) > 0 {
+		req.SetBasicAuth(url.QueryEscape(p.clientId), url.QueryEscape(p.clientSecret))
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&result)
+	if err != nil {
+		return
+	}
+	if res.StatusCode != http.StatusOK || len(result.Error) > 0 {
+		return "", errors.New("OIDC: token request failed: " + res.Status + " " + result.Error + " " + result.ErrorDescription)
+	}
+	if len(result.IdToken) == 0 {
+		return "", errors.New("OIDC: no ID token in token response")
+	}
+	return result.IdToken, nil
+}
+
+func hasAudience(aud interface{}, clientId string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientId
+	case []interface{}:
+		for _, item := range v {
+			if item == clientId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getNumericDate(claims map[string]interface{}, name string) (time.Time, bool) {
+	if v, ok := claims[name].(float64); ok {
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Time{}, false
+}
+
+// getStrings gets claim that is a string or an array of strings.
+func getStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		results := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				results = append(results, s)
+			}
+		}
+		return results
+	}
+	return nil
+}
+
+// verifyIdToken verifies signature and claims of ID token,
+// and maps claims to username and groups.
+func (p *oidcProvider) verifyIdToken(config *oidcConfig, idToken, nonce string, now time.Time) (username string, groups []string, err error) {
+	header, claims, signingInput, signature, err := parseJwt(idToken)
+	if err != nil {
+		return
+	}
+	if _, ok := jwtHashes[header.Alg]; !ok {
+		return "", nil, errJwtUnsupportedAlg
+	}
+	key, err := p.getKey(config, header.Kid)
+	if err != nil {
+		return
+	}
+	err = verifyJwtSignature(header.Alg, key, signingInput, signature)
+	if err != nil {
+		return
+	}
+
+	if iss, _ := claims["iss"].(string); iss != config.Issuer {
+		return "", nil, errors.New("OIDC: ID token issuer mismatch")
+	}
+	if !hasAudience(claims["aud"], p.clientId) {
+		return "", nil, errors.New("OIDC: ID token audience mismatch")
+	}
+	if exp, ok := getNumericDate(claims, "exp"); !ok || !now.Before(exp.Add(oidcClockSkew)) {
+		return "", nil, errors.New("OIDC: ID token expired")
+	}
+	if iat, ok := getNumericDate(claims, "iat"); ok && now.Add(oidcClockSkew).Before(iat) {
+		return "", nil, errors.New("OIDC: ID token issued in the future")
+	}
+	if n, _ := claims["nonce"].(string); len(n) == 0 || !hmac.Equal([]byte(n), []byte(nonce)) {
+		return "", nil, errors.New("OIDC: ID token nonce mismatch")
+	}
+
+	username, _ = claims[p.userClaim].(string)
+	if len(username) == 0 {
+		return "", nil, errors.New("OIDC: missing claim " + p.userClaim + " in ID token")
+	}
+	if p.userClaim == "email" {
+		if verified, ok := claims["email_verified"].(bool); ok && !verified {
+			return "", nil, errors.New("OIDC: email not verified: " + username)
+		}
+	}
+	if len(p.groupsClaim) > 0 {
+		groups = getStrings(claims[p.groupsClaim])
+	}
+	return
+}
+
+// newOidcSessionValue makes session cookie value for user logged in by OIDC,
+// "oidc.<base64-username>.<base64-groups>.<expires>.<base64-signature>".
+func newOidcSessionValue(key []byte, username string, groups []string, expires time.Time) string {
+	payload := oidcSessionPrefix +
+		base64.RawURLEncoding.EncodeToString([]byte(username)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(strings.Join(groups, "\n"))) + "." +
+		strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signSession(key, payload))
+}
+
+// parseOidcSessionValue verifies signature and expiry of OIDC session cookie value.
+func parseOidcSessionValue(key []byte, value string, now time.Time) (username string, groups []string, ok bool) {
+	sigIndex := strings.LastIndexByte(value, '.')
+	if sigIndex <= 0 || !strings.HasPrefix(value, oidcSessionPrefix) {
+		return
+	}
+	payload := value[:sigIndex]
+	signature, err := base64.RawURLEncoding.DecodeString(value[sigIndex+1:])
+	if err != nil || !hmac.Equal(signature, signSession(key, payload)) {
+		return
+	}
+
+	fields := strings.Split(payload[len(oidcSessionPrefix):], ".")
+	if len(fields) != 3 {
+		return
+	}
+	name, err := base64.RawURLEncoding.DecodeString(fields[0])
+	if err != nil || len(name) == 0 {
+		return
+	}
+	groupsBytes, err := base64.RawURLEncoding.DecodeString(fields[1])
+	if err != nil {
+		return
+	}
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return
+	}
+
+	if len(groupsBytes) > 0 {
+		groups = strings.Split(string(groupsBytes), "\n")
+	}
+	return string(name), groups, true
+}
+
+func newRandomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// newOidcStateValue makes state cookie value that binds login request to callback,
+// "<state>.<nonce>.<code-verifier>.<base64-return-url>.<expires>.<base64-signature>".
+func newOidcStateValue(key []byte, state, nonce, verifier, returnUrl string, expires time.Time) string {
+	payload := state + "." + nonce + "." + verifier + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(returnUrl)) + "." +
+		strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signSession(key, oidcSessionPrefix+payload))
+}
+
+func parseOidcStateValue(key []byte, value, state string, now time.Time) (nonce, verifier, returnUrl string, err error) {
+	sigIndex := strings.LastIndexByte(value, '.')
+	if sigIndex <= 0 {
+		return "", "", "", errOidcState
+	}
+	payload := value[:sigIndex]
+	signature, err := base64.RawURLEncoding.DecodeString(value[sigIndex+1:])
+	if err != nil || !hmac.Equal(signature, signSession(key, oidcSessionPrefix+payload)) {
+		return "", "", "", errOidcState
+	}
+
+	fields := strings.Split(payload, ".")
+	if len(fields) != 5 || len(state) == 0 || !hmac.Equal([]byte(fields[0]), []byte(state)) {
+		return "", "", "", errOidcState
+	}
+	expires, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return "", "", "", errOidcState
+	}
+	returnUrlBytes, err := base64.RawURLEncoding.DecodeString(fields[3])
+	if err != nil {
+		return "", "", "", errOidcState
+	}
+
+	return fields[1], fields[2], string(returnUrlBytes), nil
+}
+
+func (h *aliasHandler) setOidcStateCookie(w http.ResponseWriter, r *http.Request, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   isHttps(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// oidcLogin redirects user to issuer for login, and returns to current url after callback.
+func (h *aliasHandler) oidcLogin(w http.ResponseWriter, r *http.Request, data *responseData) {
+	config, err := h.oidc.getConfig()
+	if err != nil {
+		h.logError(err)
+		h.loginPage(w, r, data, http.StatusBadGateway)
+		return
+	}
+
+	state := newRandomString()
+	nonce := newRandomString()
+	verifier := newRandomString()
+	returnUrl := data.prefixReqPath + data.Context.QueryString()
+	h.setOidcStateCookie(w, r, newOidcStateValue(h.sessionKey, state, nonce, verifier, returnUrl, time.Now().Add(oidcStateMaxAge)), int(oidcStateMaxAge/time.Second))
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, h.oidc.authCodeUrl(config, h.oidc.getRedirectUrl(r, data), state, nonce, verifier), http.StatusFound)
+}
+
+// oidcCallback handles authorization response from issuer,
+// and creates login session for user of verified ID token.
+func (h *aliasHandler) oidcCallback(w http.ResponseWriter, r *http.Request, data *responseData) {
+	query := r.URL.Query()
+	h.setOidcStateCookie(w, r, "", -1)
+
+	if errCode := query.Get("error"); len(errCode) > 0 {
+		h.logger.LogErrorString(r.RemoteAddr + " OIDC login failed: " + errCode + " " + query.Get("error_description"))
+		h.loginPage(w, r, data, http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if err != nil {
+		h.logError(errors.New(r.RemoteAddr + " OIDC login failed: missing state"))
+		h.loginPage(w, r, data, http.StatusBadRequest)
+		return
+	}
+	nonce, verifier, returnUrl, err := parseOidcStateValue(h.sessionKey, cookie.Value, query.Get("state"), time.Now())
+	if err != nil {
+		h.logError(errors.New(r.RemoteAddr + " OIDC login failed: " + err.Error()))
+		h.loginPage(w, r, data, http.StatusBadRequest)
+		return
+	}
+
+	config, err := h.oidc.getConfig()
+	if err != nil {
+		h.logError(err)
+		h.loginPage(w, r, data, http.StatusBadGateway)
+		return
+	}
+	idToken, err := h.oidc.exchangeCode(config, h.oidc.getRedirectUrl(r, data), query.Get("code"), verifier)
+	if err != nil {
+		h.logError(err)
+		h.loginPage(w, r, data, http.StatusBadGateway)
+		return
+	}
+	username, groups, err := h.oidc.verifyIdToken(config, idToken, nonce, time.Now())
+	if err != nil {
+		h.logError(errors.New(r.RemoteAddr + " OIDC login failed: " + err.Error()))
+		h.loginPage(w, r, data, http.StatusUnauthorized)
+		return
+	}
+
+	expires := time.Now().Add(h.sessionMaxAge)
+	h.setSessionCookie(w, r, newOidcSessionValue(h.sessionKey, username, groups, expires), int(h.sessionMaxAge/time.Second))
+	http.Redirect(w, r, returnUrl, http.StatusSeeOther)
+}
@@ -0,0 +1,309 @@
+package serverHandler
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"mjpclab.dev/ghfs/src/param"
+	"mjpclab.dev/ghfs/src/serverLog"
+	"mjpclab.dev/ghfs/src/tpl/defaultTheme"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signTestJwt(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyJwtSignature(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	set := &jwkSet{Keys: []jwk{
+		{Kty: "RSA", Kid: "r", N: base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()), E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "e", Crv: "P-256", X: base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()), Y: base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes())},
+		{Kty: "RSA", Kid: "enc", Use: "enc", N: "AQAB", E: "AQAB"},
+	}}
+	keys := set.publicKeys()
+	if len(keys) != 2 {
+		t.Fatal(len(keys))
+	}
+
+	claims := map[string]interface{}{"sub": "alice"}
+	for _, c := range []struct {
+		alg, kid string
+		key      crypto.Signer
+	}{{"RS256", "r", rsaKey}, {"ES256", "e", ecKey}} {
+		token := signTestJwt(t, c.alg, c.kid, c.key, claims)
+		header, parsedClaims, signingInput, signature, err := parseJwt(token)
+		if err != nil || header.Alg != c.alg || header.Kid != c.kid || parsedClaims["sub"] != "alice" {
+			t.Fatal(err, header, parsedClaims)
+		}
+		if err := verifyJwtSignature(header.Alg, keys[header.Kid], signingInput, signature); err != nil {
+			t.Error(c.alg, err)
+		}
+		if err := verifyJwtSignature(header.Alg, keys[header.Kid], signingInput+"x", signature); err == nil {
+			t.Error(c.alg, "tampered token should be invalid")
+		}
+	}
+
+	token := signTestJwt(t, "RS256", "r", rsaKey, claims)
+	_, _, signingInput, signature, _ := parseJwt(token)
+	if err := verifyJwtSignature("none", keys["r"], signingInput, signature); err == nil {
+		t.Error("alg none should be rejected")
+	}
+	if err := verifyJwtSignature("ES256", keys["r"], signingInput, signature); err == nil {
+		t.Error("alg should match key type")
+	}
+
+	for _, invalid := range []string{"", "a.b", "a.b.c", "e30.e30"} {
+		if _, _, _, _, err := parseJwt(invalid); err == nil {
+			t.Error(invalid)
+		}
+	}
+}
+
+func TestOidcSessionValue(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1000000000, 0)
+
+	value := newOidcSessionValue(key, "alice@example.com", []string{"ops", "dev"}, now.Add(time.Hour))
+	if username, groups, ok := parseOidcSessionValue(key, value, now); !ok || username != "alice@example.com" || len(groups) != 2 || groups[1] != "dev" {
+		t.Error(username, groups, ok)
+	}
+	if _, _, ok := parseOidcSessionValue(key, value, now.Add(time.Hour)); ok {
+		t.Error("expired session should be invalid")
+	}
+	if _, ok := parseSessionValue(key, value, now); ok {
+		t.Error("OIDC session should not be a form login session")
+	}
+	if _, _, ok := parseOidcSessionValue(key, newSessionValue(key, "alice", now.Add(time.Hour)), now); ok {
+		t.Error("form login session should not be an OIDC session")
+	}
+
+	value = newOidcSessionValue(key, "bob", nil, now.Add(time.Hour))
+	if username, groups, ok := parseOidcSessionValue(key, value, now); !ok || username != "bob" || len(groups) != 0 {
+		t.Error(username, groups, ok)
+	}
+}
+
+// testIssuer is a stand-in OIDC issuer, which issues ID token with claims for any authorization code.
+type testIssuer struct {
+	*httptest.Server
+	t        *testing.T
+	key      *rsa.PrivateKey
+	claims   map[string]interface{}
+	nonce    string
+	verifier string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	issuer := &testIssuer{t: t}
+	issuer.key, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.URL,
+			"authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint":         issuer.URL + "/token",
+			"jwks_uri":               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwkSet{Keys: []jwk{{
+			Kty: "RSA",
+			Kid: "k1",
+			N:   base64.RawURLEncoding.EncodeToString(issuer.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(issuer.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, _ := r.BasicAuth()
+		if clientId != "ghfs" || clientSecret != "ClientSecret" || r.PostFormValue("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(challenge[:]) != issuer.verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		claims := map[string]interface{}{
+			"iss":   issuer.URL,
+			"aud":   "ghfs",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": issuer.nonce,
+		}
+		for k, v := range issuer.claims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token": signTestJwt(t, "RS256", "k1", issuer.key, claims),
+		})
+	})
+	issuer.Server = httptest.NewServer(mux)
+	return issuer
+}
+
+// authorize simulates user login at issuer, returns callback url.
+func (issuer *testIssuer) authorize(location, code string) string {
+	authUrl, err := url.Parse(location)
+	if err != nil || !strings.HasPrefix(location, issuer.URL+"/authorize?") {
+		issuer.t.Fatal("unexpected redirect:", location)
+	}
+	query := authUrl.Query()
+	if query.Get("client_id") != "ghfs" || query.Get("code_challenge_method") != "S256" {
+		issuer.t.Fatal("unexpected authorization request:", location)
+	}
+	issuer.nonce = query.Get("nonce")
+	issuer.verifier = query.Get("code_challenge")
+	return query.Get("redirect_uri") + "&code=" + code + "&state=" + query.Get("state")
+}
+
+func TestOidcLogin(t *testing.T) {
+	issuer := newTestIssuer(t)
+	defer issuer.Close()
+	issuer.claims = map[string]interface{}{"email": "alice@example.com", "email_verified": true, "groups": []string{"ops"}}
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "ops"), 0755)
+	os.Mkdir(filepath.Join(root, "dev"), 0755)
+
+	cmdResults, _, _, errs := param.ArgsToCmdResults(param.NewCliCmd(), []string{"ghfs",
+		"-r", root, "--global-auth",
+		"--user", "alice@example.com:AlicePass",
+		"--auth-user", ":/ops:@ops", "--auth-user", ":/dev:@dev",
+		"--oidc-issuer", issuer.URL, "--oidc-client-id", "ghfs", "--oidc-client-secret", "ClientSecret",
+	})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	params, errs := param.CmdResultsToParams(cmdResults)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	logger, _ := serverLog.NewFileMan().NewLogger("", "")
+	handler, errs := NewVhostHandler(params[0], logger, defaultTheme.DefaultTheme)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	request := func(target string, cookies []*http.Cookie) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Accept", "text/html")
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	// browser is redirected to issuer
+	res := request("http://localhost/ops/", nil)
+	if res.StatusCode != http.StatusFound {
+		t.Fatal(res.StatusCode)
+	}
+	stateCookies := res.Cookies()
+	callback := issuer.authorize(res.Header.Get("Location"), "good-code")
+	if !strings.HasPrefix(callback, "http://localhost/?oidc-callback&") {
+		t.Fatal(callback)
+	}
+
+	// callback without state cookie
+	if res := request(callback, nil); res.StatusCode != http.StatusBadRequest {
+		t.Error(res.StatusCode)
+	}
+
+	// callback with invalid code
+	if res := request(strings.Replace(callback, "good-code", "bad-code", 1), stateCookies); res.StatusCode != http.StatusBadGateway {
+		t.Error(res.StatusCode)
+	}
+
+	// callback creates session, and returns to original url
+	res = request(callback, stateCookies)
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/ops/" {
+		t.Fatal(res.StatusCode, res.Header.Get("Location"))
+	}
+	var sessionCookies []*http.Cookie
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == sessionCookieName {
+			sessionCookies = append(sessionCookies, cookie)
+		}
+	}
+	if len(sessionCookies) != 1 {
+		t.Fatal(res.Cookies())
+	}
+
+	// groups from ID token
+	if res := request("http://localhost/ops/?json", sessionCookies); res.StatusCode != http.StatusOK {
+		t.Error(res.StatusCode)
+	}
+	if res := request("http://localhost/dev/?json", sessionCookies); res.StatusCode != http.StatusUnauthorized {
+		t.Error(res.StatusCode)
+	}
+
+	// groups are not inherited by same name authenticated by other means
+	r := httptest.NewRequest(http.MethodGet, "http://localhost/ops/?json", nil)
+	r.SetBasicAuth("alice@example.com", "AlicePass")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Error(w.Code)
+	}
+
+	// authenticated but not allowed user is not redirected again
+	if res := request("http://localhost/dev/", sessionCookies); res.StatusCode != http.StatusUnauthorized {
+		t.Error(res.StatusCode)
+	}
+
+	// unverified email
+	issuer.claims["email_verified"] = false
+	res = request("http://localhost/?login", nil)
+	callback = issuer.authorize(res.Header.Get("Location"), "good-code")
+	if res := request(callback, res.Cookies()); res.StatusCode != http.StatusUnauthorized {
+		t.Error(res.StatusCode)
+	}
+
+	// nonce mismatch
+	issuer.claims["email_verified"] = true
+	res = request("http://localhost/?login", nil)
+	callback = issuer.authorize(res.Header.Get("Location"), "good-code")
+	issuer.nonce = "other"
+	if res := request(callback, res.Cookies()); res.StatusCode != http.StatusUnauthorized {
+		t.Error(res.StatusCode)
+	}
+}
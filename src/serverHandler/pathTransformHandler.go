@@ -28,7 +28,7 @@ func (transformer pathTransformHandler) ServeHTTP(w http.ResponseWriter, r *http
 	} else {
 		urlPathDir = urlPath
 	}
-	r.URL.RawPath = urlPathDir
+	r.URL.RawPath = getForwardedPrefix(r) + urlPathDir
 
 	if len(transformer.prefixes) == 0 {
 		r.URL.Path = urlPathDir
@@ -1,78 +1,145 @@
 package serverHandler
 
 import (
-	"mjpclab.dev/ghfs/src/util"
+	"errors"
+	"mjpclab.dev/ghfs/src/user"
+	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 )
 
-func hasUrlOrDirPrefix(urls []string, reqUrl string, dirs []string, reqDir string) bool {
-	for _, url := range urls {
-		if util.HasUrlPrefixDir(reqUrl, url) {
-			return true
+// matches any authenticated user
+const anyUser = "*"
+const userGroupPrefix = "@"
+
+func newUserGroups(entries [][]string) map[string][]string {
+	groups := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		groups[entry[0]] = entry[1:]
+	}
+	return groups
+}
+
+// expandUserGroups expands "@<group>" in names into members of the group.
+// If keepGroups is true, "@<group>" itself is also kept, and unknown group is not an error.
+func expandUserGroups(names []string, groups map[string][]string, keepGroups bool) (users []string, errs []error) {
+	users = make([]string, 0, len(names))
+	for _, name := range names {
+		if !strings.HasPrefix(name, userGroupPrefix) {
+			users = append(users, name)
+			continue
+		}
+		if keepGroups {
+			users = append(users, name)
+		}
+		members, ok := groups[name[len(userGroupPrefix):]]
+		if !ok {
+			if !keepGroups {
+				errs = append(errs, errors.New("unknown user group: "+name[len(userGroupPrefix):]))
+			}
+			continue
 		}
+		users = append(users, members...)
 	}
+	return
+}
 
-	for _, dir := range dirs {
-		if util.HasFsPrefixDir(reqDir, dir) {
-			return true
+// checkPolicyBeforeAuth checks capabilities that do not depend on user,
+// returns error if request is blocked, and if client can mutate the path.
+func (h *aliasHandler) checkPolicyBeforeAuth(r *http.Request, rawReqPath, reqFsPath string, isWrite bool) (canMutate bool, err error) {
+	subject := newPolicySubject(r, rawReqPath, reqFsPath, "")
+	canMutate = h.policy.allows(policyCapMutate, subject)
+
+	switch {
+	case !h.policy.allows(policyCapConnect, subject):
+		err = errors.New(r.RemoteAddr + " blocked by rule: connect " + rawReqPath)
+	case isWrite && !canMutate:
+		err = errors.New(r.RemoteAddr + " blocked by rule: mutate " + rawReqPath)
+	default:
+		// denied for any user
+		if decision := h.policy.decide(policyCapAccess, subject); !decision.allow && !decision.userDependent {
+			err = errors.New(r.RemoteAddr + " blocked by rule: access " + rawReqPath)
 		}
 	}
+	return
+}
 
-	return false
+// canMutate checks if client can mutate the target path before auth,
+// which may be other than request path, e.g. destination of copy or move.
+func (h *aliasHandler) canMutate(r *http.Request, rawReqPath, fsPath string) bool {
+	canMutate, err := h.checkPolicyBeforeAuth(r, rawReqPath, fsPath, true)
+	return canMutate && err == nil
 }
 
-func (h *aliasHandler) getCanUpload(info os.FileInfo, rawReqPath, reqFsPath string) bool {
-	if info == nil || !info.IsDir() {
+func (h *aliasHandler) getCanUpload(r *http.Request, info os.FileInfo, rawReqPath, reqFsPath, username string) bool {
+	if info == nil || !info.IsDir() || hasReservedName(rawReqPath) {
 		return false
 	}
 
-	if h.globalUpload {
-		return true
-	}
-
-	return hasUrlOrDirPrefix(h.uploadUrls, rawReqPath, h.uploadDirs, reqFsPath)
+	return h.policy.allows(policyCapUpload, newPolicySubject(r, rawReqPath, reqFsPath, username))
 }
 
-func (h *aliasHandler) getCanMkdir(info os.FileInfo, rawReqPath, reqFsPath string) bool {
-	if info == nil || !info.IsDir() {
+func (h *aliasHandler) getCanMkdir(r *http.Request, info os.FileInfo, rawReqPath, reqFsPath, username string) bool {
+	if info == nil || !info.IsDir() || hasReservedName(rawReqPath) {
 		return false
 	}
 
-	if h.globalMkdir {
-		return true
-	}
-
-	return hasUrlOrDirPrefix(h.mkdirUrls, rawReqPath, h.mkdirDirs, reqFsPath)
+	return h.policy.allows(policyCapMkdir, newPolicySubject(r, rawReqPath, reqFsPath, username))
 }
 
-func (h *aliasHandler) getCanDelete(info os.FileInfo, rawReqPath, reqFsPath string) bool {
-	if info == nil || !info.IsDir() {
+func (h *aliasHandler) getCanDelete(r *http.Request, info os.FileInfo, rawReqPath, reqFsPath, username string) bool {
+	if info == nil || !info.IsDir() || hasReservedName(rawReqPath) || h.isDropbox(rawReqPath, reqFsPath) {
 		return false
 	}
 
-	if h.globalDelete {
-		return true
-	}
-
-	return hasUrlOrDirPrefix(h.deleteUrls, rawReqPath, h.deleteDirs, reqFsPath)
+	return h.policy.allows(policyCapDelete, newPolicySubject(r, rawReqPath, reqFsPath, username))
 }
 
-func (h *aliasHandler) getCanArchive(subInfos []os.FileInfo, rawReqPath, reqFsPath string) bool {
-	if len(subInfos) == 0 {
+func (h *aliasHandler) getCanArchive(r *http.Request, subInfos []os.FileInfo, rawReqPath, reqFsPath, username string) bool {
+	if len(subInfos) == 0 || h.containsDropbox(rawReqPath, reqFsPath) {
 		return false
 	}
 
-	if h.globalArchive {
-		return true
-	}
+	return h.policy.allows(policyCapArchive, newPolicySubject(r, rawReqPath, reqFsPath, username))
+}
 
-	return hasUrlOrDirPrefix(h.archiveUrls, rawReqPath, h.archiveDirs, reqFsPath)
+func (h *aliasHandler) getCanCors(r *http.Request, rawReqPath, reqFsPath, username string) bool {
+	return h.policy.allows(policyCapCors, newPolicySubject(r, rawReqPath, reqFsPath, username))
 }
 
-func (h *aliasHandler) getCanCors(rawReqPath, reqFsPath string) bool {
-	if h.globalCors {
-		return true
+// checkSubItemsReadable walks sub items of directory fsPath recursively,
+// returns error if any of them is hidden, in a drop box, or not allowed for user or token,
+// so that copying or moving the directory will not expose them at another path.
+func (h *aliasHandler) checkSubItemsReadable(r *http.Request, rawReqPath, fsPath, username string, token *user.Token) error {
+	dir, err := os.Open(fsPath)
+	if err != nil {
+		return err
+	}
+	infos, err := dir.Readdir(0)
+	dir.Close()
+	if err != nil {
+		return err
 	}
 
-	return hasUrlOrDirPrefix(h.corsUrls, rawReqPath, h.corsDirs, reqFsPath)
+	if len(h.FilterItems(infos)) != len(infos) {
+		return errors.New("contains hidden item " + fsPath)
+	}
+	for _, info := range infos {
+		subRawReqPath := path.Join(rawReqPath, info.Name())
+		subFsPath := filepath.Join(fsPath, info.Name())
+		if h.isDropbox(subRawReqPath, subFsPath) {
+			return errors.New("contains drop box " + subFsPath)
+		}
+		if !h.isAuthorized(r, subRawReqPath, subFsPath, username, token) {
+			return errors.New("contains item not authorized " + subFsPath)
+		}
+		if info.IsDir() {
+			if err = h.checkSubItemsReadable(r, subRawReqPath, subFsPath, username, token); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
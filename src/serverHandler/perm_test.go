@@ -0,0 +1,124 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/param"
+	"mjpclab.dev/ghfs/src/user"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestGetCanUploadByUser(t *testing.T) {
+	users := user.NewList(false)
+	groups := newUserGroups([][]string{{"staff", "alice", "bob"}})
+	policy, errs := newPolicy(&param.Param{
+		UploadUserUrls: [][]string{{"/shared", "@staff"}, {"/public", "*"}},
+		UploadUserDirs: [][]string{{"/fs/carol", "carol"}},
+	}, users, groups, false)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	h := &aliasHandler{users: users, policy: policy}
+	r := httptest.NewRequest("GET", "/", nil)
+	dir := &dummyFileInfo{isDir: true}
+
+	if !h.getCanUpload(r, dir, "/shared/sub", "/fs/shared/sub", "Alice") {
+		t.Error("alice should be able to upload by group")
+	}
+	if h.getCanUpload(r, dir, "/shared", "/fs/shared", "carol") {
+		t.Error("carol should not be able to upload to /shared")
+	}
+	if !h.getCanUpload(r, dir, "/x", "/fs/carol", "carol") {
+		t.Error("carol should be able to upload by dir")
+	}
+	if !h.getCanUpload(r, dir, "/public", "/fs/public", "dave") {
+		t.Error("any authenticated user should be able to upload")
+	}
+	if h.getCanUpload(r, dir, "/public", "/fs/public", "") {
+		t.Error("anonymous user should not be able to upload")
+	}
+	if h.getCanUpload(r, nil, "/public", "/fs/public", "dave") {
+		t.Error("should not upload to non-dir")
+	}
+
+	if _, errs := expandUserGroups([]string{"@unknown"}, groups, false); len(errs) != 1 {
+		t.Error(errs)
+	}
+}
+
+func TestIsUserAllowed(t *testing.T) {
+	users := user.NewList(false)
+	groups := newUserGroups([][]string{{"ops", "alice", "bob"}})
+	policy, errs := newPolicy(&param.Param{
+		AuthUserUrls: [][]string{{"/ops", "@ops"}, {"/ops/secret", "alice"}},
+		AuthUserDirs: [][]string{{"/fs/carol", "carol"}},
+	}, users, groups, false)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	h := &aliasHandler{users: users, policy: policy}
+	r := httptest.NewRequest("GET", "/", nil)
+
+	if need, _ := h.needAuth(r, "", "/ops/sub", "/fs/ops/sub"); !need {
+		t.Error("allow-list path should need auth")
+	}
+	if need, _ := h.needAuth(r, "", "/public", "/fs/public"); need {
+		t.Error("public path should not need auth")
+	}
+
+	if !h.isUserAllowed(r, "/ops", "/fs/ops", "bob") {
+		t.Error("bob should be allowed by group")
+	}
+	if h.isUserAllowed(r, "/ops/secret/x", "/fs/ops/secret/x", "bob") {
+		t.Error("bob should not be allowed by nested list")
+	}
+	if !h.isUserAllowed(r, "/ops/secret/x", "/fs/ops/secret/x", "alice") {
+		t.Error("alice should be allowed")
+	}
+	if h.isUserAllowed(r, "/ops", "/fs/ops", "carol") {
+		t.Error("carol should not be allowed")
+	}
+	if !h.isUserAllowed(r, "/public", "/fs/public", "carol") {
+		t.Error("carol should be allowed for unlisted path")
+	}
+
+	items := []os.FileInfo{
+		&dummyFileInfo{name: "ops", isDir: true},
+		&dummyFileInfo{name: "carol", isDir: true},
+		&dummyFileInfo{name: "public", isDir: true},
+	}
+	items = h.filterAllowedItems(r, "/", "/fs", items, "carol")
+	if len(items) != 2 || items[0].Name() != "carol" || items[1].Name() != "public" {
+		t.Error(items)
+	}
+}
+
+func TestContainsDropbox(t *testing.T) {
+	h := &aliasHandler{
+		dropboxUrls: []string{"/pub/in"},
+		dropboxDirs: []string{"/data/inbox"},
+		aliases:     aliases{createAlias("/mnt/data", "/data")},
+	}
+
+	expects := []struct {
+		rawReqPath string
+		fsPath     string
+		contains   bool
+	}{
+		{"/pub/in/a", "/fs/pub/in/a", true},
+		{"/pub/in", "/fs/pub/in", true},
+		{"/pub", "/fs/pub", true},
+		{"/", "/fs", true},
+		{"/pub/out", "/fs/pub/out", false},
+		{"/mnt", "/fs/mnt", true},
+		{"/other", "/data", true},
+		{"/other", "/data/public", false},
+	}
+	for _, expect := range expects {
+		if contains := h.containsDropbox(expect.rawReqPath, expect.fsPath); contains != expect.contains {
+			t.Error(expect.rawReqPath, expect.fsPath, contains)
+		}
+	}
+}
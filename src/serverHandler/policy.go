@@ -0,0 +1,412 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/user"
+	"mjpclab.dev/ghfs/src/util"
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type policyCap uint16
+
+const (
+	// client can connect, checked before auth
+	policyCapConnect policyCap = 1 << iota
+	// client can perform mutating operations, checked before auth
+	policyCapMutate
+	// user can access, anonymous user that is denied by user related rules should authenticate
+	policyCapAccess
+	policyCapUpload
+	policyCapMkdir
+	policyCapDelete
+	policyCapArchive
+	policyCapCors
+	// page from referer host can access file content, checked regardless of user
+	policyCapReferer
+)
+
+const policyCapAll = policyCapConnect | policyCapMutate | policyCapAccess | policyCapUpload | policyCapMkdir | policyCapDelete | policyCapArchive | policyCapCors | policyCapReferer
+
+// capabilities that are checked before auth or regardless of user, thus cannot have user conditions
+const policyCapsBeforeAuth = policyCapConnect | policyCapMutate | policyCapReferer
+
+// capabilities that are allowed if no rule matches
+const policyCapsDefaultAllow = policyCapConnect | policyCapMutate | policyCapAccess | policyCapReferer
+
+var policyCapNames = []struct {
+	cap  policyCap
+	name string
+}{
+	{policyCapConnect, "connect"},
+	{policyCapMutate, "mutate"},
+	{policyCapAccess, "access"},
+	{policyCapUpload, "upload"},
+	{policyCapMkdir, "mkdir"},
+	{policyCapDelete, "delete"},
+	{policyCapArchive, "archive"},
+	{policyCapCors, "cors"},
+	{policyCapReferer, "referer"},
+}
+
+func (caps policyCap) String() string {
+	if caps == policyCapAll {
+		return "*"
+	}
+	names := make([]string, 0, len(policyCapNames))
+	for _, capName := range policyCapNames {
+		if caps&capName.cap != 0 {
+			names = append(names, capName.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+type policyConditionKind uint8
+
+const (
+	policyCondUrl policyConditionKind = iota
+	policyCondFs
+	policyCondUser
+	policyCondMethod
+	policyCondCidr
+	policyCondTime
+	policyCondReferer
+)
+
+var policyConditionNames = []string{"url", "fs", "user", "method", "cidr", "time", "referer"}
+
+// refererSelf matches referer host that is the same as request host
+const refererSelf = "self"
+
+// pathPattern matches url or file system path by glob,
+// "*" matches a path segment, "**" matches any sub paths,
+// "<dir>/**" also matches the dir itself.
+type pathPattern struct {
+	pattern string
+	prefix  string
+	re      *regexp.Regexp
+}
+
+func newPrefixPathPattern(prefix string, separator byte) *pathPattern {
+	pattern := prefix
+	if len(pattern) == 0 || pattern[len(pattern)-1] != separator {
+		pattern += string(separator)
+	}
+	return &pathPattern{pattern: pattern + "**", prefix: prefix}
+}
+
+func newPathPattern(pattern string, fs bool) (*pathPattern, error) {
+	slashPattern := pattern
+	if fs {
+		var err error
+		if pattern, err = filepath.Abs(pattern); err != nil {
+			return nil, err
+		}
+		slashPattern = filepath.ToSlash(pattern)
+	}
+
+	// "<dir>/**" without other wildcards is matched by prefix dir
+	if strings.HasSuffix(slashPattern, "/**") {
+		if dir := slashPattern[:len(slashPattern)-3]; !strings.ContainsAny(dir, "*?") {
+			if fs {
+				return &pathPattern{pattern: pattern, prefix: filepath.Clean(filepath.FromSlash(dir + "/"))}, nil
+			}
+			return &pathPattern{pattern: pattern, prefix: util.CleanUrlPath(dir)}, nil
+		}
+	}
+
+	buf := &strings.Builder{}
+	if fs && filepath.Separator == '\\' {
+		buf.WriteString("(?i)")
+	}
+	buf.WriteByte('^')
+	for i := 0; i < len(slashPattern); i++ {
+		switch c := slashPattern[i]; {
+		case strings.HasPrefix(slashPattern[i:], "/**") && i+3 == len(slashPattern):
+			buf.WriteString("(?:/.*)?")
+			i += 2
+		case strings.HasPrefix(slashPattern[i:], "**"):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteByte('$')
+
+	re, err := regexp.Compile(buf.String())
+	if err != nil {
+		return nil, err
+	}
+	return &pathPattern{pattern: pattern, re: re}, nil
+}
+
+func (pp *pathPattern) matchUrl(urlPath string) bool {
+	if pp.re == nil {
+		return util.HasUrlPrefixDir(urlPath, pp.prefix)
+	}
+	return pp.re.MatchString(urlPath)
+}
+
+func (pp *pathPattern) matchFs(fsPath string) bool {
+	if pp.re == nil {
+		return util.HasFsPrefixDir(fsPath, pp.prefix)
+	}
+	return pp.re.MatchString(filepath.ToSlash(fsPath))
+}
+
+// timeWindow is a range of week days and time of day in local time,
+// end can be less than start to wrap around.
+type timeWindow struct {
+	text        string
+	hasDays     bool
+	startDay    time.Weekday
+	endDay      time.Weekday
+	hasMinutes  bool
+	startMinute int
+	endMinute   int
+}
+
+func inWrappedRange(value, start, end int) bool {
+	if start <= end {
+		return value >= start && value <= end
+	}
+	return value >= start || value <= end
+}
+
+func (tw *timeWindow) contains(t time.Time) bool {
+	if tw.hasDays && !inWrappedRange(int(t.Weekday()), int(tw.startDay), int(tw.endDay)) {
+		return false
+	}
+	if tw.hasMinutes {
+		minute := t.Hour()*60 + t.Minute()
+		if tw.startMinute <= tw.endMinute {
+			return minute >= tw.startMinute && minute < tw.endMinute
+		}
+		return minute >= tw.startMinute || minute < tw.endMinute
+	}
+	return true
+}
+
+type policyCondition struct {
+	kind     policyConditionKind
+	negate   bool
+	values   []string
+	users    []string // values with groups expanded
+	patterns []*pathPattern
+	ipNets   []*net.IPNet
+	windows  []*timeWindow
+}
+
+func (cond *policyCondition) String() string {
+	op := "="
+	if cond.negate {
+		op = "!="
+	}
+	values := make([]string, len(cond.values))
+	for i, value := range cond.values {
+		if strings.ContainsAny(value, " \t,\"") {
+			value = strconv.Quote(value)
+		}
+		values[i] = value
+	}
+	return policyConditionNames[cond.kind] + op + strings.Join(values, ",")
+}
+
+type policyRule struct {
+	allow      bool
+	caps       policyCap
+	conditions []*policyCondition
+	// option that generates the rule
+	origin string
+}
+
+func (rule *policyRule) hasUserCondition() bool {
+	for _, cond := range rule.conditions {
+		if cond.kind == policyCondUser {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *policyRule) String() string {
+	buf := &strings.Builder{}
+	if rule.allow {
+		buf.WriteString("allow ")
+	} else {
+		buf.WriteString("deny ")
+	}
+	buf.WriteString(rule.caps.String())
+	for _, cond := range rule.conditions {
+		buf.WriteByte(' ')
+		buf.WriteString(cond.String())
+	}
+	return buf.String()
+}
+
+// policySubject is the request to be checked by policy.
+// Anonymous user has empty name and is not authenticated.
+type policySubject struct {
+	urlPath       string
+	fsPath        string
+	username      string
+	authenticated bool
+	// groups from OIDC session, only for user authenticated by the session
+	groups []string
+	method string
+	ip     net.IP
+	time   time.Time
+	// host of request, and host from `Referer` or `Origin` header
+	host    string
+	referer string
+}
+
+func newPolicySubject(r *http.Request, urlPath, fsPath, username string) *policySubject {
+	return &policySubject{
+		urlPath:       urlPath,
+		fsPath:        fsPath,
+		username:      username,
+		authenticated: len(username) > 0,
+		groups:        getSessionGroups(r),
+		method:        r.Method,
+		ip:            net.ParseIP(getRemoteIp(r.RemoteAddr)),
+		time:          time.Now(),
+		host:          strings.ToLower(r.Host),
+		referer:       getRefererHost(r),
+	}
+}
+
+type policyDecision struct {
+	allow bool
+	// rule that decides, nil for default
+	rule  *policyRule
+	index int
+	// a rule with user condition is matched except the user condition,
+	// thus the decision may change for another user
+	userDependent bool
+}
+
+// policy is an ordered list of rules,
+// the first rule that matches the subject decides a capability.
+type policy struct {
+	rules []*policyRule
+
+	users *user.List
+}
+
+func (p *policy) containsUser(names []string, subject *policySubject) bool {
+	if !subject.authenticated {
+		return false
+	}
+	username := subject.username
+	for _, name := range names {
+		if strings.HasPrefix(name, userGroupPrefix) {
+			if len(username) > 0 && util.Contains(subject.groups, name[len(userGroupPrefix):]) {
+				return true
+			}
+			continue
+		}
+		if name == anyUser || (len(username) > 0 && p.users.IsNameEqual(name, username)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *policy) matchCondition(cond *policyCondition, subject *policySubject) (matched bool) {
+	switch cond.kind {
+	case policyCondUrl:
+		for _, pattern := range cond.patterns {
+			if pattern.matchUrl(subject.urlPath) {
+				matched = true
+				break
+			}
+		}
+	case policyCondFs:
+		for _, pattern := range cond.patterns {
+			if pattern.matchFs(subject.fsPath) {
+				matched = true
+				break
+			}
+		}
+	case policyCondUser:
+		matched = p.containsUser(cond.users, subject)
+	case policyCondMethod:
+		matched = util.Contains(cond.values, subject.method)
+	case policyCondCidr:
+		matched = util.IPNetsContain(cond.ipNets, subject.ip)
+	case policyCondTime:
+		for _, window := range cond.windows {
+			if window.contains(subject.time) {
+				matched = true
+				break
+			}
+		}
+	case policyCondReferer:
+		for _, value := range cond.values {
+			if value == subject.referer || (value == refererSelf && subject.referer == subject.host) {
+				matched = true
+				break
+			}
+		}
+	}
+	return matched != cond.negate
+}
+
+func (p *policy) decide(capability policyCap, subject *policySubject) (decision policyDecision) {
+	if p != nil {
+	eachRule:
+		for i, rule := range p.rules {
+			if rule.caps&capability == 0 {
+				continue
+			}
+			userMatched := true
+			for _, cond := range rule.conditions {
+				if !p.matchCondition(cond, subject) {
+					if cond.kind != policyCondUser {
+						continue eachRule
+					}
+					userMatched = false
+				}
+			}
+			if rule.hasUserCondition() {
+				decision.userDependent = true
+			}
+			if userMatched {
+				decision.allow = rule.allow
+				decision.rule = rule
+				decision.index = i
+				return
+			}
+		}
+	}
+
+	decision.allow = policyCapsDefaultAllow&capability != 0
+	return
+}
+
+func (p *policy) allows(capability policyCap, subject *policySubject) bool {
+	return p.decide(capability, subject).allow
+}
+
+func (p *policy) has(capability policyCap) bool {
+	if p == nil {
+		return false
+	}
+	for _, rule := range p.rules {
+		if rule.caps&capability != 0 {
+			return true
+		}
+	}
+	return false
+}
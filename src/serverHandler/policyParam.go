@@ -0,0 +1,216 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/param"
+	"mjpclab.dev/ghfs/src/user"
+	"mjpclab.dev/ghfs/src/util"
+	"path/filepath"
+)
+
+func newUrlCondition(urlPath string) *policyCondition {
+	pattern := newPrefixPathPattern(urlPath, '/')
+	return &policyCondition{kind: policyCondUrl, values: []string{pattern.pattern}, patterns: []*pathPattern{pattern}}
+}
+
+func newFsCondition(fsPath string) *policyCondition {
+	pattern := newPrefixPathPattern(fsPath, filepath.Separator)
+	return &policyCondition{kind: policyCondFs, values: []string{pattern.pattern}, patterns: []*pathPattern{pattern}}
+}
+
+func newRefererCondition(hosts []string) *policyCondition {
+	return &policyCondition{kind: policyCondReferer, values: hosts}
+}
+
+// policyBuilder compiles permission options into rules.
+type policyBuilder struct {
+	policy     *policy
+	groups     map[string][]string
+	keepGroups bool
+	errs       []error
+}
+
+func (b *policyBuilder) add(allow bool, caps policyCap, origin string, conditions ...*policyCondition) {
+	rule := &policyRule{allow: allow, caps: caps, conditions: conditions, origin: origin}
+	b.expandUsers(rule)
+	b.policy.rules = append(b.policy.rules, rule)
+}
+
+func (b *policyBuilder) expandUsers(rule *policyRule) {
+	for _, cond := range rule.conditions {
+		if cond.kind == policyCondUser && cond.users == nil {
+			var errs []error
+			cond.users, errs = expandUserGroups(cond.values, b.groups, b.keepGroups)
+			b.errs = append(b.errs, errs...)
+		}
+	}
+}
+
+func (b *policyBuilder) addUrls(allow bool, caps policyCap, origin string, urls []string, conditions ...*policyCondition) {
+	for _, url := range urls {
+		b.add(allow, caps, origin, append([]*policyCondition{newUrlCondition(url)}, conditions...)...)
+	}
+}
+
+func (b *policyBuilder) addDirs(allow bool, caps policyCap, origin string, dirs []string, conditions ...*policyCondition) {
+	for _, dir := range dirs {
+		b.add(allow, caps, origin, append([]*policyCondition{newFsCondition(dir)}, conditions...)...)
+	}
+}
+
+func getEntryPaths(entries [][]string) []string {
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry[0])
+	}
+	return paths
+}
+
+// addPathUsers adds rules for entries of [path, users...].
+func (b *policyBuilder) addPathUsers(allow bool, caps policyCap, origin string, entries [][]string, isFs, negate bool) {
+	for _, entry := range entries {
+		pathCond := newUrlCondition(entry[0])
+		if isFs {
+			pathCond = newFsCondition(entry[0])
+		}
+		userCond := &policyCondition{kind: policyCondUser, negate: negate, values: entry[1:]}
+		b.add(allow, caps, origin, pathCond, userCond)
+	}
+}
+
+func (b *policyBuilder) newCidrCondition(ips []string) *policyCondition {
+	ipNets, errs := util.ParseIPNets(ips)
+	b.errs = append(b.errs, errs...)
+	return &policyCondition{kind: policyCondCidr, values: ips, ipNets: ipNets}
+}
+
+// addPathIPs adds rules for entries of [path, IPs...].
+func (b *policyBuilder) addPathIPs(allow bool, caps policyCap, origin string, entries [][]string, isFs bool) {
+	for _, entry := range entries {
+		pathCond := newUrlCondition(entry[0])
+		if isFs {
+			pathCond = newFsCondition(entry[0])
+		}
+		b.add(allow, caps, origin, pathCond, b.newCidrCondition(entry[1:]))
+	}
+}
+
+// addIPLists compiles IP allow and deny lists.
+// Deny lists take precedence, and if any allow list applies to the path,
+// client IP must be contained by one of them.
+func (b *policyBuilder) addIPLists(caps policyCap, optionPrefix string, globalAllows, globalDenies []string, allowUrls, allowDirs, denyUrls, denyDirs [][]string) {
+	if len(globalDenies) > 0 {
+		b.add(false, caps, "--global-"+optionPrefix+"deny-ip", b.newCidrCondition(globalDenies))
+	}
+	b.addPathIPs(false, caps, "--"+optionPrefix+"deny-ip", denyUrls, false)
+	b.addPathIPs(false, caps, "--"+optionPrefix+"deny-ip-dir", denyDirs, true)
+
+	if len(globalAllows) > 0 {
+		b.add(true, caps, "--global-"+optionPrefix+"allow-ip", b.newCidrCondition(globalAllows))
+	}
+	b.addPathIPs(true, caps, "--"+optionPrefix+"allow-ip", allowUrls, false)
+	b.addPathIPs(true, caps, "--"+optionPrefix+"allow-ip-dir", allowDirs, true)
+
+	if len(globalAllows) > 0 {
+		b.add(false, caps, "--global-"+optionPrefix+"allow-ip")
+	}
+	b.addUrls(false, caps, "--"+optionPrefix+"allow-ip", getEntryPaths(allowUrls))
+	b.addDirs(false, caps, "--"+optionPrefix+"allow-ip-dir", getEntryPaths(allowDirs))
+}
+
+// addRestrictAccess compiles restrict access options.
+// Current host and hosts listed for the path are allowed,
+// others are denied if any restrict access option applies to the path.
+func (b *policyBuilder) addRestrictAccess(globalHosts []string, urls, dirs [][]string) {
+	var origin string
+	switch {
+	case globalHosts != nil:
+		origin = "--global-restrict-access"
+	case len(urls) > 0:
+		origin = "--restrict-access"
+	case len(dirs) > 0:
+		origin = "--restrict-access-dir"
+	default:
+		return
+	}
+
+	b.add(true, policyCapReferer, origin, newRefererCondition([]string{refererSelf}))
+	if len(globalHosts) > 0 {
+		b.add(true, policyCapReferer, "--global-restrict-access", newRefererCondition(globalHosts))
+	}
+	for _, entry := range urls {
+		if len(entry) > 1 {
+			b.add(true, policyCapReferer, "--restrict-access", newUrlCondition(entry[0]), newRefererCondition(entry[1:]))
+		}
+	}
+	for _, entry := range dirs {
+		if len(entry) > 1 {
+			b.add(true, policyCapReferer, "--restrict-access-dir", newFsCondition(entry[0]), newRefererCondition(entry[1:]))
+		}
+	}
+
+	if globalHosts != nil {
+		b.add(false, policyCapReferer, "--global-restrict-access")
+	}
+	b.addUrls(false, policyCapReferer, "--restrict-access", getEntryPaths(urls))
+	b.addDirs(false, policyCapReferer, "--restrict-access-dir", getEntryPaths(dirs))
+}
+
+// addGrants compiles options that grant a capability globally, to paths, or to users of paths.
+func (b *policyBuilder) addGrants(caps policyCap, name string, global bool, urls, dirs []string, userUrls, userDirs [][]string) {
+	if global {
+		b.add(true, caps, "--global-"+name)
+	}
+	b.addUrls(true, caps, "--"+name, urls)
+	b.addDirs(true, caps, "--"+name+"-dir", dirs)
+	b.addPathUsers(true, caps, "--"+name+"-user", userUrls, false, false)
+	b.addPathUsers(true, caps, "--"+name+"-user-dir", userDirs, true, false)
+}
+
+// newPolicy builds rules from option "--rule",
+// followed by rules compiled from other permission options.
+// Group names of users are kept for matching groups of OIDC users.
+func newPolicy(p *param.Param, users *user.List, groups map[string][]string, keepGroups bool) (*policy, []error) {
+	b := &policyBuilder{
+		policy:     &policy{users: users},
+		groups:     groups,
+		keepGroups: keepGroups,
+	}
+
+	for _, input := range p.Rules {
+		rule, err := parsePolicyRule(input)
+		if err != nil {
+			b.errs = append(b.errs, err)
+			continue
+		}
+		b.expandUsers(rule)
+		b.policy.rules = append(b.policy.rules, rule)
+	}
+
+	// connect & mutate
+	b.addIPLists(policyCapConnect, "", p.GlobalAllowIPs, p.GlobalDenyIPs, p.AllowIPsUrls, p.AllowIPsDirs, p.DenyIPsUrls, p.DenyIPsDirs)
+	b.addIPLists(policyCapMutate, "mutate-", p.GlobalMutateAllowIPs, p.GlobalMutateDenyIPs, p.MutateAllowIPsUrls, p.MutateAllowIPsDirs, p.MutateDenyIPsUrls, p.MutateDenyIPsDirs)
+
+	// access
+	anyUserCond := func() *policyCondition {
+		return &policyCondition{kind: policyCondUser, negate: true, values: []string{anyUser}}
+	}
+	if p.GlobalAuth {
+		b.add(false, policyCapAccess, "--global-auth", anyUserCond())
+	}
+	b.addUrls(false, policyCapAccess, "--auth", p.AuthUrls, anyUserCond())
+	b.addDirs(false, policyCapAccess, "--auth-dir", p.AuthDirs, anyUserCond())
+	b.addPathUsers(false, policyCapAccess, "--auth-user", p.AuthUserUrls, false, true)
+	b.addPathUsers(false, policyCapAccess, "--auth-user-dir", p.AuthUserDirs, true, true)
+
+	// referer
+	b.addRestrictAccess(p.GlobalRestrictAccess, p.RestrictAccessUrls, p.RestrictAccessDirs)
+
+	// operations
+	b.addGrants(policyCapUpload, "upload", p.GlobalUpload, p.UploadUrls, p.UploadDirs, p.UploadUserUrls, p.UploadUserDirs)
+	b.addGrants(policyCapMkdir, "mkdir", p.GlobalMkdir, p.MkdirUrls, p.MkdirDirs, p.MkdirUserUrls, p.MkdirUserDirs)
+	b.addGrants(policyCapDelete, "delete", p.GlobalDelete, p.DeleteUrls, p.DeleteDirs, p.DeleteUserUrls, p.DeleteUserDirs)
+	b.addGrants(policyCapArchive, "archive", p.GlobalArchive, p.ArchiveUrls, p.ArchiveDirs, p.ArchiveUserUrls, p.ArchiveUserDirs)
+	b.addGrants(policyCapCors, "cors", p.GlobalCors, p.CorsUrls, p.CorsDirs, nil, nil)
+
+	return b.policy, b.errs
+}
@@ -0,0 +1,281 @@
+package serverHandler
+
+import (
+	"errors"
+	"mjpclab.dev/ghfs/src/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// splitQuoted splits input by separator outside of double quotes,
+// empty fields are removed.
+func splitQuoted(input string, isSeparator func(byte) bool) (fields []string, err error) {
+	start := -1
+	quoted := false
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case quoted:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quoted = false
+			}
+		case isSeparator(c):
+			if start >= 0 {
+				fields = append(fields, input[start:i])
+				start = -1
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+			if c == '"' {
+				quoted = true
+			}
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote: " + input)
+	}
+	if start >= 0 {
+		fields = append(fields, input[start:])
+	}
+	return
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isComma(c byte) bool {
+	return c == ','
+}
+
+func parsePolicyCaps(input string) (caps policyCap, err error) {
+	for _, name := range strings.Split(input, ",") {
+		if name == "*" {
+			caps |= policyCapAll
+			continue
+		}
+		found := false
+		for _, capName := range policyCapNames {
+			if strings.EqualFold(name, capName.name) {
+				caps |= capName.cap
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, errors.New("unknown capability: " + name)
+		}
+	}
+	return
+}
+
+func parseDayMinute(input string) (int, error) {
+	colonIndex := strings.IndexByte(input, ':')
+	if colonIndex <= 0 {
+		return 0, errors.New("invalid time: " + input)
+	}
+	hour, err := strconv.Atoi(input[:colonIndex])
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, errors.New("invalid time: " + input)
+	}
+	minute, err := strconv.Atoi(input[colonIndex+1:])
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, errors.New("invalid time: " + input)
+	}
+	return hour*60 + minute, nil
+}
+
+func parseWeekday(input string) (time.Weekday, error) {
+	day, ok := weekdayNames[strings.ToLower(input)]
+	if !ok {
+		return 0, errors.New("invalid week day: " + input)
+	}
+	return day, nil
+}
+
+// parseTimeWindow parses "[<day>[-<day>]@]<hh:mm>-<hh:mm>" or "<day>[-<day>]",
+// e.g. "Mon-Fri@09:00-18:00", "22:00-06:00", "Sat-Sun".
+func parseTimeWindow(input string) (window *timeWindow, err error) {
+	window = &timeWindow{text: input}
+
+	days := ""
+	minutes := input
+	if atIndex := strings.IndexByte(input, '@'); atIndex >= 0 {
+		days = input[:atIndex]
+		minutes = input[atIndex+1:]
+	} else if len(input) > 0 && (input[0] < '0' || input[0] > '9') {
+		days = input
+		minutes = ""
+	}
+
+	if len(days) > 0 {
+		window.hasDays = true
+		dashIndex := strings.IndexByte(days, '-')
+		if dashIndex < 0 {
+			window.startDay, err = parseWeekday(days)
+			window.endDay = window.startDay
+		} else {
+			if window.startDay, err = parseWeekday(days[:dashIndex]); err == nil {
+				window.endDay, err = parseWeekday(days[dashIndex+1:])
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(minutes) > 0 {
+		window.hasMinutes = true
+		dashIndex := strings.IndexByte(minutes, '-')
+		if dashIndex < 0 {
+			return nil, errors.New("invalid time range: " + minutes)
+		}
+		if window.startMinute, err = parseDayMinute(minutes[:dashIndex]); err == nil {
+			window.endMinute, err = parseDayMinute(minutes[dashIndex+1:])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !window.hasDays && !window.hasMinutes {
+		return nil, errors.New("invalid time window: " + input)
+	}
+	return
+}
+
+func parsePolicyCondition(input string) (cond *policyCondition, err error) {
+	eqIndex := strings.IndexByte(input, '=')
+	if eqIndex <= 0 {
+		return nil, errors.New("invalid condition: " + input)
+	}
+	name := input[:eqIndex]
+	cond = &policyCondition{}
+	if name[len(name)-1] == '!' {
+		cond.negate = true
+		name = name[:len(name)-1]
+	}
+
+	found := false
+	for i, condName := range policyConditionNames {
+		if strings.EqualFold(name, condName) {
+			cond.kind = policyConditionKind(i)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, errors.New("unknown condition: " + name)
+	}
+
+	values, err := splitQuoted(input[eqIndex+1:], isComma)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.New("empty condition: " + input)
+	}
+	for i, value := range values {
+		if value[0] == '"' {
+			if values[i], err = strconv.Unquote(value); err != nil {
+				return nil, errors.New("invalid quoted value: " + value)
+			}
+		}
+	}
+	cond.values = values
+
+	switch cond.kind {
+	case policyCondUrl, policyCondFs:
+		for _, value := range values {
+			if cond.kind == policyCondUrl && value[0] != '/' {
+				return nil, errors.New("url pattern should start with '/': " + value)
+			}
+			pattern, err := newPathPattern(value, cond.kind == policyCondFs)
+			if err != nil {
+				return nil, err
+			}
+			cond.patterns = append(cond.patterns, pattern)
+		}
+	case policyCondMethod:
+		for i := range values {
+			values[i] = strings.ToUpper(values[i])
+		}
+	case policyCondCidr:
+		var errs []error
+		if cond.ipNets, errs = util.ParseIPNets(values); len(errs) > 0 {
+			return nil, errs[0]
+		}
+	case policyCondTime:
+		for _, value := range values {
+			window, err := parseTimeWindow(value)
+			if err != nil {
+				return nil, err
+			}
+			cond.windows = append(cond.windows, window)
+		}
+	case policyCondReferer:
+		for i := range values {
+			if values[i] != refererSelf {
+				values[i] = util.ExtractHostFromUrl(values[i])
+			}
+		}
+	}
+
+	return
+}
+
+// parsePolicyRule parses rule in form of
+// "<allow|deny> <capability>[,<capability>...] [<condition>[!]=<value>[,<value>...]]...",
+// e.g. "allow upload,mkdir url=/incoming/** user=@staff cidr=10.0.0.0/8".
+// Values that contain spaces or commas can be double-quoted.
+func parsePolicyRule(input string) (rule *policyRule, err error) {
+	fields, err := splitQuoted(input, isSpace)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) < 2 {
+		return nil, errors.New("invalid rule: " + input)
+	}
+
+	rule = &policyRule{origin: "--rule"}
+	switch strings.ToLower(fields[0]) {
+	case "allow":
+		rule.allow = true
+	case "deny":
+	default:
+		return nil, errors.New("rule should start with allow or deny: " + input)
+	}
+
+	if rule.caps, err = parsePolicyCaps(fields[1]); err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields[2:] {
+		cond, err := parsePolicyCondition(field)
+		if err != nil {
+			return nil, err
+		}
+		rule.conditions = append(rule.conditions, cond)
+	}
+
+	if rule.caps&policyCapsBeforeAuth != 0 && rule.hasUserCondition() {
+		return nil, errors.New("user condition is not supported by connect, mutate or referer, which are checked regardless of user: " + input)
+	}
+
+	return
+}
@@ -0,0 +1,267 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/param"
+	"mjpclab.dev/ghfs/src/user"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParsePolicyRule(t *testing.T) {
+	rule, err := parsePolicyRule(`allow upload,MKDIR url=/in/**,"/a b/*" user!=alice method=put cidr=10.0.0.0/8 time=Mon-Fri@09:00-18:00`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rule.allow || rule.caps != policyCapUpload|policyCapMkdir || len(rule.conditions) != 5 {
+		t.Fatal(rule)
+	}
+	if rule.conditions[0].values[1] != "/a b/*" {
+		t.Error(rule.conditions[0].values)
+	}
+	if !rule.conditions[1].negate {
+		t.Error("user condition should be negated")
+	}
+	if rule.conditions[2].values[0] != "PUT" {
+		t.Error(rule.conditions[2].values)
+	}
+	if str := rule.String(); str != `allow upload,mkdir url=/in/**,"/a b/*" user!=alice method=PUT cidr=10.0.0.0/8 time=Mon-Fri@09:00-18:00` {
+		t.Error(str)
+	}
+
+	rule, err = parsePolicyRule("deny * cidr=192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.allow || rule.caps != policyCapAll || rule.String() != "deny * cidr=192.0.2.0/24" {
+		t.Error(rule)
+	}
+
+	for _, input := range []string{
+		"allow",
+		"grant upload",
+		"allow write",
+		"allow upload url",
+		"allow upload url=in",
+		"allow upload size=1",
+		"allow upload cidr=10.0.0.0/33",
+		"allow upload time=25:00-26:00",
+		"allow upload time=Someday",
+		`allow upload url="/a`,
+		"deny connect user=alice",
+		"deny mutate,upload user!=*",
+		"deny referer user=alice",
+	} {
+		if _, err := parsePolicyRule(input); err == nil {
+			t.Error("should fail:", input)
+		}
+	}
+}
+
+func TestPathPattern(t *testing.T) {
+	expects := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/a/**", "/a", true},
+		{"/a/**", "/a/b/c", true},
+		{"/a/**", "/ab", false},
+		{"/a/*", "/a/b", true},
+		{"/a/*", "/a/b/c", false},
+		{"/a/*/c", "/a/b/c", true},
+		{"/**/*.txt", "/a/b/c.txt", true},
+		{"/**/*.txt", "/a/b/c.md", false},
+		{"/a/?", "/a/b", true},
+		{"/a/?", "/a/bc", false},
+		{"/a/*/**", "/a/b", true},
+		{"/a/*/**", "/a/b/c", true},
+		{"/a/*/**", "/a", false},
+		{"/a.b", "/axb", false},
+	}
+	for _, expect := range expects {
+		pattern, err := newPathPattern(expect.pattern, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match := pattern.matchUrl(expect.path); match != expect.match {
+			t.Error(expect.pattern, expect.path, match)
+		}
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	// 2024-01-01 is Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+	}
+
+	window, _ := parseTimeWindow("Mon-Fri@09:00-18:00")
+	if !window.contains(at(1, 9, 0)) || window.contains(at(1, 18, 0)) || window.contains(at(6, 10, 0)) {
+		t.Error(window.text)
+	}
+
+	window, _ = parseTimeWindow("22:00-06:00")
+	if !window.contains(at(3, 23, 0)) || !window.contains(at(3, 5, 59)) || window.contains(at(3, 6, 0)) {
+		t.Error(window.text)
+	}
+
+	window, _ = parseTimeWindow("Fri-Mon")
+	if !window.contains(at(7, 12, 0)) || !window.contains(at(1, 12, 0)) || window.contains(at(3, 12, 0)) {
+		t.Error(window.text)
+	}
+}
+
+func TestPolicyDecide(t *testing.T) {
+	users := user.NewList(false)
+	pol, errs := newPolicy(&param.Param{
+		Rules: []string{
+			"deny upload url=/in/locked/**",
+			"allow upload,mkdir url=/in/** user=alice",
+			"deny access url=/private/** user!=*",
+			"deny access url=/closed/**",
+		},
+		GlobalUpload: true,
+	}, users, nil, false)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	subject := func(urlPath, username string) *policySubject {
+		return &policySubject{urlPath: urlPath, username: username, authenticated: len(username) > 0, ip: net.ParseIP("192.0.2.1"), time: time.Now()}
+	}
+
+	// first match
+	if pol.allows(policyCapUpload, subject("/in/locked/a", "alice")) {
+		t.Error("first matched deny rule should decide")
+	}
+	if decision := pol.decide(policyCapMkdir, subject("/in/a", "alice")); !decision.allow || decision.index != 1 {
+		t.Error(decision)
+	}
+
+	// compiled from options after rules
+	if decision := pol.decide(policyCapUpload, subject("/in/a", "bob")); !decision.allow || decision.rule.origin != "--global-upload" {
+		t.Error(decision)
+	}
+
+	// default
+	if decision := pol.decide(policyCapMkdir, subject("/in/a", "bob")); decision.allow || decision.rule != nil || !decision.userDependent {
+		t.Error(decision)
+	}
+	if decision := pol.decide(policyCapArchive, subject("/", "")); decision.allow || decision.userDependent {
+		t.Error(decision)
+	}
+
+	// access
+	if decision := pol.decide(policyCapAccess, subject("/private/a", "")); decision.allow || !decision.userDependent {
+		t.Error(decision)
+	}
+	if !pol.allows(policyCapAccess, subject("/private/a", "bob")) {
+		t.Error("authenticated user should access /private")
+	}
+	if decision := pol.decide(policyCapAccess, subject("/closed", "bob")); decision.allow || decision.userDependent {
+		t.Error(decision)
+	}
+
+	var nilPolicy *policy
+	if !nilPolicy.allows(policyCapAccess, subject("/", "")) || nilPolicy.allows(policyCapUpload, subject("/", "")) {
+		t.Error("nil policy should use default")
+	}
+}
+
+func TestPolicyIPLists(t *testing.T) {
+	pol, errs := newPolicy(&param.Param{
+		GlobalDenyIPs: []string{"192.0.2.66"},
+		AllowIPsUrls:  [][]string{{"/office", "10.0.0.0/8"}, {"/office/shared", "192.0.2.0/24"}},
+		AllowIPsDirs:  [][]string{{"/fs/private", "127.0.0.1", "::1"}},
+		DenyIPsUrls:   [][]string{{"/office/secret", "10.0.1.0/24"}},
+	}, user.NewList(false), nil, false)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []struct {
+		ip      string
+		urlPath string
+		fsPath  string
+		allowed bool
+	}{
+		{"192.0.2.1", "/", "/fs", true},
+		{"192.0.2.66", "/", "/fs", false},
+		{"10.0.0.1", "/office/a", "/fs/office/a", true},
+		{"192.0.2.1", "/office/a", "/fs/office/a", false},
+		{"192.0.2.1", "/office/shared/a", "/fs/office/shared/a", true},
+		{"10.0.0.1", "/office/shared/a", "/fs/office/shared/a", true},
+		{"10.0.0.1", "/office/secret", "/fs/office/secret", true},
+		{"10.0.1.1", "/office/secret", "/fs/office/secret", false},
+		{"10.0.1.1", "/officex", "/fs/officex", true},
+		{"::1", "/private", "/fs/private", true},
+		{"192.0.2.1", "/private/a", "/fs/private/a", false},
+		{"", "/office", "/fs/office", false},
+	}
+	for _, expect := range expects {
+		subject := &policySubject{urlPath: expect.urlPath, fsPath: expect.fsPath, ip: net.ParseIP(expect.ip)}
+		if allowed := pol.allows(policyCapConnect, subject); allowed != expect.allowed {
+			t.Error(expect.ip, expect.urlPath, allowed)
+		}
+		if !pol.allows(policyCapMutate, subject) {
+			t.Error("mutate should be allowed by default")
+		}
+	}
+
+	_, errs = newPolicy(&param.Param{
+		GlobalAllowIPs: []string{"10.0.0.0/33"},
+		GlobalDenyIPs:  []string{"localhost"},
+	}, user.NewList(false), nil, false)
+	if len(errs) != 2 {
+		t.Error(errs)
+	}
+}
+
+func TestPolicyRestrictAccess(t *testing.T) {
+	pol, errs := newPolicy(&param.Param{
+		Rules:              []string{"allow referer url=/embed/** referer=https://partner.example.com/page"},
+		RestrictAccessUrls: [][]string{{"/media", "cdn.example.com"}, {"/private"}},
+		RestrictAccessDirs: [][]string{{"/fs/shared", "friend.example.com"}},
+	}, user.NewList(false), nil, false)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []struct {
+		urlPath string
+		fsPath  string
+		referer string
+		allowed bool
+	}{
+		{"/", "/fs", "other.example.com", true},
+		{"/media/a", "/fs/media/a", "example.com", true},
+		{"/media/a", "/fs/media/a", "cdn.example.com", true},
+		{"/media/a", "/fs/media/a", "other.example.com", false},
+		{"/media/a", "/fs/media/a", "", false},
+		{"/private/a", "/fs/private/a", "cdn.example.com", false},
+		{"/private/a", "/fs/private/a", "example.com", true},
+		{"/shared/a", "/fs/shared/a", "friend.example.com", true},
+		{"/shared/a", "/fs/shared/a", "cdn.example.com", false},
+		{"/embed/a", "/fs/embed/a", "partner.example.com", true},
+	}
+	for _, expect := range expects {
+		subject := &policySubject{urlPath: expect.urlPath, fsPath: expect.fsPath, host: "example.com", referer: expect.referer}
+		if allowed := pol.allows(policyCapReferer, subject); allowed != expect.allowed {
+			t.Error(expect.urlPath, expect.referer, allowed)
+		}
+	}
+
+	pol, errs = newPolicy(&param.Param{GlobalRestrictAccess: []string{}}, user.NewList(false), nil, false)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	subject := &policySubject{urlPath: "/a", fsPath: "/fs/a", host: "example.com", referer: "other.example.com"}
+	if decision := pol.decide(policyCapReferer, subject); decision.allow || decision.rule.origin != "--global-restrict-access" {
+		t.Error(decision)
+	}
+	subject.referer = "example.com"
+	if decision := pol.decide(policyCapReferer, subject); !decision.allow || decision.rule.String() != "allow referer referer=self" {
+		t.Error(decision)
+	}
+}
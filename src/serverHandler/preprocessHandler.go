@@ -9,14 +9,26 @@ import (
 
 type preprocessHandler struct {
 	logger         *serverLog.Logger
+	forwardAuth    *forwardAuth
 	preMiddlewares []middleware.Middleware
 	nextHandler    http.Handler
 }
 
 func (pph preprocessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w = tryGetLoggableResponseWriter(w, r, pph.logger)
+	w, r = tryGetLoggableResponseWriter(w, r, pph.logger)
 	rw := serverCompress.NewResponseWriter(w, r)
 
+	if pph.forwardAuth != nil {
+		username, allowed := pph.forwardAuth.check(rw, r, pph.logger)
+		if !allowed {
+			rw.Close()
+			return
+		}
+		if len(username) > 0 {
+			r = withForwardAuthUser(r, username)
+		}
+	}
+
 	if len(pph.preMiddlewares) > 0 {
 		status := 0
 		middlewareContext := &middleware.Context{
@@ -40,9 +52,10 @@ func (pph preprocessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rw.Close()
 }
 
-func newPreprocessHandler(logger *serverLog.Logger, preMiddlewares []middleware.Middleware, nextHandler http.Handler) http.Handler {
+func newPreprocessHandler(logger *serverLog.Logger, forwardAuth *forwardAuth, preMiddlewares []middleware.Middleware, nextHandler http.Handler) http.Handler {
 	return preprocessHandler{
 		logger:         logger,
+		forwardAuth:    forwardAuth,
 		preMiddlewares: preMiddlewares,
 		nextHandler:    nextHandler,
 	}
@@ -0,0 +1,180 @@
+package serverHandler
+
+import (
+	"context"
+	"mjpclab.dev/ghfs/src/util"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type forwardedProtoKey struct{}
+type forwardedPrefixKey struct{}
+
+// proxyHandler restores client information from headers set by trusted reverse proxies,
+// e.g. "X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Forwarded-Prefix" and "Forwarded".
+// Headers from other peers are ignored.
+type proxyHandler struct {
+	trustedProxies []*net.IPNet
+	nextHandler    http.Handler
+}
+
+// forwardedElement is an element of "Forwarded" header(RFC 7239), e.g. `for=192.0.2.1;proto=https;host=example.com`.
+type forwardedElement map[string]string
+
+func parseForwarded(values []string) (elements []forwardedElement) {
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			pairs := forwardedElement{}
+			for _, pair := range strings.Split(element, ";") {
+				eqIndex := strings.IndexByte(pair, '=')
+				if eqIndex <= 0 {
+					continue
+				}
+				key := strings.ToLower(strings.TrimSpace(pair[:eqIndex]))
+				val := strings.TrimSpace(pair[eqIndex+1:])
+				if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
+					val = val[1 : len(val)-1]
+				}
+				pairs[key] = val
+			}
+			elements = append(elements, pairs)
+		}
+	}
+	return
+}
+
+// parseForwardedNode parses IP and optional port from node of "Forwarded" or "X-Forwarded-For" header,
+// e.g. `192.0.2.1`, `192.0.2.1:8080`, `[2001:db8::1]:8080`.
+func parseForwardedNode(node string) (ip net.IP, port string) {
+	if host, p, err := net.SplitHostPort(node); err == nil {
+		node = host
+		port = p
+	}
+	return net.ParseIP(strings.Trim(node, "[]")), port
+}
+
+func splitHeaderValues(values []string) (results []string) {
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			results = append(results, strings.TrimSpace(item))
+		}
+	}
+	return
+}
+
+// getClientIndex walks forwarded addresses from nearest proxy to farthest,
+// the first address that is not a trusted proxy is the client.
+// Items on the left of it are provided by client and cannot be trusted.
+// Returns -1 if no valid address.
+func (ph proxyHandler) getClientIndex(addrs []net.IP) int {
+	index := -1
+	for i := len(addrs) - 1; i >= 0; i-- {
+		if addrs[i] == nil {
+			break
+		}
+		index = i
+		if !util.IPNetsContain(ph.trustedProxies, addrs[i]) {
+			break
+		}
+	}
+	return index
+}
+
+// getForwardedItem gets item of "X-Forwarded-Proto" or "X-Forwarded-Host" appended along with client address,
+// or the one from nearest proxy if the proxies do not append them for each hop.
+func getForwardedItem(items []string, clientIndex, addrCount int) string {
+	if len(items) == addrCount && clientIndex >= 0 {
+		return items[clientIndex]
+	}
+	if len(items) > 0 {
+		return items[len(items)-1]
+	}
+	return ""
+}
+
+func (ph proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	peerIp := net.ParseIP(getRemoteIp(r.RemoteAddr))
+	if !util.IPNetsContain(ph.trustedProxies, peerIp) {
+		ph.nextHandler.ServeHTTP(w, r)
+		return
+	}
+
+	header := r.Header
+	var clientAddrs []net.IP
+	var clientPorts []string
+	var proto, host string
+	clientIndex := -1
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		elements := parseForwarded(values)
+		for _, element := range elements {
+			ip, port := parseForwardedNode(element["for"])
+			clientAddrs = append(clientAddrs, ip)
+			clientPorts = append(clientPorts, port)
+		}
+		clientIndex = ph.getClientIndex(clientAddrs)
+		element := elements[len(elements)-1]
+		if clientIndex >= 0 {
+			element = elements[clientIndex]
+		}
+		proto = element["proto"]
+		host = element["host"]
+	} else {
+		for _, addr := range splitHeaderValues(header.Values("X-Forwarded-For")) {
+			ip, port := parseForwardedNode(addr)
+			clientAddrs = append(clientAddrs, ip)
+			clientPorts = append(clientPorts, port)
+		}
+		clientIndex = ph.getClientIndex(clientAddrs)
+		proto = getForwardedItem(splitHeaderValues(header.Values("X-Forwarded-Proto")), clientIndex, len(clientAddrs))
+		host = getForwardedItem(splitHeaderValues(header.Values("X-Forwarded-Host")), clientIndex, len(clientAddrs))
+	}
+
+	if clientIndex >= 0 {
+		port := clientPorts[clientIndex]
+		if len(port) == 0 {
+			port = "0"
+		}
+		r.RemoteAddr = net.JoinHostPort(clientAddrs[clientIndex].String(), port)
+	}
+	if len(host) > 0 {
+		r.Host = host
+	}
+
+	ctx := r.Context()
+	if proto = strings.ToLower(proto); proto == "http" || proto == "https" {
+		ctx = context.WithValue(ctx, forwardedProtoKey{}, proto)
+	}
+	if prefix := header.Get("X-Forwarded-Prefix"); len(prefix) > 0 && prefix[0] == '/' {
+		if prefix = util.CleanUrlPath(prefix); prefix != "/" {
+			ctx = context.WithValue(ctx, forwardedPrefixKey{}, prefix)
+			r.RequestURI = prefix + r.RequestURI
+		}
+	}
+
+	ph.nextHandler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func newProxyHandler(trustedProxies []*net.IPNet, nextHandler http.Handler) http.Handler {
+	return proxyHandler{trustedProxies, nextHandler}
+}
+
+// getForwardedProto gets scheme of request from client to trusted proxy.
+func getForwardedProto(r *http.Request) (proto string, ok bool) {
+	proto, ok = r.Context().Value(forwardedProtoKey{}).(string)
+	return
+}
+
+// isHttps checks if client requests by HTTPS, directly or through trusted proxy.
+func isHttps(r *http.Request) bool {
+	if proto, ok := getForwardedProto(r); ok {
+		return proto == "https"
+	}
+	return r.TLS != nil
+}
+
+// getForwardedPrefix gets url prefix stripped by trusted proxy, which should be prepended to generated urls.
+func getForwardedPrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(forwardedPrefixKey{}).(string)
+	return prefix
+}
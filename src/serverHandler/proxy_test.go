@@ -0,0 +1,142 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/util"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseForwarded(t *testing.T) {
+	elements := parseForwarded([]string{`for=192.0.2.1;proto=https, For="[2001:db8::1]:4711"`, `for=192.0.2.2;host=example.com`})
+	if len(elements) != 3 {
+		t.Fatal(len(elements))
+	}
+	if elements[0]["for"] != "192.0.2.1" || elements[0]["proto"] != "https" {
+		t.Error(elements[0])
+	}
+	if elements[1]["for"] != "[2001:db8::1]:4711" {
+		t.Error(elements[1])
+	}
+	if elements[2]["for"] != "192.0.2.2" || elements[2]["host"] != "example.com" {
+		t.Error(elements[2])
+	}
+}
+
+func TestParseForwardedNode(t *testing.T) {
+	for node, expect := range map[string][2]string{
+		"192.0.2.1":          {"192.0.2.1", ""},
+		"192.0.2.1:8080":     {"192.0.2.1", "8080"},
+		"2001:db8::1":        {"2001:db8::1", ""},
+		"[2001:db8::1]":      {"2001:db8::1", ""},
+		"[2001:db8::1]:8080": {"2001:db8::1", "8080"},
+	} {
+		if ip, port := parseForwardedNode(node); ip == nil || ip.String() != expect[0] || port != expect[1] {
+			t.Error(node, ip, port)
+		}
+	}
+	if ip, _ := parseForwardedNode("unknown"); ip != nil {
+		t.Error(ip)
+	}
+}
+
+func TestProxyHandler(t *testing.T) {
+	trustedProxies, errs := util.ParseIPNets([]string{"10.0.0.0/8", "192.0.2.10"})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	var r *http.Request
+	ph := newProxyHandler(trustedProxies, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r = req
+	}))
+
+	newRequest := func(remoteAddr string, headers map[string]string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/a/b", nil)
+		req.RequestURI = "/a/b"
+		req.RemoteAddr = remoteAddr
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return req
+	}
+
+	// untrusted peer
+	ph.ServeHTTP(nil, newRequest("198.51.100.1:1234", map[string]string{
+		"X-Forwarded-For":   "192.0.2.1",
+		"X-Forwarded-Proto": "https",
+	}))
+	if r.RemoteAddr != "198.51.100.1:1234" || isHttps(r) {
+		t.Error(r.RemoteAddr)
+	}
+
+	// X-Forwarded-*
+	ph.ServeHTTP(nil, newRequest("10.0.0.1:1234", map[string]string{
+		"X-Forwarded-For":    "203.0.113.1, 192.0.2.1, 192.0.2.10",
+		"X-Forwarded-Proto":  "https",
+		"X-Forwarded-Host":   "example.com",
+		"X-Forwarded-Prefix": "/files/",
+	}))
+	if r.RemoteAddr != "192.0.2.1:0" {
+		t.Error(r.RemoteAddr)
+	}
+	if !isHttps(r) {
+		t.Error("should be https")
+	}
+	if r.Host != "example.com" {
+		t.Error(r.Host)
+	}
+	if prefix := getForwardedPrefix(r); prefix != "/files" {
+		t.Error(prefix)
+	}
+	if r.RequestURI != "/files/a/b" {
+		t.Error(r.RequestURI)
+	}
+
+	// Forwarded
+	ph.ServeHTTP(nil, newRequest("10.0.0.1:1234", map[string]string{
+		"Forwarded":         `for="[2001:db8::1]:4711";proto=http;host=example.org, for=192.0.2.10`,
+		"X-Forwarded-For":   "192.0.2.1",
+		"X-Forwarded-Proto": "https",
+	}))
+	if r.RemoteAddr != "[2001:db8::1]:4711" {
+		t.Error(r.RemoteAddr)
+	}
+	if isHttps(r) {
+		t.Error("should not be https")
+	}
+	if r.Host != "example.org" {
+		t.Error(r.Host)
+	}
+
+	// items provided by client
+	ph.ServeHTTP(nil, newRequest("10.0.0.1:1234", map[string]string{
+		"X-Forwarded-For":   "203.0.113.1, 192.0.2.1",
+		"X-Forwarded-Proto": "https, http",
+		"X-Forwarded-Host":  "evil.example, example.com",
+	}))
+	if isHttps(r) || r.Host != "example.com" {
+		t.Error("should use items appended by trusted proxy", r.Host)
+	}
+	ph.ServeHTTP(nil, newRequest("10.0.0.1:1234", map[string]string{
+		"X-Forwarded-For":   "192.0.2.1",
+		"X-Forwarded-Proto": "https, http",
+	}))
+	if isHttps(r) {
+		t.Error("should use item appended by nearest proxy")
+	}
+	ph.ServeHTTP(nil, newRequest("10.0.0.1:1234", map[string]string{
+		"Forwarded": `for=203.0.113.1;proto=https;host=evil.example, for=192.0.2.1;proto=http;host=example.com, for=192.0.2.10`,
+	}))
+	if r.RemoteAddr != "192.0.2.1:0" || isHttps(r) || r.Host != "example.com" {
+		t.Error("should use element appended by trusted proxy", r.RemoteAddr, r.Host)
+	}
+
+	// obfuscated client
+	ph.ServeHTTP(nil, newRequest("10.0.0.1:1234", map[string]string{
+		"Forwarded": `for=_hidden`,
+	}))
+	if r.RemoteAddr != "10.0.0.1:1234" {
+		t.Error(r.RemoteAddr)
+	}
+}
@@ -0,0 +1,219 @@
+package serverHandler
+
+import (
+	"io"
+	"mjpclab.dev/ghfs/src/user"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// isETagMatch reports whether any entity tag in header value matches etag.
+// Weak comparison is used, and "*" matches any existing item.
+func isETagMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPutPreconditions checks "If-Match" and "If-None-Match" headers against existing item.
+func checkPutPreconditions(r *http.Request, item os.FileInfo) bool {
+	etag := ""
+	if item != nil {
+		etag = getETag(item)
+	}
+
+	if ifMatch := r.Header.Get("If-Match"); len(ifMatch) > 0 {
+		if item == nil || !isETagMatch(ifMatch, etag) {
+			return false
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); len(ifNoneMatch) > 0 {
+		if item != nil && isETagMatch(ifNoneMatch, etag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasPutPreconditions(r *http.Request) bool {
+	return len(r.Header.Get("If-Match")) > 0 || len(r.Header.Get("If-None-Match")) > 0
+}
+
+// getPutBase finds nearest existing ancestor directory of target in current alias,
+// which decides permissions of uploading.
+// fsInfix is the relative path of intermediate directories need to be created.
+func (h *aliasHandler) getPutBase(target *davTarget) (rawReqPath, fsPath, fsInfix string, info os.FileInfo) {
+	rawReqPath = target.parentRawReqPath
+	fsPath = target.parentFsPath
+	info = target.parentItem
+
+	for info == nil && !h.emptyRoot && len(rawReqPath) > len(h.aliasPrefix) {
+		fsInfix = path.Join(path.Base(rawReqPath), fsInfix)
+		rawReqPath = path.Dir(rawReqPath)
+		fsPath = filepath.Dir(fsPath)
+		info, _ = os.Stat(fsPath)
+	}
+
+	return
+}
+
+// put saves raw request body as file of request path,
+// e.g. uploading by "curl -T".
+func (h *aliasHandler) put(w http.ResponseWriter, r *http.Request, data *responseData) {
+	if data.File != nil {
+		// release file handle before modifying it
+		data.File.Close()
+	}
+
+	if h.logError(h.checkCsrf(r, data)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	target, ok := h.getDavTarget(data.rawReqPath)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	baseRawReqPath, baseFsPath, fsInfix, baseItem := h.getPutBase(target)
+	if baseItem == nil || !baseItem.IsDir() {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	canUpload := tokenAllows(data.authToken, user.TokenPermUpload)
+	createDir := canUpload && h.getCanMkdir(r, baseItem, baseRawReqPath, baseFsPath, data.AuthUserName)
+	overwriteExists := tokenAllows(data.authToken, user.TokenPermDelete) && h.getCanDelete(r, baseItem, baseRawReqPath, baseFsPath, data.AuthUserName)
+	if !canUpload || !h.getCanUpload(r, baseItem, baseRawReqPath, baseFsPath, data.AuthUserName) || (len(fsInfix) > 0 && !createDir) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	_, baseAliasSubItems, errs := h.mergeAlias(baseRawReqPath, baseItem, nil, true)
+	h.logErrors(errs)
+	firstName := target.name
+	if len(fsInfix) > 0 {
+		firstName = fsInfix
+		if slashIndex := strings.IndexByte(firstName, '/'); slashIndex > 0 {
+			firstName = firstName[:slashIndex]
+		}
+	}
+	if containsItem(baseAliasSubItems, firstName) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	filePath := path.Join(fsInfix, target.name)
+	if err := h.checkUploadFilePath(filePath); err != nil {
+		uploadErr := err.(*uploadError)
+		http.Error(w, uploadErr.message, uploadErr.status)
+		return
+	}
+
+	// existing items in drop box are not revealed
+	item := data.Item
+	isDropbox := h.isDropbox(target.rawReqPath, target.fsPath)
+	if isDropbox {
+		item = nil
+	}
+	if item != nil && item.IsDir() {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkPutPreconditions(r, item) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	// conditional request decides overwriting by itself,
+	// otherwise follows upload conflict policy as uploading by form
+	resolveConflict := isDropbox || (item != nil && !hasPutPreconditions(r))
+	if item != nil && !resolveConflict && !overwriteExists {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if h.davLocks.isLocked(target.fsPath, false, r.Header.Get("If")) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+
+	if len(fsInfix) > 0 {
+		if h.logError(os.MkdirAll(target.parentFsPath, 0755)) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !h.limitUploadBody(w, r) {
+		w.WriteHeader(errUploadBodyTooLarge.status)
+		return
+	}
+	expectedDigests, err := getExpectedDigests(textproto.MIMEHeader(r.Header), "")
+	var content io.Reader
+	if err == nil {
+		content, err = h.newUploadLimitReader(r.Body, target.parentFsPath, 0)
+	}
+	if err == nil {
+		content, err = h.checkUploadContent(target.name, content)
+	}
+	mode := uploadCommitReplace
+	if err == nil && resolveConflict {
+		conflict := h.getUploadConflict(target.parentRawReqPath, target.parentFsPath, overwriteExists)
+		var errs []error
+		_, mode, _, errs = getUploadFsPath(target.parentFsPath, "", target.name, false, conflict, time.Time{}, nil)
+		if len(errs) > 0 {
+			err = errs[0]
+		} else if mode != uploadCommitReplace {
+			item = nil
+		}
+	}
+	exists := item != nil
+	var digester *uploadDigester
+	if err == nil {
+		h.logUpload(data.AuthUserName, target.name, target.fsPath, r)
+		digester = newUploadDigester(content, expectedDigests)
+		var fsPath string
+		fsPath, err = writeUploadFile(target.fsPath, mode, digester)
+		if err == nil && fsPath != target.fsPath {
+			target.name = filepath.Base(fsPath)
+			target.rawReqPath = path.Join(target.parentRawReqPath, target.name)
+			target.fsPath = fsPath
+		}
+	}
+	if h.logError(err) {
+		if uploadErr := getUploadError([]error{err}); uploadErr != nil {
+			http.Error(w, uploadErr.message, uploadErr.status)
+		} else {
+			w.WriteHeader(getStatusByErr(err))
+		}
+		return
+	}
+
+	if info, err := os.Stat(target.fsPath); err == nil {
+		w.Header().Set("ETag", getETag(info))
+	}
+	w.Header().Set("Repr-Digest", digester.reprDigest())
+	if exists {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		if target.rawReqPath != data.rawReqPath && !isDropbox {
+			w.Header().Set("Location", getDavHref(getUrlPrefix(data.prefixReqPath, data.rawReqPath)+target.rawReqPath, false))
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
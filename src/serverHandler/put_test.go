@@ -0,0 +1,143 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/param"
+	"mjpclab.dev/ghfs/src/serverLog"
+	"mjpclab.dev/ghfs/src/tpl/defaultTheme"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsETagMatch(t *testing.T) {
+	if !isETagMatch(`*`, `"a-1"`) {
+		t.Error()
+	}
+	if !isETagMatch(`"b-2", W/"a-1"`, `"a-1"`) {
+		t.Error()
+	}
+	if isETagMatch(`"b-2"`, `"a-1"`) {
+		t.Error()
+	}
+}
+
+func TestPut(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"up", "nodel", "box", "plain"} {
+		os.Mkdir(filepath.Join(root, dir), 0755)
+	}
+	os.WriteFile(filepath.Join(root, "nodel", "exist.txt"), []byte("nodel"), 0644)
+	os.WriteFile(filepath.Join(root, "box", "exist.txt"), []byte("box"), 0644)
+
+	cmdResults, _, _, errs := param.ArgsToCmdResults(param.NewCliCmd(), []string{"ghfs",
+		"-r", root,
+		"--upload", "/up", "--mkdir", "/up", "--delete", "/up",
+		"--upload", "/nodel",
+		"--upload", "/box", "--dropbox", "/box",
+	})
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	params, errs := param.CmdResultsToParams(cmdResults)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	logger, _ := serverLog.NewFileMan().NewLogger("", "")
+	handler, errs := NewVhostHandler(params[0], logger, defaultTheme.DefaultTheme)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	put := func(target, content string, headers ...string) *http.Response {
+		r := httptest.NewRequest(http.MethodPut, target, strings.NewReader(content))
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+	readFile := func(name string) string {
+		content, _ := os.ReadFile(filepath.Join(root, name))
+		return string(content)
+	}
+
+	// create, then overwrite
+	res := put("http://localhost/up/a.txt", "a1")
+	if res.StatusCode != http.StatusCreated || readFile("up/a.txt") != "a1" {
+		t.Fatal(res.StatusCode)
+	}
+	etag := res.Header.Get("ETag")
+	if len(etag) == 0 {
+		t.Error("missing ETag")
+	}
+	res = put("http://localhost/up/a.txt", "a2")
+	if res.StatusCode != http.StatusNoContent || readFile("up/a.txt") != "a2" {
+		t.Error(res.StatusCode)
+	}
+	etag = res.Header.Get("ETag")
+
+	// preconditions
+	if res = put("http://localhost/up/a.txt", "a3", "If-None-Match", "*"); res.StatusCode != http.StatusPreconditionFailed {
+		t.Error(res.StatusCode)
+	}
+	if res = put("http://localhost/up/a.txt", "a3", "If-Match", `"0-0"`); res.StatusCode != http.StatusPreconditionFailed {
+		t.Error(res.StatusCode)
+	}
+	if res = put("http://localhost/up/missing.txt", "a3", "If-Match", "*"); res.StatusCode != http.StatusPreconditionFailed {
+		t.Error(res.StatusCode)
+	}
+	if readFile("up/a.txt") != "a2" {
+		t.Error("file should not be changed if precondition failed")
+	}
+	if res = put("http://localhost/up/a.txt", "a3", "If-Match", etag); res.StatusCode != http.StatusNoContent || readFile("up/a.txt") != "a3" {
+		t.Error(res.StatusCode)
+	}
+	if res = put("http://localhost/up/b.txt", "b", "If-None-Match", "*"); res.StatusCode != http.StatusCreated || readFile("up/b.txt") != "b" {
+		t.Error(res.StatusCode)
+	}
+
+	// permission of nearest existing ancestor
+	if res = put("http://localhost/up/sub/deep/c.txt", "c"); res.StatusCode != http.StatusCreated || readFile("up/sub/deep/c.txt") != "c" {
+		t.Error(res.StatusCode)
+	}
+	if res = put("http://localhost/nodel/sub/c.txt", "c"); res.StatusCode != http.StatusForbidden {
+		t.Error("mkdir should not be allowed by /nodel", res.StatusCode)
+	}
+	if res = put("http://localhost/plain/c.txt", "c"); res.StatusCode != http.StatusForbidden {
+		t.Error("upload should not be allowed by /plain", res.StatusCode)
+	}
+	if res = put("http://localhost/missing/c.txt", "c"); res.StatusCode != http.StatusForbidden {
+		t.Error("upload should not be allowed by root", res.StatusCode)
+	}
+
+	// overwrite needs delete permission, otherwise renamed
+	if res = put("http://localhost/nodel/exist.txt", "c", "If-Match", "*"); res.StatusCode != http.StatusForbidden {
+		t.Error(res.StatusCode)
+	}
+	res = put("http://localhost/nodel/exist.txt", "c")
+	if res.StatusCode != http.StatusCreated || res.Header.Get("Location") != "/nodel/exist-1.txt" {
+		t.Error(res.StatusCode, res.Header.Get("Location"))
+	}
+	if readFile("nodel/exist.txt") != "nodel" || readFile("nodel/exist-1.txt") != "c" {
+		t.Error("existing file should not be overwritten")
+	}
+
+	// drop box always saves as unique name, without revealing it
+	for i := 0; i < 2; i++ {
+		res = put("http://localhost/box/exist.txt", "d", "If-None-Match", "*")
+		if res.StatusCode != http.StatusCreated || len(res.Header.Get("Location")) > 0 {
+			t.Error(res.StatusCode, res.Header.Get("Location"))
+		}
+	}
+	if readFile("box/exist.txt") != "box" {
+		t.Error("existing file in drop box should not be overwritten")
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "box"))
+	if len(entries) != 3 {
+		t.Error(entries)
+	}
+}
@@ -1,14 +1,17 @@
 package serverHandler
 
 import (
+	"errors"
 	"html/template"
 	"mjpclab.dev/ghfs/src/i18n"
+	"mjpclab.dev/ghfs/src/user"
 	"mjpclab.dev/ghfs/src/util"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type pathEntry struct {
@@ -35,6 +38,9 @@ type responseData struct {
 	forceAuth    bool
 	AuthUserName string
 	AuthSuccess  bool
+	authToken    *user.Token
+	// end time of lockout caused by too many auth failures
+	authLockedUntil time.Time
 
 	RestrictAccess bool
 	AllowAccess    bool
@@ -46,7 +52,17 @@ type responseData struct {
 	IsUpload       bool
 	IsMkdir        bool
 	IsDelete       bool
+	IsMove         bool
+	IsCopy         bool
 	IsMutate       bool
+	IsWebdav       bool
+	IsTus          bool
+	IsPut          bool
+	IsLogin        bool
+	IsLogout       bool
+	IsOidcCallback bool
+	IsShare        bool
+	IsExplain      bool
 
 	CanUpload    bool
 	CanMkdir     bool
@@ -55,6 +71,9 @@ type responseData struct {
 	CanArchive   bool
 	CanCors      bool
 	LoginAvail   bool
+	FormLogin    bool
+	OidcLogin    bool
+	CsrfToken    string
 
 	errors []error
 	Status int
@@ -310,26 +329,36 @@ func (h *aliasHandler) getResponseData(r *http.Request) (data *responseData, fsP
 
 	status := http.StatusOK
 
-	needAuth, forceAuth := h.needAuth(rawQuery, rawReqPath, reqFsPath)
-	authUserName, authSuccess, _authErr := h.verifyAuth(r, needAuth)
-	if needAuth {
-		if _authErr != nil {
-			errs = append(errs, _authErr)
-		}
-		if !authSuccess {
-			status = http.StatusUnauthorized
-		}
-	}
-
-	headers := h.getHeaders(rawReqPath, reqFsPath, authSuccess)
-
 	isDownload := false
 	isDownloadFile := false
 	isUpload := false
 	isMkdir := false
 	isDelete := false
+	isMove := false
+	isCopy := false
 	isMutate := false
+	isLogin := false
+	isLogout := false
+	isOidcCallback := false
+	isShare := false
+	isExplain := false
+	isTus := strings.HasPrefix(rawQuery, "tus")
+	isPut := !isTus && r.Method == http.MethodPut
+	isWebdav := !isTus && h.webdav && isWebdavMethod(r.Method)
+	byMethod := isTus || isPut || isWebdav
 	switch {
+	case byMethod:
+		// dispatched by request method
+	case h.oidc != nil && strings.HasPrefix(rawQuery, oidcCallbackQueryParam):
+		isOidcCallback = true
+	case (h.formLogin || h.oidc != nil) && strings.HasPrefix(rawQuery, loginQueryParam):
+		isLogin = true
+	case (h.formLogin || h.oidc != nil) && strings.HasPrefix(rawQuery, logoutQueryParam):
+		isLogout = true
+	case h.share && strings.HasPrefix(rawQuery, shareQueryParam) && r.Method == http.MethodPost:
+		isShare = true
+	case h.ruleExplain && strings.HasPrefix(rawQuery, explainQueryParam):
+		isExplain = true
 	case strings.HasPrefix(rawQuery, "downloadfile"):
 		isDownload = true
 		isDownloadFile = true
@@ -344,9 +373,61 @@ func (h *aliasHandler) getResponseData(r *http.Request) (data *responseData, fsP
 	case strings.HasPrefix(r.URL.RawQuery, "delete"):
 		isDelete = true
 		isMutate = true
+	case strings.HasPrefix(rawQuery, "move"):
+		isMove = true
+		isMutate = true
+	case strings.HasPrefix(rawQuery, "copy"):
+		isCopy = true
+		isMutate = true
 	}
 	wantJson := strings.HasPrefix(rawQuery, "json") || strings.Contains(rawQuery, "&json")
 
+	isWrite := isMutate || isTus || isPut || (isWebdav && r.Method != methodPropfind)
+	isDropbox := h.isDropbox(rawReqPath, reqFsPath)
+
+	// rules that do not depend on user are checked before auth
+	canMutate, blockErr := h.checkPolicyBeforeAuth(r, rawReqPath, reqFsPath, isWrite)
+	if blockErr != nil {
+		errs = append(errs, blockErr)
+	}
+
+	needAuth, forceAuth := h.needAuth(r, rawQuery, rawReqPath, reqFsPath)
+	var authUserName string
+	var authToken *user.Token
+	var authSuccess bool
+	var _authErr error
+	if blockErr == nil {
+		authUserName, authToken, authSuccess, _authErr = h.verifyAuth(r, needAuth, rawReqPath, reqFsPath)
+	}
+	_authLockedErr, authLocked := _authErr.(*authLockedError)
+	if needAuth || authToken != nil || authLocked || _authErr != nil {
+		if _authErr != nil {
+			errs = append(errs, _authErr)
+		}
+		if !authSuccess {
+			switch {
+			case authLocked:
+				status = http.StatusTooManyRequests
+			case authToken != nil:
+				status = http.StatusForbidden
+			default:
+				status = http.StatusUnauthorized
+			}
+		}
+	}
+	var authLockedUntil time.Time
+	if authLocked {
+		authLockedUntil = _authLockedErr.until
+	}
+
+	if authSuccess && !isWrite && !tokenAllows(authToken, user.TokenPermRead) {
+		errs = append(errs, errors.New(r.RemoteAddr+" token "+authToken.Name+" not allowed to read"))
+		authSuccess = false
+		status = http.StatusForbidden
+	}
+
+	headers := h.getHeaders(rawReqPath, reqFsPath, authSuccess)
+
 	isRoot := rawReqPath == "/"
 
 	currDirRelPath := getCurrDirRelPath(rawReqPath, prefixReqPath)
@@ -355,13 +436,16 @@ func (h *aliasHandler) getResponseData(r *http.Request) (data *responseData, fsP
 
 	file, item, _statErr := stat(reqFsPath, authSuccess && !h.emptyRoot)
 	if _statErr != nil {
-		errs = append(errs, _statErr)
+		// methods like PUT and MKCOL operate on non-exist items
+		if !byMethod || !os.IsNotExist(_statErr) {
+			errs = append(errs, _statErr)
+		}
 		status = getStatusByErr(_statErr)
 	}
 
-	needDirSlashRedirect := h.forceDirSlash > 0 && prefixReqPath[len(prefixReqPath)-1] != '/' && item != nil && item.IsDir()
+	needDirSlashRedirect := h.forceDirSlash > 0 && !byMethod && prefixReqPath[len(prefixReqPath)-1] != '/' && item != nil && item.IsDir()
 
-	indexFile, indexItem, _statIdxErr := h.statIndexFile(rawReqPath, reqFsPath, item, authSuccess && !needDirSlashRedirect)
+	indexFile, indexItem, _statIdxErr := h.statIndexFile(rawReqPath, reqFsPath, item, authSuccess && !needDirSlashRedirect && !byMethod && !isDropbox)
 	if _statIdxErr != nil {
 		errs = append(errs, _statIdxErr)
 		status = getStatusByErr(_statIdxErr)
@@ -375,14 +459,19 @@ func (h *aliasHandler) getResponseData(r *http.Request) (data *responseData, fsP
 		}
 	}
 
-	allowAccess := h.isAllowAccess(r, rawReqPath, reqFsPath, file, item)
+	allowAccess := blockErr == nil && h.isAllowAccess(r, rawReqPath, reqFsPath, file, item)
+	if allowAccess && isDropbox && !isWrite && item != nil && !item.IsDir() {
+		errs = append(errs, errors.New(r.RemoteAddr+" item in drop box not readable: "+rawReqPath))
+		allowAccess = false
+	}
 	if !allowAccess {
 		status = http.StatusForbidden
 	}
 
 	itemName := getItemName(item, r)
 
-	subItems, _readdirErr := readdir(file, item, authSuccess && !isMutate && !needDirSlashRedirect && allowAccess && NeedResponseBody(r.Method))
+	needReaddir := !isMutate && !isLogin && !isLogout && !isShare && !isDropbox && (!byMethod || r.Method == methodPropfind)
+	subItems, _readdirErr := readdir(file, item, authSuccess && needReaddir && !needDirSlashRedirect && allowAccess && NeedResponseBody(r.Method))
 	if _readdirErr != nil {
 		errs = append(errs, _readdirErr)
 		status = http.StatusInternalServerError
@@ -400,11 +489,16 @@ func (h *aliasHandler) getResponseData(r *http.Request) (data *responseData, fsP
 	}
 
 	// update `needDirSlashRedirect` for dangling intermediate alias directory
-	if !needDirSlashRedirect && h.forceDirSlash > 0 && len(subItems) > 0 && prefixReqPath[len(prefixReqPath)-1] != '/' {
+	if !needDirSlashRedirect && h.forceDirSlash > 0 && !byMethod && len(subItems) > 0 && prefixReqPath[len(prefixReqPath)-1] != '/' {
 		needDirSlashRedirect = true
 	}
 
+	if isDropbox {
+		// aliases are still kept in aliasSubItems to prevent uploading to shadowed names
+		subItems = subItems[:0]
+	}
 	subItems = h.FilterItems(subItems)
+	subItems = h.filterAllowedItems(r, rawReqPath, reqFsPath, subItems, authUserName)
 	rawSortBy, sortState := sortInfos(subItems, rawQuery, h.defaultSort)
 
 	if h.emptyRoot && status == http.StatusOK && len(rawReqPath) > 1 {
@@ -413,13 +507,13 @@ func (h *aliasHandler) getResponseData(r *http.Request) (data *responseData, fsP
 
 	subItemPrefix := getSubItemPrefix(currDirRelPath, rawReqPath, tailSlash)
 
-	canUpload := authSuccess && h.getCanUpload(item, rawReqPath, reqFsPath)
-	canMkdir := authSuccess && h.getCanMkdir(item, rawReqPath, reqFsPath)
-	canDelete := authSuccess && h.getCanDelete(item, rawReqPath, reqFsPath)
+	canUpload := authSuccess && canMutate && tokenAllows(authToken, user.TokenPermUpload) && h.getCanUpload(r, item, rawReqPath, reqFsPath, authUserName)
+	canMkdir := authSuccess && canMutate && tokenAllows(authToken, user.TokenPermUpload) && h.getCanMkdir(r, item, rawReqPath, reqFsPath, authUserName)
+	canDelete := authSuccess && canMutate && tokenAllows(authToken, user.TokenPermDelete) && h.getCanDelete(r, item, rawReqPath, reqFsPath, authUserName)
 	hasDeletable := canDelete && len(subItems) > len(aliasSubItems)
-	canArchive := authSuccess && h.getCanArchive(subItems, rawReqPath, reqFsPath)
-	canCors := authSuccess && h.getCanCors(rawReqPath, reqFsPath)
-	loginAvail := len(authUserName) == 0 && h.users.Len() > 0
+	canArchive := authSuccess && tokenAllows(authToken, user.TokenPermRead) && h.getCanArchive(r, subItems, rawReqPath, reqFsPath, authUserName)
+	canCors := authSuccess && h.getCanCors(r, rawReqPath, reqFsPath, authUserName)
+	loginAvail := len(authUserName) == 0 && (h.users.Len() > 0 || h.oidc != nil)
 
 	context := pathContext{
 		download:     isDownload,
@@ -434,10 +528,12 @@ func (h *aliasHandler) getResponseData(r *http.Request) (data *responseData, fsP
 		handlerReqPath: reqPath,
 		wantJson:       wantJson,
 
-		NeedAuth:     needAuth,
-		forceAuth:    forceAuth,
-		AuthUserName: authUserName,
-		AuthSuccess:  authSuccess,
+		NeedAuth:        needAuth,
+		forceAuth:       forceAuth,
+		AuthUserName:    authUserName,
+		AuthSuccess:     authSuccess,
+		authToken:       authToken,
+		authLockedUntil: authLockedUntil,
 
 		RestrictAccess: h.restrictAccess,
 		AllowAccess:    allowAccess,
@@ -449,7 +545,17 @@ func (h *aliasHandler) getResponseData(r *http.Request) (data *responseData, fsP
 		IsUpload:       isUpload,
 		IsMkdir:        isMkdir,
 		IsDelete:       isDelete,
+		IsMove:         isMove,
+		IsCopy:         isCopy,
 		IsMutate:       isMutate,
+		IsWebdav:       isWebdav,
+		IsTus:          isTus,
+		IsPut:          isPut,
+		IsLogin:        isLogin,
+		IsLogout:       isLogout,
+		IsOidcCallback: isOidcCallback,
+		IsShare:        isShare,
+		IsExplain:      isExplain,
 
 		CanUpload:    canUpload,
 		CanMkdir:     canMkdir,
@@ -458,6 +564,8 @@ func (h *aliasHandler) getResponseData(r *http.Request) (data *responseData, fsP
 		CanArchive:   canArchive,
 		CanCors:      canCors,
 		LoginAvail:   loginAvail,
+		FormLogin:    h.formLogin,
+		OidcLogin:    h.oidc != nil,
 
 		errors: errs,
 		Status: status,
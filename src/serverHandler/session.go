@@ -0,0 +1,185 @@
+package serverHandler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sessionCookieName = "ghfs_session"
+const loginQueryParam = "login"
+const logoutQueryParam = "logout"
+
+// newSessionKey gets key for signing session cookie from secret,
+// or generates a random one, which invalidates sessions after restart.
+func newSessionKey(secret string) ([]byte, error) {
+	if len(secret) > 0 {
+		return []byte(secret), nil
+	}
+
+	key := make([]byte, sha256.Size)
+	_, err := rand.Read(key)
+	return key, err
+}
+
+func signSession(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// newSessionValue makes session cookie value, "<base64-username>.<expires>.<base64-signature>".
+func newSessionValue(key []byte, username string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(username)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signSession(key, payload))
+}
+
+// parseSessionValue verifies signature and expiry of session cookie value.
+func parseSessionValue(key []byte, value string, now time.Time) (username string, ok bool) {
+	sigIndex := strings.LastIndexByte(value, '.')
+	if sigIndex <= 0 {
+		return
+	}
+	payload := value[:sigIndex]
+	signature, err := base64.RawURLEncoding.DecodeString(value[sigIndex+1:])
+	if err != nil || !hmac.Equal(signature, signSession(key, payload)) {
+		return
+	}
+
+	dotIndex := strings.IndexByte(payload, '.')
+	if dotIndex < 0 {
+		return
+	}
+	expires, err := strconv.ParseInt(payload[dotIndex+1:], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return
+	}
+	name, err := base64.RawURLEncoding.DecodeString(payload[:dotIndex])
+	if err != nil {
+		return
+	}
+
+	return string(name), true
+}
+
+type sessionGroupsKey struct{}
+
+// withSessionGroups makes request able to carry groups of user from its verified OIDC session.
+func withSessionGroups(r *http.Request) *http.Request {
+	var groups []string
+	return r.WithContext(context.WithValue(r.Context(), sessionGroupsKey{}, &groups))
+}
+
+func setSessionGroups(r *http.Request, groups []string) {
+	if p, ok := r.Context().Value(sessionGroupsKey{}).(*[]string); ok {
+		*p = groups
+	}
+}
+
+// getSessionGroups gets groups of user authenticated by OIDC session of current request.
+func getSessionGroups(r *http.Request) []string {
+	if p, ok := r.Context().Value(sessionGroupsKey{}).(*[]string); ok {
+		return *p
+	}
+	return nil
+}
+
+// getSessionUser gets user from login session cookie, if the user still exists.
+// User logged in by OIDC is not required to exist, and its groups are carried by request.
+func (h *aliasHandler) getSessionUser(r *http.Request) (username string, ok bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return
+	}
+	if h.oidc != nil && strings.HasPrefix(cookie.Value, oidcSessionPrefix) {
+		var groups []string
+		username, groups, ok = parseOidcSessionValue(h.sessionKey, cookie.Value, time.Now())
+		if ok {
+			setSessionGroups(r, groups)
+		}
+		return
+	}
+	username, ok = parseSessionValue(h.sessionKey, cookie.Value, time.Now())
+	if ok && !h.users.Exists(username) {
+		return "", false
+	}
+	return
+}
+
+func (h *aliasHandler) setSessionCookie(w http.ResponseWriter, r *http.Request, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   isHttps(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// wantLoginPage checks if unauthorized request is from browser for a page,
+// which should be responded with login page instead of Basic Auth challenge.
+func wantLoginPage(r *http.Request, data *responseData) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if data.wantJson || data.IsTus || data.IsPut || data.IsWebdav {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func (h *aliasHandler) loginPage(w http.ResponseWriter, r *http.Request, data *responseData, status int) {
+	data.IsLogin = true
+	data.Status = status
+	data.SubItems = nil
+	data.CanUpload = false
+	data.CanMkdir = false
+	data.CanDelete = false
+	data.HasDeletable = false
+	data.CanArchive = false
+	w.Header().Set("Cache-Control", "no-store")
+	h.page(w, r, data)
+}
+
+func (h *aliasHandler) login(w http.ResponseWriter, r *http.Request, data *responseData) {
+	if h.oidc != nil && (r.Method != http.MethodPost || !h.formLogin) {
+		h.oidcLogin(w, r, data)
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.loginPage(w, r, data, http.StatusOK)
+		return
+	}
+
+	username := r.PostFormValue("username")
+	if err := h.checkAuthLockout(r, username); err != nil {
+		h.logError(err)
+		setRetryAfter(w, err.(*authLockedError).until)
+		h.loginPage(w, r, data, http.StatusTooManyRequests)
+		return
+	}
+	if !h.users.Auth(username, r.PostFormValue("password")) {
+		h.logger.LogErrorString(r.RemoteAddr + " login failed for user " + username)
+		h.addAuthFailure(r, username)
+		h.loginPage(w, r, data, http.StatusUnauthorized)
+		return
+	}
+	h.resetAuthFailure(username)
+
+	expires := time.Now().Add(h.sessionMaxAge)
+	h.setSessionCookie(w, r, newSessionValue(h.sessionKey, username, expires), int(h.sessionMaxAge/time.Second))
+	http.Redirect(w, r, data.prefixReqPath+data.Context.QueryString(), http.StatusSeeOther)
+}
+
+func (h *aliasHandler) logout(w http.ResponseWriter, r *http.Request, data *responseData) {
+	h.setSessionCookie(w, r, "", -1)
+	http.Redirect(w, r, data.prefixReqPath+data.Context.QueryString(), http.StatusSeeOther)
+}
@@ -0,0 +1,36 @@
+package serverHandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionValue(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1000000000, 0)
+
+	value := newSessionValue(key, "alice.bob", now.Add(time.Hour))
+	if username, ok := parseSessionValue(key, value, now); !ok || username != "alice.bob" {
+		t.Error(username, ok)
+	}
+
+	if _, ok := parseSessionValue(key, value, now.Add(time.Hour)); ok {
+		t.Error("expired session should be invalid")
+	}
+
+	if _, ok := parseSessionValue([]byte("other"), value, now); ok {
+		t.Error("session signed by other key should be invalid")
+	}
+
+	forged := newSessionValue(key, "carol", now.Add(time.Hour))
+	forged = value[:len(value)-10] + forged[len(forged)-10:]
+	if _, ok := parseSessionValue(key, forged, now); ok {
+		t.Error("forged session should be invalid")
+	}
+
+	for _, invalid := range []string{"", ".", "a.b", "a.b.c", "YWxpY2U.x.y"} {
+		if _, ok := parseSessionValue(key, invalid, now); ok {
+			t.Error(invalid)
+		}
+	}
+}
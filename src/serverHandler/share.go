@@ -0,0 +1,205 @@
+package serverHandler
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"mjpclab.dev/ghfs/src/user"
+	"mjpclab.dev/ghfs/src/util"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const shareQueryParam = "share"
+const shareKeyQueryParam = "key"
+const shareCookieName = "ghfs_share"
+
+const sharePermRead = "r"
+const sharePermReadUpload = "ru"
+
+type shareResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Url     string `json:"url,omitempty"`
+	Expires string `json:"expires,omitempty"`
+}
+
+// newShareValue makes share key,
+// "<base64-url-path>.<base64-username>.<perm>.<expires>.<base64-signature>".
+func newShareValue(key []byte, urlPath, username string, upload bool, expires time.Time) string {
+	perm := sharePermRead
+	if upload {
+		perm = sharePermReadUpload
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(urlPath)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(username)) + "." +
+		perm + "." +
+		strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signSession(key, payload))
+}
+
+// parseShareValue verifies signature and expiry of share key,
+// and represents it as a token that only allows shared path.
+func parseShareValue(key []byte, value string, now time.Time) (token *user.Token, ok bool) {
+	sigIndex := strings.LastIndexByte(value, '.')
+	if sigIndex <= 0 {
+		return
+	}
+	payload := value[:sigIndex]
+	signature, err := base64.RawURLEncoding.DecodeString(value[sigIndex+1:])
+	if err != nil || !hmac.Equal(signature, signSession(key, payload)) {
+		return
+	}
+
+	fields := strings.Split(payload, ".")
+	if len(fields) != 4 {
+		return
+	}
+	urlPath, err := base64.RawURLEncoding.DecodeString(fields[0])
+	if err != nil {
+		return
+	}
+	username, err := base64.RawURLEncoding.DecodeString(fields[1])
+	if err != nil {
+		return
+	}
+	var perms user.TokenPerm
+	switch fields[2] {
+	case sharePermRead:
+		perms = user.TokenPermRead
+	case sharePermReadUpload:
+		perms = user.TokenPermRead | user.TokenPermUpload
+	default:
+		return
+	}
+	expires, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return
+	}
+
+	return &user.Token{
+		Name:    string(username),
+		Paths:   []string{string(urlPath)},
+		Perms:   perms,
+		Expires: time.Unix(expires, 0),
+	}, true
+}
+
+// getShareToken gets share from query string, or from cookies that cover url path.
+// Share key in query string counts as auth request even if it is invalid.
+func (h *aliasHandler) getShareToken(r *http.Request, rawReqPath string) (token *user.Token, hasAuthReq bool) {
+	now := time.Now()
+	if value := r.URL.Query().Get(shareKeyQueryParam); len(value) > 0 {
+		token, _ = parseShareValue(h.shareKey, value, now)
+		hasAuthReq = true
+	} else {
+		for _, cookie := range r.Cookies() {
+			if cookie.Name != shareCookieName {
+				continue
+			}
+			if t, ok := parseShareValue(h.shareKey, cookie.Value, now); ok && t.AllowsPath(rawReqPath) {
+				token = t
+				hasAuthReq = true
+				break
+			}
+		}
+	}
+
+	// user who created the share may be removed
+	if token != nil && !h.users.Exists(token.Name) {
+		token = nil
+	}
+	return
+}
+
+// keepShare sets cookie for shared directory accessed by share key,
+// so that its sub items can be visited without the key.
+func (h *aliasHandler) keepShare(w http.ResponseWriter, r *http.Request, data *responseData) {
+	if data.authToken == nil || data.Item == nil || !data.Item.IsDir() {
+		return
+	}
+	value := r.URL.Query().Get(shareKeyQueryParam)
+	if len(value) == 0 {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     shareCookieName,
+		Value:    value,
+		Path:     (&url.URL{Path: util.CleanUrlPath(data.prefixReqPath)}).EscapedPath(),
+		Expires:  data.authToken.Expires,
+		Secure:   isHttps(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// createShare creates share link of current path for authenticated user,
+// with optional form fields "expires" in seconds and "upload".
+func (h *aliasHandler) createShare(w http.ResponseWriter, r *http.Request, data *responseData) {
+	header := w.Header()
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+
+	status := http.StatusOK
+	result := &shareResult{}
+	defer func() {
+		body, _ := json.Marshal(result)
+		w.WriteHeader(status)
+		w.Write(body)
+	}()
+
+	// only users can create share, but not API tokens or shares
+	if r.Method != http.MethodPost || len(data.AuthUserName) == 0 || data.authToken != nil {
+		status = http.StatusForbidden
+		result.Error = "share not allowed"
+		return
+	}
+	if h.logError(h.checkCsrf(r, data)) {
+		status = http.StatusForbidden
+		result.Error = "csrf check failed"
+		return
+	}
+	if data.Item == nil {
+		status = http.StatusNotFound
+		result.Error = "not found"
+		return
+	}
+
+	maxAge := h.shareMaxAge
+	if input := r.PostFormValue("expires"); len(input) > 0 {
+		seconds, err := strconv.Atoi(input)
+		if err != nil || seconds <= 0 {
+			status = http.StatusBadRequest
+			result.Error = "invalid expires"
+			return
+		}
+		if age := time.Duration(seconds) * time.Second; age < maxAge {
+			maxAge = age
+		}
+	}
+
+	upload, _ := strconv.ParseBool(r.PostFormValue("upload"))
+	if upload && !data.CanUpload {
+		status = http.StatusForbidden
+		result.Error = "upload not allowed"
+		return
+	}
+
+	expires := time.Now().Add(maxAge)
+	sharePath := util.CleanUrlPath(data.rawReqPath)
+	value := newShareValue(h.shareKey, sharePath, data.AuthUserName, upload, expires)
+
+	urlPath := util.CleanUrlPath(data.prefixReqPath)
+	if data.Item.IsDir() && urlPath != "/" {
+		urlPath += "/"
+	}
+
+	result.Success = true
+	result.Url = (&url.URL{Path: urlPath, RawQuery: shareKeyQueryParam + "=" + value}).String()
+	result.Expires = expires.UTC().Format(time.RFC3339)
+	h.logMutate(data.AuthUserName, "share", sharePath+" until "+result.Expires, r)
+}
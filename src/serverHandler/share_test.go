@@ -0,0 +1,45 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/user"
+	"testing"
+	"time"
+)
+
+func TestShareValue(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1000000000, 0)
+
+	value := newShareValue(key, "/a/b.c", "alice", false, now.Add(time.Hour))
+	token, ok := parseShareValue(key, value, now)
+	if !ok || token.Name != "alice" || token.Perms != user.TokenPermRead || !token.Expires.Equal(now.Add(time.Hour)) {
+		t.Error(token, ok)
+	}
+	if !token.AllowsPath("/a/b.c") || !token.AllowsPath("/a/b.c/d") || token.AllowsPath("/a") || token.AllowsPath("/a/b") {
+		t.Error(token.Paths)
+	}
+
+	value = newShareValue(key, "/", "alice", true, now.Add(time.Hour))
+	if token, ok := parseShareValue(key, value, now); !ok || !token.Allows(user.TokenPermUpload) || token.Allows(user.TokenPermDelete) {
+		t.Error(token, ok)
+	}
+
+	if _, ok := parseShareValue(key, value, now.Add(time.Hour)); ok {
+		t.Error("expired share should be invalid")
+	}
+
+	if _, ok := parseShareValue([]byte("other"), value, now); ok {
+		t.Error("share signed by other key should be invalid")
+	}
+
+	sessionValue := newSessionValue(key, "alice", now.Add(time.Hour))
+	if _, ok := parseShareValue(key, sessionValue, now); ok {
+		t.Error("session value should not be a share")
+	}
+
+	for _, invalid := range []string{"", ".", "a.b", "a.b.c.d", "Lw.YWxpY2U.r.x.y"} {
+		if _, ok := parseShareValue(key, invalid, now); ok {
+			t.Error(invalid)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/user"
+	"net/http"
+	"strings"
+)
+
+const bearerAuthPrefix = "Bearer "
+
+func getBearerToken(r *http.Request) (token string, ok bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len(bearerAuthPrefix) || !strings.EqualFold(auth[:len(bearerAuthPrefix)], bearerAuthPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(bearerAuthPrefix):]), true
+}
+
+// tokenAllows checks permission scope of token, request not authenticated by token is always allowed.
+func tokenAllows(token *user.Token, perm user.TokenPerm) bool {
+	return token == nil || token.Allows(perm)
+}
@@ -0,0 +1,468 @@
+package serverHandler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mjpclab.dev/ghfs/src/serverError"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const tusVersion = "1.0.0"
+const tusContentType = "application/offset+octet-stream"
+const tusStagingDir = ".ghfs-tus"
+const tusInfoSuffix = ".info"
+const tusIdLen = 32
+const tusExpires = 24 * time.Hour
+
+type tusInfo struct {
+	Path     string `json:"path"`
+	Length   int64  `json:"length"`
+	Metadata string `json:"metadata"`
+	// creator of the upload, only the same one can continue or terminate it
+	User  string `json:"user"`
+	Token string `json:"token,omitempty"`
+}
+
+// getTusOwner identifies creator of upload by user name,
+// plus name and paths of API token or share if used.
+func getTusOwner(data *responseData) (username, token string) {
+	if data.authToken != nil {
+		token = data.authToken.Name + ":" + strings.Join(data.authToken.Paths, ",")
+	}
+	return data.AuthUserName, token
+}
+
+func (h *aliasHandler) isTusOwner(info *tusInfo, data *responseData) bool {
+	username, token := getTusOwner(data)
+	return h.users.IsNameEqual(info.User, username) && info.Token == token
+}
+
+// tusUploads tracks uploads in progress, to prevent concurrent PATCH on same upload.
+type tusUploads struct {
+	mu     sync.Mutex
+	active map[string]struct{}
+}
+
+func newTusUploads() *tusUploads {
+	return &tusUploads{active: map[string]struct{}{}}
+}
+
+func (uploads *tusUploads) acquire(id string) bool {
+	uploads.mu.Lock()
+	defer uploads.mu.Unlock()
+
+	if _, ok := uploads.active[id]; ok {
+		return false
+	}
+	uploads.active[id] = struct{}{}
+	return true
+}
+
+func (uploads *tusUploads) release(id string) {
+	uploads.mu.Lock()
+	delete(uploads.active, id)
+	uploads.mu.Unlock()
+}
+
+func newTusId() string {
+	buf := make([]byte, tusIdLen/2)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func isTusId(id string) bool {
+	if len(id) != tusIdLen {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// getTusId extracts upload id from query string "tus=<id>".
+func getTusId(rawQuery string) (id string, ok bool) {
+	const prefix = "tus="
+	if !strings.HasPrefix(rawQuery, prefix) {
+		return "", false
+	}
+	id = rawQuery[len(prefix):]
+	if ampIndex := strings.IndexByte(id, '&'); ampIndex >= 0 {
+		id = id[:ampIndex]
+	}
+	return id, true
+}
+
+func parseTusMetadata(header string) map[string]string {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		key := pair
+		value := ""
+		if spaceIndex := strings.IndexByte(pair, ' '); spaceIndex >= 0 {
+			key = pair[:spaceIndex]
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(pair[spaceIndex+1:]))
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+		metadata[key] = value
+	}
+	return metadata
+}
+
+// getTusFilePath gets uploading file path from metadata,
+// which is compatible with common tus clients.
+func getTusFilePath(metadata map[string]string) string {
+	if relativePath := metadata["relativePath"]; len(relativePath) > 0 && relativePath != "null" {
+		return relativePath
+	}
+	if filename := metadata["filename"]; len(filename) > 0 {
+		return filename
+	}
+	return metadata["name"]
+}
+
+func splitTusFilePath(filePath string) (fsInfix, filename string) {
+	filenameIndex := strings.LastIndexByte(filePath, '/')
+	if filenameIndex < 0 {
+		return "", filePath
+	}
+	return filePath[:filenameIndex], filePath[filenameIndex+1:]
+}
+
+var errTusNotOwner = errors.New("tus: upload not owned by current user")
+
+// readTusInfo reads info of upload that is owned by current user or token.
+func (h *aliasHandler) readTusInfo(data *responseData, stagingPath, id string) (info *tusInfo, offset int64, err error) {
+	content, err := os.ReadFile(filepath.Join(stagingPath, id+tusInfoSuffix))
+	if err != nil {
+		return
+	}
+	info = &tusInfo{}
+	if err = json.Unmarshal(content, info); err != nil {
+		return
+	}
+	if !h.isTusOwner(info, data) {
+		err = errTusNotOwner
+		h.logError(err)
+		return
+	}
+	dataInfo, err := os.Stat(filepath.Join(stagingPath, id))
+	if err != nil {
+		return
+	}
+	offset = dataInfo.Size()
+	return
+}
+
+func removeTusUpload(stagingPath, id string) {
+	os.Remove(filepath.Join(stagingPath, id))
+	os.Remove(filepath.Join(stagingPath, id+tusInfoSuffix))
+	os.Remove(stagingPath) // only succeed if empty
+}
+
+func (h *aliasHandler) removeExpiredTusUploads(stagingPath string) {
+	dir, err := os.Open(stagingPath)
+	if err != nil {
+		return
+	}
+	infos, _ := dir.Readdir(0)
+	dir.Close()
+
+	expires := time.Now().Add(-tusExpires)
+	for _, info := range infos {
+		id := info.Name()
+		if !isTusId(id) || info.ModTime().After(expires) || !h.tusUploads.acquire(id) {
+			continue
+		}
+		removeTusUpload(stagingPath, id)
+		h.tusUploads.release(id)
+	}
+}
+
+func (h *aliasHandler) tus(w http.ResponseWriter, r *http.Request, data *responseData) {
+	header := w.Header()
+	header.Set("Tus-Resumable", tusVersion)
+	if data.CanCors {
+		header.Set("Access-Control-Expose-Headers", "Location, Upload-Offset, Upload-Length, Upload-Metadata, Tus-Resumable, Tus-Version, Tus-Extension")
+	}
+
+	if h.uploadMaxFileSize > 0 {
+		header.Set("Tus-Max-Size", strconv.FormatInt(h.uploadMaxFileSize, 10))
+	}
+
+	if r.Method == http.MethodOptions {
+		header.Set("Tus-Version", tusVersion)
+		header.Set("Tus-Extension", "creation,termination")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		header.Set("Tus-Version", tusVersion)
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	if r.Method != http.MethodHead && h.logError(h.checkCsrf(r, data)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if !data.CanUpload {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	fsPrefix := h.root + data.handlerReqPath
+	stagingPath := filepath.Join(fsPrefix, tusStagingDir)
+
+	id, hasId := getTusId(r.URL.RawQuery)
+	if !hasId {
+		if r.Method == http.MethodPost {
+			h.tusCreate(w, r, data, fsPrefix, stagingPath)
+		} else {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if !isTusId(id) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		h.tusOffset(w, data, stagingPath, id)
+	case http.MethodPatch:
+		h.tusPatch(w, r, data, fsPrefix, stagingPath, id)
+	case http.MethodDelete:
+		h.tusTerminate(w, data, stagingPath, id)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *aliasHandler) tusCreate(w http.ResponseWriter, r *http.Request, data *responseData, fsPrefix, stagingPath string) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rawMetadata := r.Header.Get("Upload-Metadata")
+	metadata := parseTusMetadata(rawMetadata)
+	filePath, err := h.getUploadFilePath(getTusFilePath(metadata))
+	if err != nil {
+		uploadErr := err.(*uploadError)
+		http.Error(w, uploadErr.message, uploadErr.status)
+		return
+	}
+	if fsInfix, _ := splitTusFilePath(filePath); len(fsInfix) > 0 && !data.CanMkdir {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if err = h.checkUploadSize(fsPrefix, length); err != nil {
+		uploadErr := err.(*uploadError)
+		http.Error(w, uploadErr.message, uploadErr.status)
+		return
+	}
+
+	if h.logError(os.MkdirAll(stagingPath, 0755)) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.removeExpiredTusUploads(stagingPath)
+
+	id := newTusId()
+	username, token := getTusOwner(data)
+	content, _ := json.Marshal(&tusInfo{filePath, length, rawMetadata, username, token})
+	err = os.WriteFile(filepath.Join(stagingPath, id+tusInfoSuffix), content, 0644)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(stagingPath, id), nil, 0644)
+	}
+	if h.logError(err) {
+		removeTusUpload(stagingPath, id)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if length == 0 {
+		if err = h.finishTusUpload(data, fsPrefix, stagingPath, id, filePath, metadata, r); err != nil {
+			h.writeTusFinishError(w, err)
+			return
+		}
+	}
+
+	location := &url.URL{Path: data.prefixReqPath, RawQuery: "tus=" + id}
+	w.Header().Set("Location", location.String())
+	w.Header().Set("Upload-Offset", "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *aliasHandler) tusOffset(w http.ResponseWriter, data *responseData, stagingPath, id string) {
+	info, offset, err := h.readTusInfo(data, stagingPath, id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	header.Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	if len(info.Metadata) > 0 {
+		header.Set("Upload-Metadata", info.Metadata)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *aliasHandler) tusPatch(w http.ResponseWriter, r *http.Request, data *responseData, fsPrefix, stagingPath, id string) {
+	if r.Header.Get("Content-Type") != tusContentType {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if !h.tusUploads.acquire(id) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+	defer h.tusUploads.release(id)
+
+	info, offset, err := h.readTusInfo(data, stagingPath, id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	reqOffset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || reqOffset != offset {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	if !h.limitUploadBody(w, r) {
+		w.WriteHeader(errUploadBodyTooLarge.status)
+		return
+	}
+	content, err := h.newUploadLimitReader(io.LimitReader(r.Body, info.Length-offset), stagingPath, offset)
+	if err != nil {
+		uploadErr := err.(*uploadError)
+		http.Error(w, uploadErr.message, uploadErr.status)
+		return
+	}
+
+	file, err := os.OpenFile(filepath.Join(stagingPath, id), os.O_WRONLY|os.O_APPEND, 0644)
+	if h.logError(err) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	written, err := io.Copy(file, content)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	offset += written
+	if h.logError(err) {
+		if uploadErr := getUploadError([]error{err}); uploadErr != nil {
+			http.Error(w, uploadErr.message, uploadErr.status)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if offset == info.Length {
+		if err = h.finishTusUpload(data, fsPrefix, stagingPath, id, info.Path, parseTusMetadata(info.Metadata), r); err != nil {
+			h.writeTusFinishError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *aliasHandler) tusTerminate(w http.ResponseWriter, data *responseData, stagingPath, id string) {
+	if !h.tusUploads.acquire(id) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+	defer h.tusUploads.release(id)
+
+	if _, _, err := h.readTusInfo(data, stagingPath, id); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	removeTusUpload(stagingPath, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finishTusUpload moves completed file from staging area to its final path,
+// with the same naming, mkdir and conflict rules as multipart uploading.
+func (h *aliasHandler) finishTusUpload(data *responseData, fsPrefix, stagingPath, id, filePath string, metadata map[string]string, r *http.Request) error {
+	defer removeTusUpload(stagingPath, id)
+
+	fsInfix, filename := splitTusFilePath(filePath)
+	stagingFile := filepath.Join(stagingPath, id)
+	if err := h.checkTusContent(filename, stagingFile); h.logError(err) {
+		return err
+	}
+
+	conflict := h.getUploadConflict(data.rawReqPath, fsPrefix, data.CanDelete)
+	mtime := parseUploadMtime(metadata[uploadMtimeField])
+	fsPath, mode, skip, errs := getUploadFsPath(fsPrefix, fsInfix, filename, data.CanMkdir, conflict, mtime, data.AliasSubItems)
+	if len(fsPath) > 0 && !skip {
+		h.logUpload(data.AuthUserName, filename, fsPath, r)
+		var err error
+		if fsPath, err = commitUploadFile(stagingFile, fsPath, mode); err != nil {
+			errs = append(errs, err)
+		} else {
+			errs = serverError.AppendError(errs, setUploadMtime(fsPath, mtime))
+		}
+	}
+
+	if h.logErrors(errs) {
+		return errs[0]
+	}
+	return nil
+}
+
+// checkTusContent sniffs head of completed staging file.
+func (h *aliasHandler) checkTusContent(filename, stagingFile string) error {
+	if !h.uploadSniffType {
+		return nil
+	}
+
+	file, err := os.Open(stagingFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = h.checkUploadContent(filename, file)
+	return err
+}
+
+func (h *aliasHandler) writeTusFinishError(w http.ResponseWriter, err error) {
+	if uploadErr := getUploadError([]error{err}); uploadErr != nil {
+		http.Error(w, uploadErr.message, uploadErr.status)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
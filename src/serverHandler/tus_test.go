@@ -0,0 +1,69 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/user"
+	"testing"
+)
+
+func TestGetTusId(t *testing.T) {
+	id, ok := getTusId("tus=0123456789abcdef0123456789abcdef&json")
+	if !ok || id != "0123456789abcdef0123456789abcdef" || !isTusId(id) {
+		t.Error(id)
+	}
+
+	id, ok = getTusId("tus")
+	if ok {
+		t.Error(id)
+	}
+
+	if isTusId("../../../../etc/passwd") {
+		t.Error()
+	}
+}
+
+func TestParseTusMetadata(t *testing.T) {
+	metadata := parseTusMetadata("filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential, relativePath ZGlyL2EudHh0")
+	if metadata["filename"] != "world_domination_plan.pdf" {
+		t.Error(metadata)
+	}
+	if _, ok := metadata["is_confidential"]; !ok {
+		t.Error(metadata)
+	}
+	if getTusFilePath(metadata) != "dir/a.txt" {
+		t.Error(metadata)
+	}
+
+	fsInfix, filename := splitTusFilePath("dir/sub/a.txt")
+	if fsInfix != "dir/sub" || filename != "a.txt" {
+		t.Error(fsInfix, filename)
+	}
+}
+
+func TestIsTusOwner(t *testing.T) {
+	h := &aliasHandler{users: user.NewList(false)}
+	token := &user.Token{Name: "alice", Paths: []string{"/shared"}}
+
+	username, tokenId := getTusOwner(&responseData{AuthUserName: "alice"})
+	info := &tusInfo{User: username, Token: tokenId}
+	if !h.isTusOwner(info, &responseData{AuthUserName: "alice"}) {
+		t.Error("creator should own the upload")
+	}
+	if h.isTusOwner(info, &responseData{AuthUserName: "bob"}) {
+		t.Error("other user should not own the upload")
+	}
+	if h.isTusOwner(info, &responseData{}) {
+		t.Error("anonymous user should not own the upload")
+	}
+	if h.isTusOwner(info, &responseData{AuthUserName: "alice", authToken: token}) {
+		t.Error("share of creator should not own the upload")
+	}
+
+	username, tokenId = getTusOwner(&responseData{AuthUserName: "alice", authToken: token})
+	info = &tusInfo{User: username, Token: tokenId}
+	if !h.isTusOwner(info, &responseData{AuthUserName: "alice", authToken: token}) {
+		t.Error("same share should own the upload")
+	}
+	if h.isTusOwner(info, &responseData{AuthUserName: "alice"}) {
+		t.Error("creator of share should not own the upload by share")
+	}
+}
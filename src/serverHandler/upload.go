@@ -2,20 +2,42 @@ package serverHandler
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
+	"mjpclab.dev/ghfs/src/serverError"
 	"mjpclab.dev/ghfs/src/util"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const file = "file"
 const dirFile = "dirfile"
 const innerDirFile = "innerdirfile"
+const uploadMtimeField = "mtime"
+const maxUploadFieldSize = 1024
+
+type uploadResult struct {
+	Name    string            `json:"name"`
+	To      string            `json:"to,omitempty"`
+	Success bool              `json:"success"`
+	Skipped bool              `json:"skipped,omitempty"`
+	Digest  map[string]string `json:"digest,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// uploadPartFields are companion form fields preceding a file part,
+// which only apply to that file.
+type uploadPartFields struct {
+	mtime  time.Time
+	digest string
+}
 
 func getAvailableFilename(fsPrefix, filename string, mustAppendSuffix bool) string {
 	if len(fsPrefix) == 0 {
@@ -42,6 +64,78 @@ func getAvailableFilename(fsPrefix, filename string, mustAppendSuffix bool) stri
 	return ""
 }
 
+// uploadCommitMode is how uploaded file, which is completely written into temporary file,
+// takes its final name.
+type uploadCommitMode uint8
+
+const (
+	// replace existing file
+	uploadCommitReplace uploadCommitMode = iota
+	// fail if file already exists
+	uploadCommitExclusive
+	// take next available name with suffix if file already exists
+	uploadCommitAvailable
+	// always take name with suffix
+	uploadCommitUnique
+)
+
+// commitUploadFile moves temporary file to fsPath by mode, returns the final path.
+// Names are claimed atomically by hard link, so that concurrent uploading will not get the same name.
+func commitUploadFile(tempPath, fsPath string, mode uploadCommitMode) (string, error) {
+	switch mode {
+	case uploadCommitReplace:
+		return fsPath, os.Rename(tempPath, fsPath)
+	case uploadCommitExclusive:
+		err := linkFsFile(tempPath, fsPath)
+		if os.IsExist(err) {
+			return "", fmt.Errorf("upload: %w %s", errUploadExists, fsPath)
+		}
+		if err != nil {
+			return "", err
+		}
+		os.Remove(tempPath)
+		return fsPath, nil
+	}
+
+	dir, filename := filepath.Split(fsPath)
+	filenamePrefix, filenameSuffix := util.SplitFilename(filename)
+	for i := 0; ; i++ {
+		if i == 0 && mode == uploadCommitUnique {
+			continue
+		}
+		newPath := fsPath
+		if i > 0 {
+			newPath = dir + filenamePrefix + "-" + strconv.Itoa(i) + filenameSuffix
+		}
+		err := linkFsFile(tempPath, newPath)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		os.Remove(tempPath)
+		return newPath, nil
+	}
+}
+
+// writeUploadFile writes content into a hidden temporary file besides fsPath,
+// and commits it by mode only after content is read completely,
+// so that interrupted writing will not leave truncated file,
+// and no partial file is visible under the final name.
+// Returns the final path.
+func writeUploadFile(fsPath string, mode uploadCommitMode, content io.Reader) (string, error) {
+	tempPath, err := writeFsTempFile(filepath.Dir(fsPath), content)
+	if err != nil {
+		return "", err
+	}
+	fsPath, err = commitUploadFile(tempPath, fsPath, mode)
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return fsPath, err
+}
+
 // RFC 7578, Section 4.2 requires that if a filename is provided, the
 // directory path information must not be used.
 // Since Go 1.17, Part.FileName() will strip directory information.
@@ -53,138 +147,231 @@ func getPartFilePath(part *multipart.Part) string {
 	return params["filename"]
 }
 
-func (h *aliasHandler) saveUploadFiles(authUserName, fsPrefix string, createDir, overwriteExists bool, aliasSubItems []os.FileInfo, r *http.Request) bool {
-	var errs []error
-
-	reader, err := r.MultipartReader()
-	if err != nil {
-		errs = append(errs, err)
-		return false
-	}
+// getUploadFsPath resolves file system path for saving uploaded file by conflict policy,
+// and creates intermediate directories.
+// The path is only a candidate, final name is decided by mode when committing the file.
+// Existing file to be overwritten is kept until it is replaced.
+// Returns empty fsPath if file cannot be saved.
+// Returns path of existing file and skip if it is not older than mtime for policy "newer".
+func getUploadFsPath(fsPrefix, fsInfix, filename string, createDir bool, conflict uploadConflict, mtime time.Time, aliasSubItems []os.FileInfo) (fsPath string, mode uploadCommitMode, skip bool, errs []error) {
+	filePrefix := fsPrefix
+	if len(fsInfix) > 0 {
+		if !createDir {
+			errs = append(errs, errors.New("upload: mkdir is not enabled for "+fsPrefix))
+			return
+		}
 
-	for {
-		part, err := reader.NextPart()
-		if err != nil {
-			if err != io.EOF {
-				errs = append(errs, err)
+		if len(aliasSubItems) > 0 {
+			fsInfixPart1 := fsInfix
+			fsInfixSlashIndex := strings.IndexByte(fsInfixPart1, '/')
+			if fsInfixSlashIndex > 0 {
+				fsInfixPart1 = fsInfixPart1[0:fsInfixSlashIndex]
+			}
+			if containsItem(aliasSubItems, fsInfixPart1) {
+				errs = append(errs, errors.New("upload: ignore path shadowed by alias "+fsInfix))
+				return
 			}
-			break
 		}
 
-		inputPartFilePath := getPartFilePath(part)
-		if len(inputPartFilePath) == 0 {
-			continue
-		}
-		partFilePath, ok := getCleanDirFilePath(inputPartFilePath)
-		if !ok {
-			errs = append(errs, errors.New("upload: illegal file path "+inputPartFilePath))
-			continue
+		filePrefix += "/" + fsInfix
+		err := os.MkdirAll(filePrefix, 0755)
+		if err != nil {
+			errs = append(errs, err)
+			return
 		}
+	}
 
-		filenameIndex := strings.LastIndexByte(partFilePath, '/')
+	tryPath := filepath.Join(filePrefix, filename)
+	isFilenameAliased := len(fsInfix) == 0 && containsItem(aliasSubItems, filename)
+	if conflict == uploadConflictUnique || isFilenameAliased {
+		return tryPath, uploadCommitUnique, false, nil
+	}
 
-		fsInfix := ""
-		formname := part.FormName()
-		if formname == dirFile {
-			if filenameIndex > 0 {
-				fsInfix = partFilePath[0:filenameIndex]
-			}
-		} else if formname == innerDirFile { // get file path, strip first level of dir
-			if filenameIndex <= 0 {
-				continue
-			}
-			filepath := partFilePath[0:filenameIndex]
-			if prefixEndIndex := strings.IndexByte(filepath, '/'); prefixEndIndex > 0 {
-				fsInfix = filepath[prefixEndIndex+1:]
-			}
-		} else if formname == file {
-			// noop
-		} else {
-			errs = append(errs, errors.New("upload: unknown mode "+formname))
-			continue
-		}
+	info, _ := os.Lstat(tryPath)
+	switch {
+	case info == nil && conflict == uploadConflictReject:
+		return tryPath, uploadCommitExclusive, false, nil
+	case info == nil && (conflict == uploadConflictOverwrite || conflict == uploadConflictNewer):
+		// replace the one created meanwhile, as if it existed
+		return tryPath, uploadCommitReplace, false, nil
+	case info == nil:
+	case conflict == uploadConflictReject:
+		errs = append(errs, fmt.Errorf("upload: %w %s", errUploadExists, tryPath))
+		return
+	case info.IsDir():
+	case conflict == uploadConflictNewer && !mtime.IsZero():
+		return tryPath, uploadCommitReplace, !mtime.After(info.ModTime()), nil
+	case conflict == uploadConflictOverwrite:
+		return tryPath, uploadCommitReplace, false, nil
+	}
 
-		filePrefix := fsPrefix
-		if len(fsInfix) > 0 {
-			if !createDir {
-				errs = append(errs, errors.New("upload: mkdir is not enabled for "+fsPrefix))
-				continue
-			}
+	return tryPath, uploadCommitAvailable, false, nil
+}
 
-			if len(aliasSubItems) > 0 {
-				fsInfixPart1 := fsInfix
-				fsInfixSlashIndex := strings.IndexByte(fsInfixPart1, '/')
-				if fsInfixSlashIndex > 0 {
-					fsInfixPart1 = fsInfixPart1[0:fsInfixSlashIndex]
-				}
-				if containsItem(aliasSubItems, fsInfixPart1) {
-					errs = append(errs, errors.New("upload: ignore path shadowed by alias "+fsInfix))
-					continue
-				}
-			}
+// setUploadMtime sets modification time of uploaded file to the one provided by client.
+func setUploadMtime(fsPath string, mtime time.Time) error {
+	if mtime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(fsPath, mtime, mtime)
+}
 
-			filePrefix += "/" + fsInfix
-			err := os.MkdirAll(filePrefix, 0755)
-			if err != nil {
-				errs = append(errs, err)
-				continue
-			}
-		}
+// saveUploadPart saves a file part of multipart request,
+// returns nil result if the part is not a file to be saved.
+func (h *aliasHandler) saveUploadPart(authUserName, rawReqPath, fsPrefix string, part *multipart.Part, fields *uploadPartFields, createDir bool, conflict uploadConflict, aliasSubItems []os.FileInfo, r *http.Request) (result *uploadResult, errs []error) {
+	inputPartFilePath := getPartFilePath(part)
+	if len(inputPartFilePath) == 0 {
+		return
+	}
+	result = &uploadResult{Name: inputPartFilePath}
 
-		filename := partFilePath
-		if filenameIndex >= 0 {
-			filename = filename[filenameIndex+1:]
+	partFilePath, err := h.getUploadFilePath(inputPartFilePath)
+	if err != nil {
+		result.Error = err.Error()
+		errs = append(errs, fmt.Errorf("upload: %w %s", err, inputPartFilePath))
+		return
+	}
+
+	filenameIndex := strings.LastIndexByte(partFilePath, '/')
+
+	fsInfix := ""
+	formname := part.FormName()
+	if formname == dirFile {
+		if filenameIndex > 0 {
+			fsInfix = partFilePath[0:filenameIndex]
 		}
-		if len(filename) == 0 {
-			continue
+	} else if formname == innerDirFile { // get file path, strip first level of dir
+		if filenameIndex <= 0 {
+			return nil, nil
+		}
+		filepath := partFilePath[0:filenameIndex]
+		if prefixEndIndex := strings.IndexByte(filepath, '/'); prefixEndIndex > 0 {
+			fsInfix = filepath[prefixEndIndex+1:]
 		}
+	} else if formname == file {
+		// noop
+	} else {
+		result.Error = "unknown mode"
+		errs = append(errs, errors.New("upload: unknown mode "+formname))
+		return
+	}
 
-		isFilenameAliased := len(fsInfix) == 0 && containsItem(aliasSubItems, filename)
-		var fsFilename string
-		if overwriteExists && !isFilenameAliased {
-			tryPath := filePrefix + "/" + filename
-			var info os.FileInfo
-			info, err = os.Lstat(tryPath)
-			if info != nil && !info.IsDir() {
-				err = os.Remove(tryPath)
-				if err != nil && !os.IsNotExist(err) {
-					errs = append(errs, err)
-				}
-				// even remove failed, still try to write content to file by TRUNCATE mode
-				fsFilename = filename
-			}
+	filename := partFilePath
+	if filenameIndex >= 0 {
+		filename = filename[filenameIndex+1:]
+	}
+	if len(filename) == 0 {
+		return nil, nil
+	}
+
+	expectedDigests, err := getExpectedDigests(part.Header, fields.digest)
+	var content io.Reader
+	if err == nil {
+		content, err = h.newUploadLimitReader(part, filepath.Join(fsPrefix, fsInfix), 0)
+	}
+	if err == nil {
+		content, err = h.checkUploadContent(filename, content)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		errs = append(errs, err)
+		return
+	}
+
+	fsPath, mode, skip, errs := getUploadFsPath(fsPrefix, fsInfix, filename, createDir, conflict, fields.mtime, aliasSubItems)
+	if len(fsPath) == 0 {
+		if uploadErr := getUploadError(errs); uploadErr != nil {
+			result.Error = uploadErr.message
+		} else {
+			result.Error = "cannot save file"
 		}
-		if len(fsFilename) == 0 {
-			fsFilename = getAvailableFilename(filePrefix, filename, isFilenameAliased)
+		return
+	}
+	if skip {
+		result.To = path.Join(rawReqPath, fsInfix, filepath.Base(fsPath))
+		result.Success = true
+		result.Skipped = true
+		return
+	}
+
+	h.logUpload(authUserName, filename, fsPath, r)
+	digester := newUploadDigester(content, expectedDigests)
+	fsPath, err = writeUploadFile(fsPath, mode, digester)
+	if err != nil {
+		if uploadErr := getUploadError([]error{err}); uploadErr != nil {
+			result.Error = uploadErr.message
+		} else {
+			result.Error = "cannot save file"
 		}
-		if len(fsFilename) == 0 {
-			err := errors.New("no available filename for " + filename)
-			errs = append(errs, err)
-			continue
+		errs = append(errs, err)
+		return
+	}
+	errs = serverError.AppendError(errs, setUploadMtime(fsPath, fields.mtime))
+
+	result.To = path.Join(rawReqPath, fsInfix, filepath.Base(fsPath))
+	result.Digest = digester.digests()
+	result.Success = true
+	return
+}
+
+// getUploadResultsText describes failed files of uploading in plain text, one per line.
+func getUploadResultsText(results []uploadResult) string {
+	buf := &strings.Builder{}
+	for _, result := range results {
+		if !result.Success {
+			buf.WriteString(result.Name)
+			buf.WriteString(": ")
+			buf.WriteString(result.Error)
+			buf.WriteByte('\n')
 		}
+	}
+	return buf.String()
+}
+
+func (h *aliasHandler) saveUploadFiles(authUserName, rawReqPath, fsPrefix string, createDir bool, conflict uploadConflict, aliasSubItems []os.FileInfo, r *http.Request) (results []uploadResult, success bool, uploadErr *uploadError) {
+	var errs []error
+	var fields uploadPartFields
+	results = []uploadResult{}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		errs = append(errs, err)
+		return results, false, nil
+	}
 
-		fsPath := filepath.Join(filePrefix, fsFilename)
-		h.logUpload(authUserName, filename, fsPath, r)
-		file, err := os.OpenFile(fsPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	for {
+		part, err := reader.NextPart()
 		if err != nil {
-			errs = append(errs, err)
-			continue
+			if err != io.EOF {
+				errs = append(errs, err)
+			}
+			break
 		}
 
-		_, err = io.Copy(file, part)
-		if err != nil {
-			errs = append(errs, err)
+		if len(getPartFilePath(part)) == 0 {
+			switch part.FormName() {
+			case uploadMtimeField:
+				value, _ := io.ReadAll(io.LimitReader(part, maxUploadFieldSize))
+				fields.mtime = parseUploadMtime(string(value))
+			case uploadDigestField:
+				value, _ := io.ReadAll(io.LimitReader(part, maxUploadFieldSize))
+				fields.digest = string(value)
+			}
+			continue
 		}
 
-		err = file.Close()
-		if err != nil {
-			errs = append(errs, err)
+		result, partErrs := h.saveUploadPart(authUserName, rawReqPath, fsPrefix, part, &fields, createDir, conflict, aliasSubItems, r)
+		if result != nil {
+			results = append(results, *result)
 		}
+		errs = append(errs, partErrs...)
+		fields = uploadPartFields{}
 	}
 
+	uploadErr = getUploadError(errs)
 	if h.logErrors(errs) {
-		return false
+		return results, false, uploadErr
 	}
 
-	return true
+	return results, true, nil
 }
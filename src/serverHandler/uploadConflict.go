@@ -0,0 +1,102 @@
+package serverHandler
+
+import (
+	"mjpclab.dev/ghfs/src/util"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type uploadConflict uint8
+
+const (
+	// overwrite existing file if delete is allowed, otherwise rename
+	uploadConflictDefault uploadConflict = iota
+	uploadConflictRename
+	uploadConflictOverwrite
+	uploadConflictReject
+	uploadConflictNewer
+	// always rename, used by drop box to hide existing files
+	uploadConflictUnique
+)
+
+var errUploadExists = &uploadError{http.StatusConflict, "file already exists"}
+
+func parseUploadConflict(policy string) uploadConflict {
+	switch policy {
+	case "rename":
+		return uploadConflictRename
+	case "overwrite":
+		return uploadConflictOverwrite
+	case "reject":
+		return uploadConflictReject
+	case "newer":
+		return uploadConflictNewer
+	default:
+		return uploadConflictDefault
+	}
+}
+
+type pathUploadConflict struct {
+	path     string
+	conflict uploadConflict
+}
+
+func newPathUploadConflicts(entries [][2]string) []pathUploadConflict {
+	results := make([]pathUploadConflict, 0, len(entries))
+	for _, entry := range entries {
+		results = append(results, pathUploadConflict{entry[0], parseUploadConflict(entry[1])})
+	}
+	return results
+}
+
+// getUploadConflict finds conflict policy of the nearest url path,
+// then the nearest file system path, then the global one.
+// Default policy is resolved by whether deleting is allowed,
+// and policies that replace existing file fall back to rename if deleting is not allowed.
+// Files uploaded to drop box are always renamed.
+func (h *aliasHandler) getUploadConflict(rawReqPath, fsPath string, canDelete bool) uploadConflict {
+	if h.isDropbox(rawReqPath, fsPath) {
+		return uploadConflictUnique
+	}
+
+	matchLen := -1
+	conflict := h.globalUploadConflict
+	for _, item := range h.uploadConflictUrls {
+		if len(item.path) > matchLen && util.HasUrlPrefixDir(rawReqPath, item.path) {
+			matchLen = len(item.path)
+			conflict = item.conflict
+		}
+	}
+	if matchLen < 0 {
+		for _, item := range h.uploadConflictDirs {
+			if len(item.path) > matchLen && util.HasFsPrefixDir(fsPath, item.path) {
+				matchLen = len(item.path)
+				conflict = item.conflict
+			}
+		}
+	}
+
+	switch conflict {
+	case uploadConflictDefault:
+		if canDelete {
+			return uploadConflictOverwrite
+		}
+		return uploadConflictRename
+	case uploadConflictOverwrite, uploadConflictNewer:
+		if !canDelete {
+			return uploadConflictRename
+		}
+	}
+	return conflict
+}
+
+// parseUploadMtime parses client modification time in milliseconds since epoch,
+// e.g. `File.lastModified` of browser.
+func parseUploadMtime(input string) time.Time {
+	ms, err := strconv.ParseInt(input, 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
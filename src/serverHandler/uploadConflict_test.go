@@ -0,0 +1,168 @@
+package serverHandler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetUploadConflict(t *testing.T) {
+	h := &aliasHandler{
+		uploadConflictUrls: newPathUploadConflicts([][2]string{{"/a", "reject"}, {"/a/b", "newer"}}),
+		uploadConflictDirs: newPathUploadConflicts([][2]string{{"/fs/c", "overwrite"}}),
+	}
+
+	if conflict := h.getUploadConflict("/a/b/c", "/fs/c", true); conflict != uploadConflictNewer {
+		t.Error(conflict)
+	}
+	if conflict := h.getUploadConflict("/a/x", "/fs/c", false); conflict != uploadConflictReject {
+		t.Error(conflict)
+	}
+	if conflict := h.getUploadConflict("/x", "/fs/c/d", true); conflict != uploadConflictOverwrite {
+		t.Error(conflict)
+	}
+
+	// replacing existing file requires delete permission
+	if conflict := h.getUploadConflict("/a/b/c", "/fs/c", false); conflict != uploadConflictRename {
+		t.Error(conflict)
+	}
+	if conflict := h.getUploadConflict("/x", "/fs/c/d", false); conflict != uploadConflictRename {
+		t.Error(conflict)
+	}
+	if conflict := h.getUploadConflict("/x", "/fs/x", false); conflict != uploadConflictRename {
+		t.Error(conflict)
+	}
+	if conflict := h.getUploadConflict("/x", "/fs/x", true); conflict != uploadConflictOverwrite {
+		t.Error(conflict)
+	}
+
+	h.globalUploadConflict = uploadConflictReject
+	if conflict := h.getUploadConflict("/x", "/fs/x", true); conflict != uploadConflictReject {
+		t.Error(conflict)
+	}
+
+	h.dropboxUrls = []string{"/a/b/inbox"}
+	h.dropboxDirs = []string{"/fs/inbox"}
+	if conflict := h.getUploadConflict("/a/b/inbox", "/fs/x", true); conflict != uploadConflictUnique {
+		t.Error(conflict)
+	}
+	if conflict := h.getUploadConflict("/x", "/fs/inbox/sub", true); conflict != uploadConflictUnique {
+		t.Error(conflict)
+	}
+}
+
+func TestGetUploadFsPathConflict(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(existing, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(existing, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	fsPath, mode, skip, errs := getUploadFsPath(dir, "", "file.txt", false, uploadConflictRename, time.Time{}, nil)
+	if fsPath != existing || mode != uploadCommitAvailable || skip || len(errs) > 0 {
+		t.Error(fsPath, mode, skip, errs)
+	}
+
+	fsPath, mode, skip, errs = getUploadFsPath(dir, "", "file.txt", false, uploadConflictReject, time.Time{}, nil)
+	if fsPath != "" || getUploadError(errs) != errUploadExists {
+		t.Error(fsPath, errs)
+	}
+
+	fsPath, mode, skip, errs = getUploadFsPath(dir, "", "file.txt", false, uploadConflictNewer, modTime, nil)
+	if fsPath != existing || mode != uploadCommitReplace || !skip || len(errs) > 0 {
+		t.Error(fsPath, skip, errs)
+	}
+
+	fsPath, mode, skip, errs = getUploadFsPath(dir, "", "file.txt", false, uploadConflictNewer, time.Time{}, nil)
+	if fsPath != existing || mode != uploadCommitAvailable || skip || len(errs) > 0 {
+		t.Error(fsPath, mode, skip, errs)
+	}
+
+	fsPath, mode, skip, errs = getUploadFsPath(dir, "", "file.txt", false, uploadConflictNewer, modTime.Add(time.Second), nil)
+	if fsPath != existing || mode != uploadCommitReplace || skip || len(errs) > 0 {
+		t.Error(fsPath, skip, errs)
+	}
+	fsPath, mode, skip, errs = getUploadFsPath(dir, "", "file.txt", false, uploadConflictOverwrite, time.Time{}, nil)
+	if fsPath != existing || mode != uploadCommitReplace || skip || len(errs) > 0 {
+		t.Error(fsPath, skip, errs)
+	}
+
+	fsPath, mode, skip, errs = getUploadFsPath(dir, "", "unique.txt", false, uploadConflictUnique, time.Time{}, nil)
+	if fsPath != filepath.Join(dir, "unique.txt") || mode != uploadCommitUnique || skip || len(errs) > 0 {
+		t.Error(fsPath, mode, skip, errs)
+	}
+
+	fsPath, mode, skip, errs = getUploadFsPath(dir, "", "new.txt", false, uploadConflictReject, time.Time{}, nil)
+	if fsPath != filepath.Join(dir, "new.txt") || mode != uploadCommitExclusive || skip || len(errs) > 0 {
+		t.Error(fsPath, mode, skip, errs)
+	}
+
+	// nothing is created before committing
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Error(entries)
+	}
+}
+
+func TestCommitUploadFile(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(existing, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(content string, mode uploadCommitMode) (string, error) {
+		return writeUploadFile(existing, mode, strings.NewReader(content))
+	}
+	readFile := func(name string) string {
+		content, _ := os.ReadFile(filepath.Join(dir, name))
+		return string(content)
+	}
+
+	if fsPath, err := commit("a", uploadCommitAvailable); err != nil || fsPath != filepath.Join(dir, "file-1.txt") || readFile("file-1.txt") != "a" {
+		t.Error(fsPath, err)
+	}
+	if fsPath, err := commit("b", uploadCommitAvailable); err != nil || fsPath != filepath.Join(dir, "file-2.txt") || readFile("file-2.txt") != "b" {
+		t.Error("taken filename should not be used again", fsPath, err)
+	}
+	if fsPath, err := commit("c", uploadCommitExclusive); getUploadError([]error{err}) != errUploadExists || fsPath != "" {
+		t.Error(fsPath, err)
+	}
+	if fsPath, err := writeUploadFile(filepath.Join(dir, "new.txt"), uploadCommitUnique, strings.NewReader("d")); err != nil || fsPath != filepath.Join(dir, "new-1.txt") {
+		t.Error(fsPath, err)
+	}
+	if readFile("file.txt") != "existing" {
+		t.Error("existing file should be kept")
+	}
+	if fsPath, err := commit("e", uploadCommitReplace); err != nil || fsPath != existing || readFile("file.txt") != "e" {
+		t.Error(fsPath, err)
+	}
+
+	// temporary files are cleaned
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if isTempFileName(entry.Name()) {
+			t.Error(entry.Name())
+		}
+	}
+	if len(entries) != 4 {
+		t.Error(entries)
+	}
+}
+
+func TestParseUploadMtime(t *testing.T) {
+	if mtime := parseUploadMtime("1700000000123"); !mtime.Equal(time.UnixMilli(1700000000123)) {
+		t.Error(mtime)
+	}
+	if mtime := parseUploadMtime("abc"); !mtime.IsZero() {
+		t.Error(mtime)
+	}
+	if mtime := parseUploadMtime("0"); !mtime.IsZero() {
+		t.Error(mtime)
+	}
+}
@@ -0,0 +1,149 @@
+package serverHandler
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+const uploadDigestField = "digest"
+const defaultDigestAlgorithm = "sha-256"
+
+var errUploadDigestInvalid = &uploadError{http.StatusBadRequest, "invalid digest"}
+var errUploadDigestMismatch = &uploadError{http.StatusBadRequest, "file digest mismatch"}
+
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+	"md5":     md5.New,
+}
+
+func normalizeDigestAlgorithm(name string) string {
+	name = strings.ToLower(name)
+	switch name {
+	case "sha256":
+		return "sha-256"
+	case "sha512":
+		return "sha-512"
+	}
+	return name
+}
+
+// parseDigests parses value of "Content-Digest" or "Repr-Digest" header(RFC 9530),
+// e.g. `sha-256=:<base64>:, sha-512=:<base64>:`.
+// Hex encoded value like `sha256=<hex>` is also accepted for convenience.
+// Unsupported algorithms are ignored.
+func parseDigests(value string) (expected map[string][]byte, err error) {
+	for _, member := range strings.Split(value, ",") {
+		member = strings.TrimSpace(member)
+		if len(member) == 0 {
+			continue
+		}
+		eqIndex := strings.IndexByte(member, '=')
+		if eqIndex <= 0 {
+			return nil, errUploadDigestInvalid
+		}
+		algorithm := normalizeDigestAlgorithm(strings.TrimSpace(member[:eqIndex]))
+		if digestAlgorithms[algorithm] == nil {
+			continue
+		}
+
+		encoded := strings.TrimSpace(member[eqIndex+1:])
+		var sum []byte
+		if len(encoded) >= 2 && encoded[0] == ':' && encoded[len(encoded)-1] == ':' {
+			sum, err = base64.StdEncoding.DecodeString(encoded[1 : len(encoded)-1])
+		} else {
+			sum, err = hex.DecodeString(encoded)
+		}
+		if err != nil || len(sum) != digestAlgorithms[algorithm]().Size() {
+			return nil, errUploadDigestInvalid
+		}
+
+		if expected == nil {
+			expected = map[string][]byte{}
+		}
+		expected[algorithm] = sum
+	}
+
+	return
+}
+
+// getExpectedDigests gets expected digests from header of multipart part or request,
+// or from preceding companion form field.
+func getExpectedDigests(header textproto.MIMEHeader, fieldValue string) (map[string][]byte, error) {
+	value := header.Get("Content-Digest")
+	if len(value) == 0 {
+		value = header.Get("Repr-Digest")
+	}
+	if len(value) == 0 {
+		value = fieldValue
+	}
+	return parseDigests(value)
+}
+
+// uploadDigester computes digests of content while reading,
+// and fails at the end of content if any expected digest mismatches.
+type uploadDigester struct {
+	reader   io.Reader
+	expected map[string][]byte
+	hashes   map[string]hash.Hash
+	writer   io.Writer
+}
+
+func newUploadDigester(reader io.Reader, expected map[string][]byte) *uploadDigester {
+	hashes := map[string]hash.Hash{defaultDigestAlgorithm: digestAlgorithms[defaultDigestAlgorithm]()}
+	for algorithm := range expected {
+		if hashes[algorithm] == nil {
+			hashes[algorithm] = digestAlgorithms[algorithm]()
+		}
+	}
+
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+
+	return &uploadDigester{
+		reader:   reader,
+		expected: expected,
+		hashes:   hashes,
+		writer:   io.MultiWriter(writers...),
+	}
+}
+
+func (d *uploadDigester) Read(p []byte) (n int, err error) {
+	n, err = d.reader.Read(p)
+	if n > 0 {
+		d.writer.Write(p[:n])
+	}
+	if err == io.EOF {
+		for algorithm, sum := range d.expected {
+			if !bytes.Equal(d.hashes[algorithm].Sum(nil), sum) {
+				return n, errUploadDigestMismatch
+			}
+		}
+	}
+	return
+}
+
+// digests returns hex encoded digests of content read.
+func (d *uploadDigester) digests() map[string]string {
+	results := make(map[string]string, len(d.hashes))
+	for algorithm, h := range d.hashes {
+		results[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+	return results
+}
+
+// reprDigest formats digest of default algorithm as "Repr-Digest" header value.
+func (d *uploadDigester) reprDigest() string {
+	return defaultDigestAlgorithm + "=:" + base64.StdEncoding.EncodeToString(d.hashes[defaultDigestAlgorithm].Sum(nil)) + ":"
+}
@@ -0,0 +1,60 @@
+package serverHandler
+
+import (
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+const helloSha256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+const helloMd5 = "5d41402abc4b2a76b9719d911017c592"
+
+func TestParseDigests(t *testing.T) {
+	expected, err := parseDigests("sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:, MD5=" + helloMd5 + ", unixsum=:AAA=:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) != 2 {
+		t.Error(expected)
+	}
+	if hex.EncodeToString(expected["sha-256"]) != helloSha256 {
+		t.Error(expected["sha-256"])
+	}
+	if hex.EncodeToString(expected["md5"]) != helloMd5 {
+		t.Error(expected["md5"])
+	}
+
+	if expected, err = parseDigests(""); err != nil || expected != nil {
+		t.Error(expected, err)
+	}
+	if _, err = parseDigests("sha256=" + helloMd5); err != errUploadDigestInvalid {
+		t.Error(err)
+	}
+	if _, err = parseDigests("sha-512=:not base64:"); err != errUploadDigestInvalid {
+		t.Error(err)
+	}
+	if _, err = parseDigests("sha-256"); err != errUploadDigestInvalid {
+		t.Error(err)
+	}
+}
+
+func TestUploadDigester(t *testing.T) {
+	expected, _ := parseDigests("md5=" + helloMd5)
+	digester := newUploadDigester(strings.NewReader("hello"), expected)
+	if content, err := io.ReadAll(digester); err != nil || string(content) != "hello" {
+		t.Error(string(content), err)
+	}
+	digests := digester.digests()
+	if len(digests) != 2 || digests["sha-256"] != helloSha256 || digests["md5"] != helloMd5 {
+		t.Error(digests)
+	}
+	if reprDigest := digester.reprDigest(); reprDigest != "sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:" {
+		t.Error(reprDigest)
+	}
+
+	digester = newUploadDigester(strings.NewReader("hello!"), expected)
+	if _, err := io.ReadAll(digester); err != errUploadDigestMismatch {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,183 @@
+package serverHandler
+
+import (
+	"errors"
+	"io"
+	"mjpclab.dev/ghfs/src/util"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const uploadFreeSpaceCheckInterval = 4 << 20
+
+type pathSize struct {
+	path string
+	size int64
+}
+
+// uploadError is an error that should be reported to client.
+type uploadError struct {
+	status  int
+	message string
+}
+
+func (err *uploadError) Error() string {
+	return err.message
+}
+
+var errUploadFileTooLarge = &uploadError{http.StatusRequestEntityTooLarge, "uploaded file size exceeds limit"}
+var errUploadBodyTooLarge = &uploadError{http.StatusRequestEntityTooLarge, "request body size exceeds limit"}
+var errUploadQuotaExceeded = &uploadError{http.StatusInsufficientStorage, "upload directory quota exceeded"}
+var errUploadNoSpace = &uploadError{http.StatusInsufficientStorage, "insufficient free space"}
+
+// getUploadError finds first error should be reported to client.
+func getUploadError(errs []error) *uploadError {
+	for _, err := range errs {
+		var uploadErr *uploadError
+		if errors.As(err, &uploadErr) {
+			return uploadErr
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return errUploadBodyTooLarge
+		}
+	}
+	return nil
+}
+
+func newPathSizes(entries [][2]string) []pathSize {
+	results := make([]pathSize, 0, len(entries))
+	for _, entry := range entries {
+		size, err := util.ParseSize(entry[1])
+		if err != nil {
+			continue
+		}
+		results = append(results, pathSize{entry[0], size})
+	}
+	return results
+}
+
+// getDiskFree gets free space of the nearest existing directory.
+func getDiskFree(dir string) (free uint64, ok bool) {
+	for {
+		if free, ok = util.GetDiskFree(dir); ok {
+			return
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dir = parent
+	}
+}
+
+func getDirSize(dir string) (size int64) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}
+
+// getUploadQuota finds quota of the nearest directory that contains fsPath.
+func (h *aliasHandler) getUploadQuota(fsPath string) (quota pathSize, ok bool) {
+	for _, item := range h.uploadQuotaDirs {
+		if util.HasFsPrefixDir(fsPath, item.path) && (!ok || len(item.path) > len(quota.path)) {
+			quota = item
+			ok = true
+		}
+	}
+	return
+}
+
+// limitUploadBody limits request body size, returns false if it is known to be too large.
+func (h *aliasHandler) limitUploadBody(w http.ResponseWriter, r *http.Request) bool {
+	if h.uploadMaxBodySize <= 0 {
+		return true
+	}
+	if r.ContentLength > h.uploadMaxBodySize {
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.uploadMaxBodySize)
+	return true
+}
+
+// checkUploadSize checks if a file of known size can be saved into dir.
+func (h *aliasHandler) checkUploadSize(dir string, size int64) error {
+	if h.uploadMaxFileSize > 0 && size > h.uploadMaxFileSize {
+		return errUploadFileTooLarge
+	}
+	if quota, ok := h.getUploadQuota(dir); ok && getDirSize(quota.path)+size > quota.size {
+		return errUploadQuotaExceeded
+	}
+	if h.uploadMinFreeSpace > 0 {
+		if free, ok := getDiskFree(dir); ok && int64(free)-size < h.uploadMinFreeSpace {
+			return errUploadNoSpace
+		}
+	}
+	return nil
+}
+
+type uploadLimitReader struct {
+	reader  io.Reader
+	dir     string
+	read    int64
+	maxSize int64 // negative for no limit
+	maxErr  error
+	minFree int64
+	checked int64
+}
+
+// newUploadLimitReader wraps content of file to be saved into dir,
+// fails reading if exceeds file size limit, directory quota or free space threshold.
+// written is the size already saved, e.g. resumed upload.
+func (h *aliasHandler) newUploadLimitReader(reader io.Reader, dir string, written int64) (io.Reader, error) {
+	if h.uploadMaxFileSize <= 0 && h.uploadMinFreeSpace <= 0 && len(h.uploadQuotaDirs) == 0 {
+		return reader, nil
+	}
+
+	if err := h.checkUploadSize(dir, 0); err != nil {
+		return nil, err
+	}
+
+	limitReader := &uploadLimitReader{
+		reader:  reader,
+		dir:     dir,
+		read:    written,
+		maxSize: -1,
+		minFree: h.uploadMinFreeSpace,
+		checked: written,
+	}
+	if h.uploadMaxFileSize > 0 {
+		limitReader.maxSize = h.uploadMaxFileSize
+		limitReader.maxErr = errUploadFileTooLarge
+	}
+	if quota, ok := h.getUploadQuota(dir); ok {
+		// size of resumed part already counted in directory size
+		remain := quota.size - getDirSize(quota.path) + written
+		if limitReader.maxSize < 0 || remain < limitReader.maxSize {
+			limitReader.maxSize = remain
+			limitReader.maxErr = errUploadQuotaExceeded
+		}
+	}
+	return limitReader, nil
+}
+
+func (r *uploadLimitReader) Read(p []byte) (n int, err error) {
+	if r.minFree > 0 && r.read-r.checked >= uploadFreeSpaceCheckInterval {
+		r.checked = r.read
+		if free, ok := getDiskFree(r.dir); ok && int64(free)-int64(len(p)) < r.minFree {
+			return 0, errUploadNoSpace
+		}
+	}
+
+	n, err = r.reader.Read(p)
+	r.read += int64(n)
+	if r.maxSize >= 0 && r.read > r.maxSize {
+		return n, r.maxErr
+	}
+	return
+}
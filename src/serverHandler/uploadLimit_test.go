@@ -0,0 +1,51 @@
+package serverHandler
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestUploadLimitReader(t *testing.T) {
+	h := &aliasHandler{uploadMaxFileSize: 5}
+
+	reader, err := h.newUploadLimitReader(strings.NewReader("12345"), t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := io.ReadAll(reader); err != nil || string(content) != "12345" {
+		t.Error(string(content), err)
+	}
+
+	reader, _ = h.newUploadLimitReader(strings.NewReader("123456"), t.TempDir(), 0)
+	if _, err = io.ReadAll(reader); err != errUploadFileTooLarge {
+		t.Error(err)
+	}
+
+	reader, _ = h.newUploadLimitReader(strings.NewReader("123"), t.TempDir(), 3)
+	if _, err = io.ReadAll(reader); err != errUploadFileTooLarge {
+		t.Error(err)
+	}
+}
+
+func TestGetUploadQuota(t *testing.T) {
+	h := &aliasHandler{uploadQuotaDirs: newPathSizes([][2]string{
+		{"/data", "1G"},
+		{"/data/sub", "1M"},
+		{"/other", "invalid"},
+	})}
+
+	quota, ok := h.getUploadQuota("/data/sub/dir")
+	if !ok || quota.size != 1<<20 {
+		t.Error(quota)
+	}
+
+	quota, ok = h.getUploadQuota("/data/subdir")
+	if !ok || quota.size != 1<<30 {
+		t.Error(quota)
+	}
+
+	if _, ok = h.getUploadQuota("/other"); ok {
+		t.Error()
+	}
+}
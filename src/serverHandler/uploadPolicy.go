@@ -0,0 +1,194 @@
+package serverHandler
+
+import (
+	"bytes"
+	"io"
+	"mjpclab.dev/ghfs/src/util"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxUploadNameLen = 255
+const uploadSniffLen = 512
+
+var errUploadIllegalPath = &uploadError{http.StatusBadRequest, "illegal file path"}
+var errUploadNameNotAllowed = &uploadError{http.StatusForbidden, "file name not allowed"}
+var errUploadTypeMismatch = &uploadError{http.StatusUnsupportedMediaType, "file content does not match its type"}
+
+var executableSignatures = [][]byte{
+	[]byte("\x7fELF"),
+	[]byte("MZ"),
+	{0xfe, 0xed, 0xfa, 0xce},
+	{0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe},
+	{0xcf, 0xfa, 0xed, 0xfe},
+	{0xca, 0xfe, 0xba, 0xbe},
+}
+
+// truncateUtf8 shortens s to at most maxLen bytes without breaking a character.
+func truncateUtf8(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	for maxLen > 0 && !utf8.RuneStart(s[maxLen]) {
+		maxLen--
+	}
+	return s[:maxLen]
+}
+
+// sanitizeFilename strips control characters and trailing dots and spaces from a file name,
+// and shortens it to max length while keeping its suffix.
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+
+	if len(name) > maxUploadNameLen {
+		prefix, suffix := util.SplitFilename(name)
+		if len(suffix) >= maxUploadNameLen/2 {
+			prefix, suffix = name, ""
+		}
+		prefix = strings.TrimRight(truncateUtf8(prefix, maxUploadNameLen-len(suffix)), ". ")
+		name = prefix + suffix
+	}
+
+	return name
+}
+
+// sanitizeFilePath sanitizes each segment of a relative file path,
+// empty segments are dropped.
+func sanitizeFilePath(filePath string) string {
+	segments := strings.Split(strings.Replace(filePath, "\\", "/", -1), "/")
+	results := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment = sanitizeFilename(segment); len(segment) > 0 {
+			results = append(results, segment)
+		}
+	}
+	return strings.Join(results, "/")
+}
+
+func isExecutableContent(head []byte) bool {
+	for _, signature := range executableSignatures {
+		if bytes.HasPrefix(head, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+func getMajorType(ctype string) string {
+	if slashIndex := strings.IndexByte(ctype, '/'); slashIndex > 0 {
+		return ctype[:slashIndex]
+	}
+	return ctype
+}
+
+func isMediaMajorType(major string) bool {
+	return major == "image" || major == "audio" || major == "video"
+}
+
+// isContentTypeMatch checks if head of file content is consistent with the type of its name.
+// Executables disguised as media or text files,
+// and media files which are actually something else are treated as mismatch.
+func isContentTypeMatch(filename string, head []byte) bool {
+	declaredType, _ := util.GetContentType(filename, bytes.NewReader(nil))
+	if len(declaredType) == 0 {
+		return true
+	}
+	declaredMajor := getMajorType(declaredType)
+
+	if isExecutableContent(head) {
+		return !isMediaMajorType(declaredMajor) && declaredMajor != "text"
+	}
+
+	if !isMediaMajorType(declaredMajor) {
+		return true
+	}
+	sniffedType, err := util.GetContentType("", bytes.NewReader(head))
+	if err != nil {
+		return true
+	}
+	sniffedMajor := getMajorType(sniffedType)
+	switch {
+	case isMediaMajorType(sniffedMajor):
+		return true
+	case strings.HasPrefix(sniffedType, "application/octet-stream"), strings.HasPrefix(sniffedType, "application/ogg"):
+		// unrecognized content
+		return true
+	case sniffedMajor == "text" && strings.Contains(declaredType, "+xml"):
+		// e.g. svg
+		return true
+	}
+	return false
+}
+
+// getUploadFilePath cleans and validates relative path of uploading file against name policy.
+func (h *aliasHandler) getUploadFilePath(inputFilePath string) (filePath string, err error) {
+	if h.uploadSanitizeName {
+		inputFilePath = sanitizeFilePath(inputFilePath)
+		if len(inputFilePath) == 0 {
+			return "", errUploadIllegalPath
+		}
+	}
+
+	filePath, ok := getCleanDirFilePath(inputFilePath)
+	if !ok {
+		return "", errUploadIllegalPath
+	}
+
+	filename := filePath[strings.LastIndexByte(filePath, '/')+1:]
+	if hasReservedName(filePath) || !h.isUploadNameAllowed(filename) {
+		return "", errUploadNameNotAllowed
+	}
+
+	return filePath, nil
+}
+
+// checkUploadFilePath validates path of file to be created other than uploading,
+// e.g. put, move or copy, which must be clean already.
+func (h *aliasHandler) checkUploadFilePath(filePath string) error {
+	cleanFilePath, err := h.getUploadFilePath(filePath)
+	if err == nil && cleanFilePath != filePath {
+		err = errUploadIllegalPath
+	}
+	return err
+}
+
+// isUploadNameAllowed checks file name against deny list, then allow list if provided.
+func (h *aliasHandler) isUploadNameAllowed(filename string) bool {
+	if h.uploadDenies != nil && h.uploadDenies.MatchString(filename) {
+		return false
+	}
+	if h.uploadAllows != nil && !h.uploadAllows.MatchString(filename) {
+		return false
+	}
+	return true
+}
+
+// checkUploadContent sniffs head of content if enabled,
+// returns a reader that still provides the whole content.
+func (h *aliasHandler) checkUploadContent(filename string, content io.Reader) (io.Reader, error) {
+	if !h.uploadSniffType {
+		return content, nil
+	}
+
+	head := make([]byte, uploadSniffLen)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	if !isContentTypeMatch(filename, head) {
+		return nil, errUploadTypeMismatch
+	}
+
+	return io.MultiReader(bytes.NewReader(head), content), nil
+}
@@ -0,0 +1,128 @@
+package serverHandler
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	if name := sanitizeFilename("a\x00b\x1fc\x7f.txt. . "); name != "abc.txt" {
+		t.Error(name)
+	}
+	if name := sanitizeFilename(" ..."); name != "" {
+		t.Error(name)
+	}
+
+	long := strings.Repeat("中", 100) + ".tar.gz"
+	name := sanitizeFilename(long)
+	if len(name) > maxUploadNameLen || !strings.HasSuffix(name, ".tar.gz") || !strings.HasPrefix(name, "中中") {
+		t.Error(len(name), name)
+	}
+	if name[:len(name)-7] != strings.Repeat("中", (maxUploadNameLen-7)/3) {
+		t.Error(name)
+	}
+
+	longSuffix := "a." + strings.Repeat("b", 300)
+	if name := sanitizeFilename(longSuffix); len(name) != maxUploadNameLen {
+		t.Error(len(name))
+	}
+}
+
+func TestSanitizeFilePath(t *testing.T) {
+	if filePath := sanitizeFilePath("dir. /sub\x01\\file.txt "); filePath != "dir/sub/file.txt" {
+		t.Error(filePath)
+	}
+	if filePath := sanitizeFilePath("../../file"); filePath != "file" {
+		t.Error(filePath)
+	}
+}
+
+func TestIsContentTypeMatch(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	elf := []byte("\x7fELF\x02\x01\x01")
+	html := []byte("<html><script></script></html>")
+
+	if !isContentTypeMatch("a.png", png) {
+		t.Error("png")
+	}
+	if !isContentTypeMatch("a.jpg", png) {
+		t.Error("png as jpg")
+	}
+	if isContentTypeMatch("a.jpg", elf) {
+		t.Error("elf as jpg")
+	}
+	if isContentTypeMatch("a.txt", []byte("MZ\x90\x00")) {
+		t.Error("exe as txt")
+	}
+	if isContentTypeMatch("a.gif", html) {
+		t.Error("html as gif")
+	}
+	if !isContentTypeMatch("a.svg", []byte("<?xml version=\"1.0\"?><svg></svg>")) {
+		t.Error("svg")
+	}
+	if !isContentTypeMatch("a.bin", elf) {
+		t.Error("elf as bin")
+	}
+	if !isContentTypeMatch("noext", elf) {
+		t.Error("elf without extension")
+	}
+	if !isContentTypeMatch("a.png", nil) {
+		t.Error("empty png")
+	}
+}
+
+func TestGetUploadFilePath(t *testing.T) {
+	h := &aliasHandler{
+		uploadAllows: regexp.MustCompile(`^.*\.txt$|^.*\.exe$`),
+		uploadDenies: regexp.MustCompile(`^.*\.exe$`),
+	}
+
+	if filePath, err := h.getUploadFilePath("dir/a.txt"); err != nil || filePath != "dir/a.txt" {
+		t.Error(filePath, err)
+	}
+	if _, err := h.getUploadFilePath("a.exe"); err != errUploadNameNotAllowed {
+		t.Error(err)
+	}
+	if _, err := h.getUploadFilePath("a.jpg"); err != errUploadNameNotAllowed {
+		t.Error(err)
+	}
+	if _, err := h.getUploadFilePath("../a.txt"); err != errUploadIllegalPath {
+		t.Error(err)
+	}
+	if _, err := h.getUploadFilePath("a.txt. "); err != errUploadNameNotAllowed {
+		t.Error(err)
+	}
+	if _, err := h.getUploadFilePath(".ghfs-tus/a.txt"); err != errUploadNameNotAllowed {
+		t.Error(err)
+	}
+	if _, err := h.getUploadFilePath("dir/.ghfs-upload-a.txt"); err != errUploadNameNotAllowed {
+		t.Error(err)
+	}
+
+	h.uploadSanitizeName = true
+	if filePath, err := h.getUploadFilePath("a.txt. "); err != nil || filePath != "a.txt" {
+		t.Error(filePath, err)
+	}
+	if _, err := h.getUploadFilePath("\x01. "); err != errUploadIllegalPath {
+		t.Error(err)
+	}
+}
+
+func TestCheckUploadContent(t *testing.T) {
+	h := &aliasHandler{uploadSniffType: true}
+
+	content := "\x89PNG\r\n\x1a\n" + strings.Repeat("0", 1000)
+	reader, err := h.checkUploadContent("a.png", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, _ := io.ReadAll(reader); string(result) != content {
+		t.Error(len(result))
+	}
+
+	if _, err = h.checkUploadContent("a.png", strings.NewReader("\x7fELF")); err != errUploadTypeMismatch {
+		t.Error(err)
+	}
+}
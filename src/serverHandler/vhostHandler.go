@@ -6,12 +6,15 @@ import (
 	"mjpclab.dev/ghfs/src/serverLog"
 	"mjpclab.dev/ghfs/src/tpl/theme"
 	"mjpclab.dev/ghfs/src/user"
+	"mjpclab.dev/ghfs/src/util"
 	"net/http"
 	"regexp"
+	"time"
 )
 
 type vhostContext struct {
 	users  *user.List
+	tokens *user.TokenList
 	theme  theme.Theme
 	logger *serverLog.Logger
 
@@ -22,13 +25,29 @@ type vhostContext struct {
 	hideDirs  *regexp.Regexp
 	hideFiles *regexp.Regexp
 
-	restrictAccess     bool
-	restrictAccessUrls []pathStrings
-	restrictAccessDirs []pathStrings
+	restrictAccess bool
 
 	headersUrls []pathHeaders
 	headersDirs []pathHeaders
 
+	uploadQuotaDirs []pathSize
+	uploadAllows    *regexp.Regexp
+	uploadDenies    *regexp.Regexp
+
+	uploadConflictUrls []pathUploadConflict
+	uploadConflictDirs []pathUploadConflict
+
+	policy *policy
+
+	sessionKey []byte
+	shareKey   []byte
+	oidc       *oidcProvider
+
+	authFailures *authFailures
+
+	davLocks   *davLocks
+	tusUploads *tusUploads
+
 	vary string
 }
 
@@ -38,7 +57,9 @@ func NewVhostHandler(
 	theme theme.Theme,
 ) (handler http.Handler, errs []error) {
 	// users
+	logReloadErrors := newReloadErrorsLogger(logger)
 	users := user.NewList(p.UserMatchCase)
+	users.OnReloadErrors(logReloadErrors)
 	for _, u := range p.UsersPlain {
 		errs = serverError.AppendError(errs, users.AddPlain(u[0], u[1]))
 	}
@@ -57,6 +78,19 @@ func NewVhostHandler(
 	for _, u := range p.UsersSha512 {
 		errs = serverError.AppendError(errs, users.AddSha512(u[0], u[1]))
 	}
+	for _, file := range p.UserFiles {
+		errs = append(errs, users.AddFile(file)...)
+	}
+
+	// tokens
+	tokens := user.NewTokenList()
+	tokens.OnReloadErrors(logReloadErrors)
+	for _, t := range p.Tokens {
+		errs = serverError.AppendError(errs, tokens.Add(t))
+	}
+	for _, file := range p.TokenFiles {
+		errs = append(errs, tokens.AddFile(file)...)
+	}
 
 	// show/hide
 	shows, err := wildcardToRegexp(p.Shows)
@@ -72,14 +106,40 @@ func NewVhostHandler(
 	hideFiles, err := wildcardToRegexp(p.HideFiles)
 	errs = serverError.AppendError(errs, err)
 
+	// upload name policy
+	uploadAllows, err := wildcardToRegexp(p.UploadAllows)
+	errs = serverError.AppendError(errs, err)
+	uploadDenies, err := wildcardToRegexp(p.UploadDenies)
+	errs = serverError.AppendError(errs, err)
+
+	// reverse proxy
+	trustedProxies, es := util.ParseIPNets(p.TrustedProxies)
+	errs = append(errs, es...)
+
+	// OIDC
+	oidc := newOidcProvider(p.OidcIssuer, p.OidcClientId, p.OidcClientSecret, p.OidcRedirectUrl, p.OidcScope, p.OidcUserClaim, p.OidcGroupsClaim)
+
+	// permission policy
+	// group names are kept for matching groups of OIDC users
+	userGroups := newUserGroups(p.UserGroups)
+	keepGroups := oidc != nil
+	policy, es := newPolicy(p, users, userGroups, keepGroups)
+	errs = append(errs, es...)
+
+	// session
+	sessionKey, err := newSessionKey(p.SessionSecret)
+	errs = serverError.AppendError(errs, err)
+
+	// share
+	shareKey, err := newSessionKey(p.ShareSecret)
+	errs = serverError.AppendError(errs, err)
+
 	if len(errs) > 0 {
 		return nil, errs
 	}
 
 	// restrict access
-	restrictAccessUrls := newRestrictAccesses(p.RestrictAccessUrls)
-	restrictAccessDirs := newRestrictAccesses(p.RestrictAccessDirs)
-	restrictAccess := hasRestrictAccess(p.GlobalRestrictAccess, restrictAccessUrls, restrictAccessDirs)
+	restrictAccess := policy.has(policyCapReferer)
 
 	// `Vary` header
 	vary := "accept-encoding"
@@ -90,6 +150,7 @@ func NewVhostHandler(
 	// alias param
 	vhostCtx := &vhostContext{
 		users:  users,
+		tokens: tokens,
 		theme:  theme,
 		logger: logger,
 
@@ -100,18 +161,37 @@ func NewVhostHandler(
 		hideDirs:  hideDirs,
 		hideFiles: hideFiles,
 
-		restrictAccess:     restrictAccess,
-		restrictAccessUrls: restrictAccessUrls,
-		restrictAccessDirs: restrictAccessDirs,
+		restrictAccess: restrictAccess,
 
 		headersUrls: newPathHeaders(p.HeadersUrls),
 		headersDirs: newPathHeaders(p.HeadersDirs),
 
+		uploadQuotaDirs: newPathSizes(p.UploadQuotaDirs),
+		uploadAllows:    uploadAllows,
+		uploadDenies:    uploadDenies,
+
+		uploadConflictUrls: newPathUploadConflicts(p.UploadConflictUrls),
+		uploadConflictDirs: newPathUploadConflicts(p.UploadConflictDirs),
+
+		policy: policy,
+
+		sessionKey: sessionKey,
+		shareKey:   shareKey,
+		oidc:       oidc,
+
+		authFailures: newAuthFailures(p.AuthFailDelayAfter, p.AuthFailLockoutAfter, time.Duration(p.AuthFailLockoutTime)*time.Second),
+
+		davLocks:   newDavLocks(),
+		tusUploads: newTusUploads(),
+
 		vary: vary,
 	}
 
 	handler = newMultiplexHandler(p, vhostCtx)
-	handler = newPreprocessHandler(logger, p.PreMiddlewares, handler)
+	handler = newPreprocessHandler(logger, newForwardAuth(p.ForwardAuth, p.ForwardAuthUserHeader), p.PreMiddlewares, handler)
 	handler = newPathTransformHandler(p.PrefixUrls, handler)
+	if len(trustedProxies) > 0 {
+		handler = newProxyHandler(trustedProxies, handler)
+	}
 	return
 }
@@ -0,0 +1,475 @@
+package serverHandler
+
+import (
+	"encoding/xml"
+	"io"
+	"mjpclab.dev/ghfs/src/user"
+	"mjpclab.dev/ghfs/src/util"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	methodPropfind = "PROPFIND"
+	methodMkcol    = "MKCOL"
+	methodCopy     = "COPY"
+	methodMove     = "MOVE"
+	methodLock     = "LOCK"
+	methodUnlock   = "UNLOCK"
+)
+
+const davAllowMethods = "OPTIONS, GET, HEAD, POST, PUT, DELETE, PROPFIND, MKCOL, COPY, MOVE, LOCK, UNLOCK"
+
+func isWebdavMethod(method string) bool {
+	switch method {
+	case http.MethodOptions,
+		http.MethodDelete,
+		methodPropfind,
+		methodMkcol,
+		methodCopy,
+		methodMove,
+		methodLock,
+		methodUnlock:
+		return true
+	}
+	return false
+}
+
+// davTarget is the item that WebDAV method operates on,
+// permissions are determined by its parent directory.
+type davTarget struct {
+	rawReqPath string
+	fsPath     string
+	name       string
+
+	parentRawReqPath    string
+	parentFsPath        string
+	parentItem          os.FileInfo
+	parentAliasSubItems []os.FileInfo
+}
+
+// getUrlPrefix returns the part of URL path that stripped by pathTransformHandler.
+func getUrlPrefix(prefixReqPath, rawReqPath string) string {
+	return strings.TrimSuffix(prefixReqPath, rawReqPath)
+}
+
+func getDavDepth(r *http.Request) int {
+	switch r.Header.Get("Depth") {
+	case "0":
+		return 0
+	case "1":
+		return 1
+	default:
+		return -1
+	}
+}
+
+// isOwnUrlPath reports whether rawReqPath is served by current alias handler
+// rather than by a successor alias.
+func (h *aliasHandler) isOwnUrlPath(rawReqPath string) bool {
+	if !util.HasUrlPrefixDir(rawReqPath, h.aliasPrefix) {
+		return false
+	}
+	for _, alias := range h.aliases {
+		if alias.isMatch(rawReqPath) || alias.isPredecessorOf(rawReqPath) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *aliasHandler) getDavTarget(rawReqPath string) (target *davTarget, ok bool) {
+	rawReqPath = util.CleanUrlPath(rawReqPath)
+	if util.IsPathEqual(rawReqPath, h.aliasPrefix) || !h.isOwnUrlPath(rawReqPath) {
+		return nil, false
+	}
+
+	reqPath := util.CleanUrlPath(rawReqPath[len(h.aliasPrefix):])
+	fsPath := filepath.Clean(h.root + reqPath)
+
+	target = &davTarget{
+		rawReqPath:       rawReqPath,
+		fsPath:           fsPath,
+		name:             path.Base(rawReqPath),
+		parentRawReqPath: path.Dir(rawReqPath),
+		parentFsPath:     filepath.Dir(fsPath),
+	}
+
+	if !h.emptyRoot {
+		target.parentItem, _ = os.Stat(target.parentFsPath)
+	}
+	var errs []error
+	_, target.parentAliasSubItems, errs = h.mergeAlias(target.parentRawReqPath, target.parentItem, nil, true)
+	h.logErrors(errs)
+
+	return target, true
+}
+
+func (h *aliasHandler) davCanUpload(r *http.Request, target *davTarget, data *responseData) bool {
+	return tokenAllows(data.authToken, user.TokenPermUpload) &&
+		h.getCanUpload(r, target.parentItem, target.parentRawReqPath, target.parentFsPath, data.AuthUserName) &&
+		!containsItem(target.parentAliasSubItems, target.name) && !isReservedName(target.name)
+}
+
+func (h *aliasHandler) davCanMkdir(r *http.Request, target *davTarget, data *responseData) bool {
+	return tokenAllows(data.authToken, user.TokenPermUpload) &&
+		h.getCanMkdir(r, target.parentItem, target.parentRawReqPath, target.parentFsPath, data.AuthUserName) &&
+		!containsItem(target.parentAliasSubItems, target.name) && !isReservedName(target.name)
+}
+
+func (h *aliasHandler) davCanDelete(r *http.Request, target *davTarget, data *responseData) bool {
+	return tokenAllows(data.authToken, user.TokenPermDelete) &&
+		h.getCanDelete(r, target.parentItem, target.parentRawReqPath, target.parentFsPath, data.AuthUserName) &&
+		!containsItem(target.parentAliasSubItems, target.name) && !isReservedName(target.name)
+}
+
+func (h *aliasHandler) serveWebdav(w http.ResponseWriter, r *http.Request, data *responseData) {
+	if r.Method != methodPropfind && data.File != nil {
+		// release file handle before modifying it
+		data.File.Close()
+	}
+
+	if r.Method != http.MethodOptions && r.Method != methodPropfind && h.logError(h.checkCsrf(r, data)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		h.davOptions(w)
+	case methodPropfind:
+		h.davPropfind(w, r, data)
+	case methodMkcol:
+		h.davMkcol(w, r, data)
+	case http.MethodDelete:
+		h.davDelete(w, r, data)
+	case methodCopy, methodMove:
+		h.davCopyMove(w, r, data, r.Method == methodMove)
+	case methodLock:
+		h.davLock(w, r, data)
+	case methodUnlock:
+		h.davUnlock(w, r, data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *aliasHandler) davOptions(w http.ResponseWriter) {
+	header := w.Header()
+	header.Set("DAV", "1, 2")
+	header.Set("MS-Author-Via", "DAV")
+	header.Set("Allow", davAllowMethods)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *aliasHandler) davMkcol(w http.ResponseWriter, r *http.Request, data *responseData) {
+	if r.ContentLength > 0 {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	target, ok := h.getDavTarget(data.rawReqPath)
+	if !ok || !h.davCanMkdir(r, target, data) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if data.Item != nil {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if target.parentItem == nil || !target.parentItem.IsDir() {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if h.davLocks.isLocked(target.fsPath, false, r.Header.Get("If")) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+
+	h.logMutate(data.AuthUserName, "mkdir", target.fsPath, r)
+	err := os.Mkdir(target.fsPath, 0755)
+	if h.logError(err) {
+		w.WriteHeader(getStatusByErr(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *aliasHandler) davDelete(w http.ResponseWriter, r *http.Request, data *responseData) {
+	if data.Item == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	target, ok := h.getDavTarget(data.rawReqPath)
+	if !ok || !h.davCanDelete(r, target, data) || h.containsDropbox(target.rawReqPath, target.fsPath) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if h.davLocks.isLocked(target.fsPath, true, r.Header.Get("If")) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+
+	h.logMutate(data.AuthUserName, "delete", target.fsPath, r)
+	err := os.RemoveAll(target.fsPath)
+	if h.logError(err) {
+		w.WriteHeader(getStatusByErr(err))
+		return
+	}
+	h.davLocks.removeUnder(target.fsPath)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *aliasHandler) getDavDestination(r *http.Request, data *responseData) (destRawReqPath string, status int) {
+	dest := r.Header.Get("Destination")
+	if len(dest) == 0 {
+		return "", http.StatusBadRequest
+	}
+	destUrl, err := url.Parse(dest)
+	if err != nil {
+		return "", http.StatusBadRequest
+	}
+	if len(destUrl.Host) > 0 && !strings.EqualFold(destUrl.Host, r.Host) {
+		return "", http.StatusBadGateway
+	}
+
+	destPath := destUrl.Path
+	prefix := getUrlPrefix(data.prefixReqPath, data.rawReqPath)
+	if len(prefix) > 0 {
+		if !util.HasUrlPrefixDir(destPath, prefix) {
+			return "", http.StatusBadGateway
+		}
+		destPath = destPath[len(prefix):]
+	}
+
+	return util.CleanUrlPath(destPath), http.StatusOK
+}
+
+func (h *aliasHandler) davCopyMove(w http.ResponseWriter, r *http.Request, data *responseData, isMove bool) {
+	if data.Item == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	isDir := data.Item.IsDir()
+
+	destRawReqPath, status := h.getDavDestination(r, data)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	source, ok := h.getDavTarget(data.rawReqPath)
+	if !ok || h.containsDropbox(source.rawReqPath, source.fsPath) || (isMove && !h.davCanDelete(r, source, data)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	// destination out of current alias is not supported,
+	// neither is drop box, whose existing items should not be revealed or replaced
+	dest, ok := h.getDavTarget(destRawReqPath)
+	if !ok || h.containsDropbox(dest.rawReqPath, dest.fsPath) || !h.canMutate(r, dest.rawReqPath, dest.fsPath) || !h.davCanUpload(r, dest, data) || (isDir && !h.davCanMkdir(r, dest, data)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !h.isAuthorized(r, dest.rawReqPath, dest.fsPath, data.AuthUserName, data.authToken) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !isDir {
+		if err := h.checkUploadFilePath(dest.name); h.logError(err) {
+			uploadErr := err.(*uploadError)
+			http.Error(w, uploadErr.message, uploadErr.status)
+			return
+		}
+	}
+	if isDir && (isMove || getDavDepth(r) != 0) &&
+		h.logError(h.checkSubItemsReadable(r, source.rawReqPath, source.fsPath, data.AuthUserName, data.authToken)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if util.HasFsPrefixDir(dest.fsPath, source.fsPath) || util.HasFsPrefixDir(source.fsPath, dest.fsPath) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if dest.parentItem == nil || !dest.parentItem.IsDir() {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	ifHeader := r.Header.Get("If")
+	if (isMove && h.davLocks.isLocked(source.fsPath, true, ifHeader)) || h.davLocks.isLocked(dest.fsPath, true, ifHeader) {
+		w.WriteHeader(http.StatusLocked)
+		return
+	}
+
+	_, err := os.Lstat(dest.fsPath)
+	destExists := err == nil
+	if destExists {
+		if r.Header.Get("Overwrite") == "F" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if !h.davCanDelete(r, dest, data) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		h.logMutate(data.AuthUserName, "delete", dest.fsPath, r)
+		err = os.RemoveAll(dest.fsPath)
+		if h.logError(err) {
+			w.WriteHeader(getStatusByErr(err))
+			return
+		}
+		h.davLocks.removeUnder(dest.fsPath)
+	}
+
+	var errs []error
+	if isMove {
+		h.logMutate(data.AuthUserName, "move", source.fsPath+" -> "+dest.fsPath, r)
+		errs = moveFsItem(source.fsPath, dest.fsPath)
+		h.davLocks.removeUnder(source.fsPath)
+	} else {
+		h.logMutate(data.AuthUserName, "copy", source.fsPath+" -> "+dest.fsPath, r)
+		errs = copyFsItem(source.fsPath, dest.fsPath, getDavDepth(r) != 0)
+	}
+	if h.logErrors(errs) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if destExists {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+type davLockInfo struct {
+	Exclusive *struct{} `xml:"lockscope>exclusive"`
+	Shared    *struct{} `xml:"lockscope>shared"`
+	Owner     struct {
+		InnerXML string `xml:",innerxml"`
+	} `xml:"owner"`
+}
+
+func getDavTimeout(r *http.Request) time.Duration {
+	for _, item := range strings.Split(r.Header.Get("Timeout"), ",") {
+		item = strings.TrimSpace(item)
+		if !strings.HasPrefix(item, "Second-") {
+			continue
+		}
+		seconds, err := strconv.Atoi(item[len("Second-"):])
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		timeout := time.Duration(seconds) * time.Second
+		if timeout > davMaxLockTimeout {
+			timeout = davMaxLockTimeout
+		}
+		return timeout
+	}
+	return davDefaultLockTimeout
+}
+
+func (h *aliasHandler) davLock(w http.ResponseWriter, r *http.Request, data *responseData) {
+	// locking in drop box reveals if item exists
+	target, ok := h.getDavTarget(data.rawReqPath)
+	if !ok || !h.davCanUpload(r, target, data) || h.isDropbox(target.rawReqPath, target.fsPath) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	timeout := getDavTimeout(r)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if h.logError(err) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var lock *davLock
+	status := http.StatusOK
+	if len(strings.TrimSpace(string(body))) == 0 {
+		// refresh existing lock
+		lock, ok = h.davLocks.refresh(target.fsPath, r.Header.Get("If"), timeout)
+		if !ok {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+	} else {
+		var info davLockInfo
+		if err = xml.Unmarshal(body, &info); err != nil || (info.Exclusive == nil && info.Shared == nil) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if data.Item == nil {
+			// lock unmapped url, creates an empty resource
+			if target.parentItem == nil || !target.parentItem.IsDir() {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			err = h.checkUploadFilePath(target.name)
+			if err == nil {
+				err = h.checkUploadSize(target.parentFsPath, 0)
+			}
+			if h.logError(err) {
+				uploadErr := err.(*uploadError)
+				http.Error(w, uploadErr.message, uploadErr.status)
+				return
+			}
+			h.logUpload(data.AuthUserName, target.name, target.fsPath, r)
+			file, err := os.OpenFile(target.fsPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+			if h.logError(err) {
+				w.WriteHeader(getStatusByErr(err))
+				return
+			}
+			file.Close()
+			status = http.StatusCreated
+		}
+
+		deep := getDavDepth(r) != 0
+		lock, ok = h.davLocks.create(target.fsPath, deep, info.Shared != nil, info.Owner.InnerXML, timeout)
+		if !ok {
+			w.WriteHeader(http.StatusLocked)
+			return
+		}
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	header.Set("Lock-Token", "<"+lock.token+">")
+	w.WriteHeader(status)
+
+	buf := []byte(xml.Header)
+	buf = append(buf, `<D:prop xmlns:D="DAV:"><D:lockdiscovery>`...)
+	buf = appendDavActiveLock(buf, lock, getUrlPrefix(data.prefixReqPath, data.rawReqPath)+target.rawReqPath)
+	buf = append(buf, `</D:lockdiscovery></D:prop>`...)
+	w.Write(buf)
+}
+
+func (h *aliasHandler) davUnlock(w http.ResponseWriter, r *http.Request, data *responseData) {
+	target, ok := h.getDavTarget(data.rawReqPath)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	token := strings.TrimSpace(r.Header.Get("Lock-Token"))
+	token = strings.TrimPrefix(token, "<")
+	token = strings.TrimSuffix(token, ">")
+	if len(token) == 0 || !h.davLocks.remove(target.fsPath, token) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+package serverHandler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"mjpclab.dev/ghfs/src/util"
+	"strings"
+	"sync"
+	"time"
+)
+
+const davDefaultLockTimeout = time.Hour
+const davMaxLockTimeout = 24 * time.Hour
+
+type davLock struct {
+	token   string
+	fsPath  string
+	deep    bool
+	shared  bool
+	owner   string
+	timeout time.Duration
+	expires time.Time
+}
+
+type davLocks struct {
+	mu    sync.Mutex
+	locks map[string]*davLock
+}
+
+func newDavLocks() *davLocks {
+	return &davLocks{locks: map[string]*davLock{}}
+}
+
+func newDavLockToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return "opaquelocktoken:" + hex.EncodeToString(buf)
+}
+
+func isDavLockOverlap(aPath string, aDeep bool, bPath string, bDeep bool) bool {
+	if util.IsPathEqual(aPath, bPath) {
+		return true
+	}
+	if aDeep && util.HasFsPrefixDir(bPath, aPath) {
+		return true
+	}
+	if bDeep && util.HasFsPrefixDir(aPath, bPath) {
+		return true
+	}
+	return false
+}
+
+func (locks *davLocks) removeExpired(now time.Time) {
+	for token, lock := range locks.locks {
+		if now.After(lock.expires) {
+			delete(locks.locks, token)
+		}
+	}
+}
+
+func (locks *davLocks) create(fsPath string, deep, shared bool, owner string, timeout time.Duration) (lock *davLock, ok bool) {
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+
+	now := time.Now()
+	locks.removeExpired(now)
+
+	for _, existing := range locks.locks {
+		if (!shared || !existing.shared) && isDavLockOverlap(existing.fsPath, existing.deep, fsPath, deep) {
+			return nil, false
+		}
+	}
+
+	lock = &davLock{
+		token:   newDavLockToken(),
+		fsPath:  fsPath,
+		deep:    deep,
+		shared:  shared,
+		owner:   owner,
+		timeout: timeout,
+		expires: now.Add(timeout),
+	}
+	locks.locks[lock.token] = lock
+	return lock, true
+}
+
+func (locks *davLocks) refresh(fsPath, ifHeader string, timeout time.Duration) (lock *davLock, ok bool) {
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+
+	now := time.Now()
+	locks.removeExpired(now)
+
+	for token, existing := range locks.locks {
+		if !util.IsPathEqual(existing.fsPath, fsPath) || !strings.Contains(ifHeader, "<"+token+">") {
+			continue
+		}
+		existing.timeout = timeout
+		existing.expires = now.Add(timeout)
+		copied := *existing
+		return &copied, true
+	}
+
+	return nil, false
+}
+
+func (locks *davLocks) remove(fsPath, token string) bool {
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+
+	lock, ok := locks.locks[token]
+	if !ok || !isDavLockOverlap(lock.fsPath, lock.deep, fsPath, false) {
+		return false
+	}
+	delete(locks.locks, token)
+	return true
+}
+
+func (locks *davLocks) removeUnder(fsPath string) {
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+
+	for token, lock := range locks.locks {
+		if util.HasFsPrefixDir(lock.fsPath, fsPath) {
+			delete(locks.locks, token)
+		}
+	}
+}
+
+// isLocked reports whether fsPath(and its descendants if deep) is locked
+// by a lock whose token is not submitted by the "If" request header.
+func (locks *davLocks) isLocked(fsPath string, deep bool, ifHeader string) bool {
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+
+	locks.removeExpired(time.Now())
+
+	for token, lock := range locks.locks {
+		if !isDavLockOverlap(lock.fsPath, lock.deep, fsPath, deep) {
+			continue
+		}
+		if !strings.Contains(ifHeader, "<"+token+">") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,86 @@
+package serverHandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsDavLockOverlap(t *testing.T) {
+	if !isDavLockOverlap("/a/b", false, "/a/b", false) {
+		t.Error()
+	}
+	if isDavLockOverlap("/a", false, "/a/b", false) {
+		t.Error()
+	}
+	if !isDavLockOverlap("/a", true, "/a/b", false) {
+		t.Error()
+	}
+	if !isDavLockOverlap("/a/b", false, "/a", true) {
+		t.Error()
+	}
+	if isDavLockOverlap("/a/b", true, "/a/c", true) {
+		t.Error()
+	}
+}
+
+func TestDavLocks(t *testing.T) {
+	locks := newDavLocks()
+
+	lock, ok := locks.create("/a", true, false, "", time.Minute)
+	if !ok {
+		t.Fatal()
+	}
+	if _, ok := locks.create("/a/b", false, true, "", time.Minute); ok {
+		t.Error("exclusive lock should conflict")
+	}
+
+	if !locks.isLocked("/a/b", false, "") {
+		t.Error()
+	}
+	if locks.isLocked("/a/b", false, "(<"+lock.token+">)") {
+		t.Error()
+	}
+	if locks.isLocked("/b", true, "") {
+		t.Error()
+	}
+
+	if _, ok := locks.refresh("/a", "(<"+lock.token+">)", time.Hour); !ok {
+		t.Error()
+	}
+	if !locks.remove("/a/b", lock.token) {
+		t.Error()
+	}
+	if locks.isLocked("/a", true, "") {
+		t.Error()
+	}
+
+	shared1, ok1 := locks.create("/s", false, true, "", time.Minute)
+	_, ok2 := locks.create("/s", false, true, "", time.Minute)
+	if !ok1 || !ok2 {
+		t.Error("shared locks should coexist")
+	}
+	locks.removeUnder("/")
+	if locks.isLocked("/s", false, "") || locks.remove("/s", shared1.token) {
+		t.Error()
+	}
+
+	locks.create("/e", false, false, "", -time.Second)
+	if locks.isLocked("/e", false, "") {
+		t.Error("expired lock should be ignored")
+	}
+}
+
+func TestGetUrlPrefix(t *testing.T) {
+	if prefix := getUrlPrefix("/a/b", "/a/b"); prefix != "" {
+		t.Error(prefix)
+	}
+	if prefix := getUrlPrefix("/p/a/b", "/a/b"); prefix != "/p" {
+		t.Error(prefix)
+	}
+	if prefix := getUrlPrefix("/p/", "/"); prefix != "/p" {
+		t.Error(prefix)
+	}
+	if prefix := getUrlPrefix("/p", "/"); prefix != "/p" {
+		t.Error(prefix)
+	}
+}
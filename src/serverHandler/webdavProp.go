@@ -0,0 +1,136 @@
+package serverHandler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+func appendDavEscaped(buf []byte, str string) []byte {
+	w := bytes.NewBuffer(buf)
+	xml.EscapeText(w, []byte(str))
+	return w.Bytes()
+}
+
+func getDavHref(urlPath string, isDir bool) string {
+	href := (&url.URL{Path: urlPath}).EscapedPath()
+	if isDir && (len(href) == 0 || href[len(href)-1] != '/') {
+		href += "/"
+	}
+	return href
+}
+
+func appendDavResponse(buf []byte, href string, info os.FileInfo) []byte {
+	buf = append(buf, `<D:response><D:href>`...)
+	buf = appendDavEscaped(buf, href)
+	buf = append(buf, `</D:href><D:propstat><D:prop>`...)
+
+	buf = append(buf, `<D:displayname>`...)
+	buf = appendDavEscaped(buf, info.Name())
+	buf = append(buf, `</D:displayname>`...)
+
+	buf = append(buf, `<D:getlastmodified>`...)
+	buf = append(buf, info.ModTime().UTC().Format(http.TimeFormat)...)
+	buf = append(buf, `</D:getlastmodified>`...)
+
+	if info.IsDir() {
+		buf = append(buf, `<D:resourcetype><D:collection/></D:resourcetype>`...)
+	} else {
+		contentType := mime.TypeByExtension(path.Ext(info.Name()))
+		if len(contentType) == 0 {
+			contentType = "application/octet-stream"
+		}
+
+		buf = append(buf, `<D:resourcetype/><D:getcontentlength>`...)
+		buf = strconv.AppendInt(buf, info.Size(), 10)
+		buf = append(buf, `</D:getcontentlength><D:getcontenttype>`...)
+		buf = appendDavEscaped(buf, contentType)
+		buf = append(buf, `</D:getcontenttype><D:getetag>`...)
+		buf = appendDavEscaped(buf, getETag(info))
+		buf = append(buf, `</D:getetag>`...)
+	}
+
+	buf = append(buf, `<D:supportedlock>`...)
+	buf = append(buf, `<D:lockentry><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockentry>`...)
+	buf = append(buf, `<D:lockentry><D:lockscope><D:shared/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockentry>`...)
+	buf = append(buf, `</D:supportedlock>`...)
+
+	buf = append(buf, `</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`...)
+	return buf
+}
+
+func appendDavActiveLock(buf []byte, lock *davLock, rawReqPath string) []byte {
+	buf = append(buf, `<D:activelock><D:locktype><D:write/></D:locktype><D:lockscope>`...)
+	if lock.shared {
+		buf = append(buf, `<D:shared/>`...)
+	} else {
+		buf = append(buf, `<D:exclusive/>`...)
+	}
+	buf = append(buf, `</D:lockscope><D:depth>`...)
+	if lock.deep {
+		buf = append(buf, `infinity`...)
+	} else {
+		buf = append(buf, `0`...)
+	}
+	buf = append(buf, `</D:depth>`...)
+	if len(lock.owner) > 0 {
+		buf = append(buf, `<D:owner>`...)
+		buf = append(buf, lock.owner...)
+		buf = append(buf, `</D:owner>`...)
+	}
+	buf = append(buf, `<D:timeout>Second-`...)
+	buf = strconv.AppendInt(buf, int64(lock.timeout/time.Second), 10)
+	buf = append(buf, `</D:timeout><D:locktoken><D:href>`...)
+	buf = appendDavEscaped(buf, lock.token)
+	buf = append(buf, `</D:href></D:locktoken><D:lockroot><D:href>`...)
+	buf = appendDavEscaped(buf, getDavHref(rawReqPath, false))
+	buf = append(buf, `</D:href></D:lockroot></D:activelock>`...)
+	return buf
+}
+
+func (h *aliasHandler) davPropfind(w http.ResponseWriter, r *http.Request, data *responseData) {
+	depth := getDavDepth(r)
+	if depth < 0 {
+		header := w.Header()
+		header.Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(xml.Header + `<D:error xmlns:D="DAV:"><D:propfind-finite-depth/></D:error>`))
+		return
+	}
+
+	item := data.Item
+	if item == nil && len(data.SubItems) > 0 {
+		// virtual intermediate directory of alias
+		item = createPlaceholderFileInfo(path.Base(data.rawReqPath), true)
+	}
+	if item == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	prefix := getUrlPrefix(data.prefixReqPath, data.rawReqPath)
+	selfPath := prefix + data.rawReqPath
+
+	buf := []byte(xml.Header)
+	buf = append(buf, `<D:multistatus xmlns:D="DAV:">`...)
+	buf = appendDavResponse(buf, getDavHref(selfPath, item.IsDir()), item)
+	if depth > 0 && item.IsDir() {
+		for _, subItem := range data.SubItems {
+			subHref := getDavHref(path.Join(selfPath, subItem.Name()), subItem.IsDir())
+			buf = appendDavResponse(buf, subHref, subItem)
+		}
+	}
+	buf = append(buf, `</D:multistatus>`...)
+
+	header := w.Header()
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(buf)))
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write(buf)
+}
@@ -955,6 +955,9 @@
 						relativePath = file.name;
 					}
 
+					if (file.lastModified) {
+						parts.append('mtime', file.lastModified);
+					}
 					parts.append(formName, file, relativePath);
 				});
 
@@ -0,0 +1,281 @@
+package user
+
+import (
+	"crypto/md5"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+const bcryptAlphabet = "./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+var bcryptEncoding = base64.NewEncoding(bcryptAlphabet).WithPadding(base64.NoPadding)
+
+var errUnrecognizedHash = errors.New("unrecognized hash")
+
+// cryptEncode24 encodes 3 bytes into n characters, least significant bits first,
+// as crypt(3) does.
+func cryptEncode24(buf *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint32(b2)<<16 | uint32(b1)<<8 | uint32(b0)
+	for ; n > 0; n-- {
+		buf.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// bcrypt
+
+const bcryptMinCost = 4
+const bcryptMaxCost = 31
+const bcryptSaltLen = 22
+const bcryptMaxKeyLen = 72
+
+var bcryptMagicCipherData = []byte("OrpheanBeholderScryDoubt")
+
+// bcryptCrypt hashes password with version, cost and salt from hash,
+// e.g. "$2y$10$<salt><hash>".
+func bcryptCrypt(password, hash string) (string, error) {
+	if len(hash) < 7+bcryptSaltLen || hash[0] != '$' || hash[1] != '2' || hash[3] != '$' || hash[6] != '$' {
+		return "", errUnrecognizedHash
+	}
+	switch hash[2] {
+	case 'a', 'b', 'y':
+	default:
+		return "", errUnrecognizedHash
+	}
+	cost, err := strconv.Atoi(hash[4:6])
+	if err != nil || cost < bcryptMinCost || cost > bcryptMaxCost {
+		return "", errUnrecognizedHash
+	}
+	encodedSalt := hash[7 : 7+bcryptSaltLen]
+	salt, err := bcryptEncoding.DecodeString(encodedSalt)
+	if err != nil {
+		return "", errUnrecognizedHash
+	}
+
+	key := make([]byte, len(password)+1)
+	copy(key, password)
+	if len(key) > bcryptMaxKeyLen {
+		key = key[:bcryptMaxKeyLen]
+	}
+
+	c := newBlowfish()
+	c.expandKey(key, salt)
+	for i, rounds := uint64(0), uint64(1)<<cost; i < rounds; i++ {
+		c.expandKey(key, nil)
+		c.expandKey(salt, nil)
+	}
+
+	cipherData := make([]byte, len(bcryptMagicCipherData))
+	copy(cipherData, bcryptMagicCipherData)
+	for i := 0; i < 64; i++ {
+		for j := 0; j < len(cipherData); j += 8 {
+			c.encryptBlock(cipherData[j:j+8], cipherData[j:j+8])
+		}
+	}
+
+	return hash[:7] + encodedSalt + bcryptEncoding.EncodeToString(cipherData[:23]), nil
+}
+
+// apr1, Apache variant of md5 crypt
+
+const apr1Magic = "$apr1$"
+const md5CryptMaxSaltLen = 8
+
+// apr1Crypt hashes password with salt from hash, e.g. "$apr1$<salt>$<hash>".
+func apr1Crypt(password, hash string) (string, error) {
+	if !strings.HasPrefix(hash, apr1Magic) {
+		return "", errUnrecognizedHash
+	}
+	salt := hash[len(apr1Magic):]
+	if dollarIndex := strings.IndexByte(salt, '$'); dollarIndex >= 0 {
+		salt = salt[:dollarIndex]
+	}
+	if len(salt) > md5CryptMaxSaltLen {
+		salt = salt[:md5CryptMaxSaltLen]
+	}
+
+	alt := md5.Sum([]byte(password + salt + password))
+
+	ctx := md5.New()
+	ctx.Write([]byte(password + apr1Magic + salt))
+	for n := len(password); n > 0; n -= md5.Size {
+		if n > md5.Size {
+			ctx.Write(alt[:])
+		} else {
+			ctx.Write(alt[:n])
+		}
+	}
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write([]byte{password[0]})
+		}
+	}
+	final := ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		ctx.Reset()
+		if i&1 != 0 {
+			ctx.Write([]byte(password))
+		} else {
+			ctx.Write(final)
+		}
+		if i%3 != 0 {
+			ctx.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			ctx.Write([]byte(password))
+		}
+		if i&1 != 0 {
+			ctx.Write(final)
+		} else {
+			ctx.Write([]byte(password))
+		}
+		final = ctx.Sum(final[:0])
+	}
+
+	buf := &strings.Builder{}
+	buf.WriteString(apr1Magic)
+	buf.WriteString(salt)
+	buf.WriteByte('$')
+	cryptEncode24(buf, final[0], final[6], final[12], 4)
+	cryptEncode24(buf, final[1], final[7], final[13], 4)
+	cryptEncode24(buf, final[2], final[8], final[14], 4)
+	cryptEncode24(buf, final[3], final[9], final[15], 4)
+	cryptEncode24(buf, final[4], final[10], final[5], 4)
+	cryptEncode24(buf, 0, 0, final[11], 2)
+	return buf.String(), nil
+}
+
+// sha512 crypt
+
+const sha512CryptMagic = "$6$"
+const sha512CryptRoundsPrefix = "rounds="
+const sha512CryptDefaultRounds = 5000
+const sha512CryptMinRounds = 1000
+const sha512CryptMaxRounds = 999999999
+const sha512CryptMaxSaltLen = 16
+
+// repeatBytes repeats sum to fill length n.
+func repeatBytes(sum []byte, n int) []byte {
+	result := make([]byte, 0, n)
+	for len(result) < n {
+		remain := n - len(result)
+		if remain > len(sum) {
+			remain = len(sum)
+		}
+		result = append(result, sum[:remain]...)
+	}
+	return result
+}
+
+// sha512Crypt hashes password with rounds and salt from hash,
+// e.g. "$6$<salt>$<hash>" or "$6$rounds=<n>$<salt>$<hash>".
+func sha512Crypt(password, hash string) (string, error) {
+	if !strings.HasPrefix(hash, sha512CryptMagic) {
+		return "", errUnrecognizedHash
+	}
+	setting := hash[len(sha512CryptMagic):]
+
+	rounds := sha512CryptDefaultRounds
+	customRounds := false
+	if strings.HasPrefix(setting, sha512CryptRoundsPrefix) {
+		dollarIndex := strings.IndexByte(setting, '$')
+		if dollarIndex < 0 {
+			return "", errUnrecognizedHash
+		}
+		n, err := strconv.Atoi(setting[len(sha512CryptRoundsPrefix):dollarIndex])
+		if err != nil {
+			return "", errUnrecognizedHash
+		}
+		if n < sha512CryptMinRounds {
+			n = sha512CryptMinRounds
+		} else if n > sha512CryptMaxRounds {
+			n = sha512CryptMaxRounds
+		}
+		rounds = n
+		customRounds = true
+		setting = setting[dollarIndex+1:]
+	}
+
+	salt := setting
+	if dollarIndex := strings.IndexByte(salt, '$'); dollarIndex >= 0 {
+		salt = salt[:dollarIndex]
+	}
+	if len(salt) > sha512CryptMaxSaltLen {
+		salt = salt[:sha512CryptMaxSaltLen]
+	}
+
+	pass := []byte(password)
+	saltBytes := []byte(salt)
+
+	altSum := sha512.Sum512([]byte(password + salt + password))
+	alt := altSum[:]
+
+	ctx := sha512.New()
+	ctx.Write(pass)
+	ctx.Write(saltBytes)
+	ctx.Write(repeatBytes(alt, len(pass)))
+	for n := len(pass); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			ctx.Write(alt)
+		} else {
+			ctx.Write(pass)
+		}
+	}
+	a := ctx.Sum(nil)
+
+	ctx.Reset()
+	for i := 0; i < len(pass); i++ {
+		ctx.Write(pass)
+	}
+	p := repeatBytes(ctx.Sum(nil), len(pass))
+
+	ctx.Reset()
+	for i := 0; i < 16+int(a[0]); i++ {
+		ctx.Write(saltBytes)
+	}
+	s := repeatBytes(ctx.Sum(nil), len(saltBytes))
+
+	for i := 0; i < rounds; i++ {
+		ctx.Reset()
+		if i&1 != 0 {
+			ctx.Write(p)
+		} else {
+			ctx.Write(a)
+		}
+		if i%3 != 0 {
+			ctx.Write(s)
+		}
+		if i%7 != 0 {
+			ctx.Write(p)
+		}
+		if i&1 != 0 {
+			ctx.Write(a)
+		} else {
+			ctx.Write(p)
+		}
+		a = ctx.Sum(a[:0])
+	}
+
+	buf := &strings.Builder{}
+	buf.WriteString(sha512CryptMagic)
+	if customRounds {
+		buf.WriteString(sha512CryptRoundsPrefix)
+		buf.WriteString(strconv.Itoa(rounds))
+		buf.WriteByte('$')
+	}
+	buf.WriteString(salt)
+	buf.WriteByte('$')
+	for i := 0; i < 21; i++ {
+		x := i * 22 % 63
+		cryptEncode24(buf, a[x], a[(x+21)%63], a[(x+42)%63], 4)
+	}
+	cryptEncode24(buf, 0, 0, a[63], 2)
+	return buf.String(), nil
+}
@@ -0,0 +1,38 @@
+package user
+
+import (
+	"testing"
+)
+
+func TestCrypt(t *testing.T) {
+	cases := []struct {
+		crypt    func(password, hash string) (string, error)
+		password string
+		hash     string
+	}{
+		{bcryptCrypt, "U*U", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+		{bcryptCrypt, "password", "$2y$10$abcdefghijklmnopqrstuu5Lo0g67CiD3M4RpN1BmBb4Crp5w7dbK"},
+		{apr1Crypt, "password", "$apr1$abcdefgh$FBwExRW4dCc8aL.OvjpIE1"},
+		{apr1Crypt, "password", "$apr1$x$JzZzpGvcyRmaRIUjVzP42/"},
+		{sha512Crypt, "password", "$6$saltstring$adDbXsJjcDlq2662QPgd.tkSOVmnG9Tt3oXl4HR60SusC3AGjirnDenVZp3DGwLwqy6iYKCzannhaX9DR72nN1"},
+		{sha512Crypt, "password", "$6$rounds=10000$saltstringsaltst$Qzq13AQeOS5aa8nj8fFRVn5cZkLp.t1PUmhPeygZiOwD7eFxWmcxrxGtIgnXDKoTYvTEmyGzyqVjaDGGtAurL/"},
+	}
+
+	for _, c := range cases {
+		result, err := c.crypt(c.password, c.hash)
+		if err != nil || result != c.hash {
+			t.Error(c.hash, result, err)
+		}
+		result, err = c.crypt(c.password+"x", c.hash)
+		if err != nil || result == c.hash {
+			t.Error(c.hash, result, err)
+		}
+	}
+
+	if _, err := bcryptCrypt("password", "$2x$10$abcdefghijklmnopqrstuu"); err != errUnrecognizedHash {
+		t.Error(err)
+	}
+	if _, err := sha512Crypt("password", "$5$saltstring$"); err != errUnrecognizedHash {
+		t.Error(err)
+	}
+}
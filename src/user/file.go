@@ -0,0 +1,164 @@
+package user
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"mjpclab.dev/ghfs/src/util"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// interval of checking if file changed on disk
+var fileCheckInterval = time.Second
+
+// watchedFile is a line based file, which can be reloaded when it changes on disk.
+type watchedFile struct {
+	path string
+	// called with errors of reloading file
+	onReloadErrors func(errs []error)
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+func (f *watchedFile) lineError(lineNo int, message string) error {
+	return errors.New(f.path + ":" + strconv.Itoa(lineNo) + ": " + message)
+}
+
+// readLines reads lines of file except empty lines and comments,
+// and records state of file for detecting changes.
+func (f *watchedFile) readLines(onLine func(lineNo int, line string)) error {
+	f.checked = time.Now()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		f.modTime = time.Time{}
+		f.size = 0
+		return err
+	}
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		onLine(lineNo, line)
+	}
+
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return nil
+}
+
+func (f *watchedFile) reloadErrors(errs []error) {
+	if len(errs) > 0 && f.onReloadErrors != nil {
+		f.onReloadErrors(errs)
+	}
+}
+
+// isChanged checks if file changed since last read, at most once per interval.
+func (f *watchedFile) isChanged() bool {
+	if time.Since(f.checked) < fileCheckInterval {
+		return false
+	}
+
+	info, err := os.Stat(f.path)
+	if err != nil || !info.ModTime().Equal(f.modTime) || info.Size() != f.size {
+		return true
+	}
+	f.checked = time.Now()
+	return false
+}
+
+// newHtpasswdUser creates user from an entry of Apache htpasswd file, "name:hash".
+func newHtpasswdUser(name, hash string) (user, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+		return newCryptUser(name, hash, bcryptCrypt), nil
+	case strings.HasPrefix(hash, apr1Magic):
+		return newCryptUser(name, hash, apr1Crypt), nil
+	case strings.HasPrefix(hash, sha512CryptMagic):
+		return newCryptUser(name, hash, sha512Crypt), nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		return newSha1Base64User(name, hash[5:])
+	default:
+		return nil, errors.New("unsupported password hash of user: " + name)
+	}
+}
+
+// userFile is an Apache htpasswd file, reloaded when it changes on disk.
+type userFile struct {
+	watchedFile
+	namesEqualFunc util.StrEqualFunc
+	users          []user
+}
+
+func (f *userFile) findIndex(users []user, username string) int {
+	for i := range users {
+		if f.namesEqualFunc(users[i].getName(), username) {
+			return i
+		}
+	}
+	return -1
+}
+
+// load reads all entries from file, invalid entries are skipped.
+func (f *userFile) load() (errs []error) {
+	var users []user
+	err := f.readLines(func(lineNo int, line string) {
+		colonIndex := strings.IndexByte(line, ':')
+		if colonIndex <= 0 {
+			errs = append(errs, f.lineError(lineNo, "invalid entry"))
+			return
+		}
+		name := line[:colonIndex]
+		if f.findIndex(users, name) >= 0 {
+			errs = append(errs, f.lineError(lineNo, "duplicated username: "+name))
+			return
+		}
+		u, err := newHtpasswdUser(name, line[colonIndex+1:])
+		if err != nil {
+			errs = append(errs, f.lineError(lineNo, err.Error()))
+			return
+		}
+		users = append(users, u)
+	})
+	if err != nil {
+		f.users = nil
+		return []error{err}
+	}
+
+	f.users = users
+	return
+}
+
+// getUsers gets current users, reloads file first if it is changed.
+func (f *userFile) getUsers() []user {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.isChanged() {
+		f.reloadErrors(f.load())
+	}
+
+	return f.users
+}
+
+func (f *userFile) find(username string) user {
+	users := f.getUsers()
+	if index := f.findIndex(users, username); index >= 0 {
+		return users[index]
+	}
+	return nil
+}
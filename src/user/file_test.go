@@ -0,0 +1,81 @@
+package user
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListAddFile(t *testing.T) {
+	fileCheckInterval = 0
+	defer func() {
+		fileCheckInterval = time.Second
+	}()
+
+	filename := filepath.Join(t.TempDir(), "htpasswd")
+	content := `# comment
+bcrypt:$2y$10$abcdefghijklmnopqrstuu5Lo0g67CiD3M4RpN1BmBb4Crp5w7dbK
+apr1:$apr1$abcdefgh$FBwExRW4dCc8aL.OvjpIE1
+
+sha:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=
+sha512:$6$saltstring$adDbXsJjcDlq2662QPgd.tkSOVmnG9Tt3oXl4HR60SusC3AGjirnDenVZp3DGwLwqy6iYKCzannhaX9DR72nN1
+invalid
+unknown:password
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var reloadErrs []error
+	list := NewList(false)
+	list.OnReloadErrors(func(errs []error) {
+		reloadErrs = append(reloadErrs, errs...)
+	})
+	list.AddPlain("plain", "plain")
+	if errs := list.AddFile(filename); len(errs) != 2 {
+		t.Error(errs)
+	}
+	if list.Len() != 5 {
+		t.Error(list.Len())
+	}
+
+	for _, name := range []string{"bcrypt", "APR1", "sha", "sha512"} {
+		if !list.Auth(name, "password") {
+			t.Error(name)
+		}
+		if !list.Auth(name, "password") {
+			t.Error(name, "verified again")
+		}
+		if list.Auth(name, "passwor") {
+			t.Error(name, "wrong password")
+		}
+	}
+	if !list.Auth("plain", "plain") {
+		t.Error("plain")
+	}
+
+	// reload
+	os.WriteFile(filename, []byte("sha:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"), 0644)
+	os.Chtimes(filename, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	if list.Auth("bcrypt", "password") {
+		t.Error("bcrypt should be removed")
+	}
+	if !list.Auth("sha", "password") {
+		t.Error("sha")
+	}
+	if len(reloadErrs) != 0 {
+		t.Error(reloadErrs)
+	}
+
+	os.Remove(filename)
+	if list.Auth("sha", "password") {
+		t.Error("sha should be removed")
+	}
+	if len(reloadErrs) != 1 {
+		t.Error("reload error should be reported", reloadErrs)
+	}
+	if list.Len() != 1 {
+		t.Error(list.Len())
+	}
+}
@@ -7,11 +7,22 @@ import (
 
 type List struct {
 	users          []user
+	files          []*userFile
 	namesEqualFunc util.StrEqualFunc
+	onReloadErrors func(errs []error)
+}
+
+// OnReloadErrors sets callback for errors of reloading files added afterwards.
+func (list *List) OnReloadErrors(handler func(errs []error)) {
+	list.onReloadErrors = handler
 }
 
 func (list *List) Len() int {
-	return len(list.users)
+	count := len(list.users)
+	for _, file := range list.files {
+		count += len(file.getUsers())
+	}
+	return count
 }
 
 func (list *List) FindIndex(username string) int {
@@ -23,6 +34,11 @@ func (list *List) FindIndex(username string) int {
 	return -1
 }
 
+// IsNameEqual compares usernames, case sensitive or not as the list is created.
+func (list *List) IsNameEqual(a, b string) bool {
+	return list.namesEqualFunc(a, b)
+}
+
 func (list *List) addUser(user user) error {
 	username := user.getName()
 	index := list.FindIndex(username)
@@ -86,13 +102,41 @@ func (list *List) AddSha512(username, password string) error {
 	return err
 }
 
+// AddFile loads users from Apache htpasswd file,
+// which supports bcrypt, apr1, sha512 crypt and {SHA} hashes.
+// The file is reloaded automatically when it changes.
+// Users added by other methods take precedence over users in file.
+func (list *List) AddFile(filename string) []error {
+	file := &userFile{watchedFile: watchedFile{path: filename, onReloadErrors: list.onReloadErrors}, namesEqualFunc: list.namesEqualFunc}
+	errs := file.load()
+	list.files = append(list.files, file)
+	return errs
+}
+
+func (list *List) find(username string) user {
+	if index := list.FindIndex(username); index >= 0 {
+		return list.users[index]
+	}
+	for _, file := range list.files {
+		if u := file.find(username); u != nil {
+			return u
+		}
+	}
+	return nil
+}
+
+// Exists checks if user still exists, e.g. when restoring login session.
+func (list *List) Exists(username string) bool {
+	return list.find(username) != nil
+}
+
 func (list *List) Auth(username, password string) bool {
-	index := list.FindIndex(username)
-	if index < 0 {
+	u := list.find(username)
+	if u == nil {
 		return false
 	}
 
-	return list.users[index].auth(password)
+	return u.auth(password)
 }
 
 func NewList(nameCaseSensitive bool) *List {
@@ -0,0 +1,227 @@
+package user
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"mjpclab.dev/ghfs/src/util"
+	"strings"
+	"time"
+)
+
+type TokenPerm uint8
+
+const (
+	TokenPermRead TokenPerm = 1 << iota
+	TokenPermUpload
+	TokenPermDelete
+
+	TokenPermAll = TokenPermRead | TokenPermUpload | TokenPermDelete
+)
+
+// Token is an API token for bearer authentication,
+// which acts as a user named by Name, and can be limited by scopes.
+type Token struct {
+	Name string
+	// url path prefixes token can access, empty for all
+	Paths []string
+	Perms TokenPerm
+	// zero value for never expires
+	Expires time.Time
+
+	digest [sha256.Size]byte
+}
+
+func parseTokenPerms(input string) (perms TokenPerm, err error) {
+	for _, perm := range strings.Split(input, ",") {
+		switch strings.TrimSpace(perm) {
+		case "read":
+			perms |= TokenPermRead
+		case "upload":
+			perms |= TokenPermUpload
+		case "delete":
+			perms |= TokenPermDelete
+		default:
+			return 0, errors.New("unknown token permission: " + perm)
+		}
+	}
+	return
+}
+
+func parseTokenExpires(input string) (time.Time, error) {
+	if expires, err := time.Parse(time.RFC3339, input); err == nil {
+		return expires, nil
+	}
+	return time.ParseInLocation("2006-01-02", input, time.Local)
+}
+
+// newToken creates token from fields of name, token and optional scopes,
+// e.g. "path=/upload", "perm=read,upload", "expires=2030-01-01".
+func newToken(fields []string) (*Token, error) {
+	if len(fields) < 2 || len(fields[0]) == 0 || len(fields[1]) == 0 {
+		return nil, errors.New("token requires name and value")
+	}
+
+	token := &Token{
+		Name:   fields[0],
+		Perms:  TokenPermAll,
+		digest: sha256.Sum256([]byte(fields[1])),
+	}
+	hasPerms := false
+	for _, scope := range fields[2:] {
+		eqIndex := strings.IndexByte(scope, '=')
+		if eqIndex < 0 {
+			return nil, errors.New("invalid scope of token " + token.Name + ": " + scope)
+		}
+		key, value := scope[:eqIndex], scope[eqIndex+1:]
+
+		var err error
+		switch key {
+		case "path":
+			value, err = util.NormalizeUrlPath(value)
+			token.Paths = append(token.Paths, value)
+		case "perm":
+			var perms TokenPerm
+			perms, err = parseTokenPerms(value)
+			if !hasPerms {
+				token.Perms = 0
+				hasPerms = true
+			}
+			token.Perms |= perms
+		case "expires":
+			token.Expires, err = parseTokenExpires(value)
+		default:
+			err = errors.New("unknown scope of token " + token.Name + ": " + key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+func (token *Token) Allows(perm TokenPerm) bool {
+	return token.Perms&perm == perm
+}
+
+func (token *Token) AllowsPath(urlPath string) bool {
+	if len(token.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range token.Paths {
+		if util.HasUrlPrefixDir(urlPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (token *Token) IsExpired(now time.Time) bool {
+	return !token.Expires.IsZero() && !now.Before(token.Expires)
+}
+
+func findToken(tokens []*Token, digest [sha256.Size]byte) (found *Token) {
+	for _, token := range tokens {
+		// compare all tokens to avoid timing difference
+		if subtle.ConstantTimeCompare(token.digest[:], digest[:]) == 1 && found == nil {
+			found = token
+		}
+	}
+	return
+}
+
+// tokenFile is a file of tokens, reloaded when it changes on disk.
+// Each line contains whitespace separated name, token and optional scopes.
+type tokenFile struct {
+	watchedFile
+	tokens []*Token
+}
+
+func (f *tokenFile) load() (errs []error) {
+	var tokens []*Token
+	err := f.readLines(func(lineNo int, line string) {
+		token, err := newToken(strings.Fields(line))
+		if err != nil {
+			errs = append(errs, f.lineError(lineNo, err.Error()))
+			return
+		}
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		f.tokens = nil
+		return []error{err}
+	}
+
+	f.tokens = tokens
+	return
+}
+
+func (f *tokenFile) getTokens() []*Token {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.isChanged() {
+		f.reloadErrors(f.load())
+	}
+
+	return f.tokens
+}
+
+type TokenList struct {
+	tokens         []*Token
+	files          []*tokenFile
+	onReloadErrors func(errs []error)
+}
+
+// OnReloadErrors sets callback for errors of reloading files added afterwards.
+func (list *TokenList) OnReloadErrors(handler func(errs []error)) {
+	list.onReloadErrors = handler
+}
+
+func (list *TokenList) Len() int {
+	count := len(list.tokens)
+	for _, file := range list.files {
+		count += len(file.getTokens())
+	}
+	return count
+}
+
+// Add adds token from fields of name, token and optional scopes.
+func (list *TokenList) Add(fields []string) error {
+	token, err := newToken(fields)
+	if err != nil {
+		return err
+	}
+	list.tokens = append(list.tokens, token)
+	return nil
+}
+
+// AddFile loads tokens from file, which is reloaded automatically when it changes.
+func (list *TokenList) AddFile(filename string) []error {
+	file := &tokenFile{watchedFile: watchedFile{path: filename, onReloadErrors: list.onReloadErrors}}
+	errs := file.load()
+	list.files = append(list.files, file)
+	return errs
+}
+
+// Auth finds token by its value, expired token is ignored.
+func (list *TokenList) Auth(value string) *Token {
+	digest := sha256.Sum256([]byte(value))
+	token := findToken(list.tokens, digest)
+	for _, file := range list.files {
+		if token != nil {
+			break
+		}
+		token = findToken(file.getTokens(), digest)
+	}
+
+	if token == nil || token.IsExpired(time.Now()) {
+		return nil
+	}
+	return token
+}
+
+func NewTokenList() *TokenList {
+	return &TokenList{}
+}
@@ -0,0 +1,99 @@
+package user
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewToken(t *testing.T) {
+	token, err := newToken([]string{"ci", "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Perms != TokenPermAll || len(token.Paths) != 0 || !token.Expires.IsZero() {
+		t.Error(token)
+	}
+	if !token.AllowsPath("/any/path") {
+		t.Error("path")
+	}
+
+	token, err = newToken([]string{"ci", "secret", "path=/upload/", "perm=read,upload", "expires=2000-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.Allows(TokenPermRead) || !token.Allows(TokenPermUpload) || token.Allows(TokenPermDelete) {
+		t.Error(token.Perms)
+	}
+	if !token.AllowsPath("/upload") || !token.AllowsPath("/upload/sub") || token.AllowsPath("/uploads") || token.AllowsPath("/") {
+		t.Error(token.Paths)
+	}
+	if !token.IsExpired(time.Now()) {
+		t.Error(token.Expires)
+	}
+
+	for _, fields := range [][]string{
+		{"ci"},
+		{"", "secret"},
+		{"ci", "secret", "path"},
+		{"ci", "secret", "perm=write"},
+		{"ci", "secret", "expires=tomorrow"},
+		{"ci", "secret", "unknown=1"},
+	} {
+		if _, err := newToken(fields); err == nil {
+			t.Error(fields)
+		}
+	}
+}
+
+func TestTokenList(t *testing.T) {
+	fileCheckInterval = 0
+	defer func() {
+		fileCheckInterval = time.Second
+	}()
+
+	filename := filepath.Join(t.TempDir(), "tokens")
+	content := `# comment
+file1 secret1 perm=read
+file2 secret2 expires=2000-01-01T00:00:00Z
+invalid
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	list := NewTokenList()
+	if err := list.Add([]string{"config", "secret0"}); err != nil {
+		t.Error(err)
+	}
+	if errs := list.AddFile(filename); len(errs) != 1 {
+		t.Error(errs)
+	}
+	if list.Len() != 3 {
+		t.Error(list.Len())
+	}
+
+	if token := list.Auth("secret0"); token == nil || token.Name != "config" {
+		t.Error(token)
+	}
+	if token := list.Auth("secret1"); token == nil || token.Name != "file1" || token.Allows(TokenPermUpload) {
+		t.Error(token)
+	}
+	if token := list.Auth("secret2"); token != nil {
+		t.Error("expired token", token)
+	}
+	if token := list.Auth("secret"); token != nil {
+		t.Error("unknown token", token)
+	}
+
+	// reload
+	os.WriteFile(filename, []byte("file3 secret3\n"), 0644)
+	os.Chtimes(filename, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	if token := list.Auth("secret1"); token != nil {
+		t.Error("file1 should be removed")
+	}
+	if token := list.Auth("secret3"); token == nil || token.Name != "file3" {
+		t.Error(token)
+	}
+}
@@ -1,13 +1,17 @@
 package user
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"sync"
 )
 
 type user interface {
@@ -31,7 +35,7 @@ type plainUser struct {
 }
 
 func (u *plainUser) auth(input string) bool {
-	return u.token == input
+	return subtle.ConstantTimeCompare([]byte(u.token), []byte(input)) == 1
 }
 
 func newPlainUser(name, pass string) *plainUser {
@@ -46,7 +50,7 @@ type base64User struct {
 
 func (u *base64User) auth(input string) bool {
 	inputToken := base64.StdEncoding.EncodeToString([]byte(input))
-	return u.token == inputToken
+	return subtle.ConstantTimeCompare([]byte(u.token), []byte(inputToken)) == 1
 }
 
 func newBase64User(name, encPass string) *base64User {
@@ -61,7 +65,7 @@ type md5User struct {
 
 func (u *md5User) auth(input string) bool {
 	inputToken := md5.Sum([]byte(input))
-	return u.token == inputToken
+	return subtle.ConstantTimeCompare(u.token[:], inputToken[:]) == 1
 }
 
 func newMd5User(name, encPass string) (*md5User, error) {
@@ -85,7 +89,7 @@ type sha1User struct {
 
 func (u *sha1User) auth(input string) bool {
 	inputToken := sha1.Sum([]byte(input))
-	return u.token == inputToken
+	return subtle.ConstantTimeCompare(u.token[:], inputToken[:]) == 1
 }
 
 func newSha1User(name, encPass string) (*sha1User, error) {
@@ -101,6 +105,20 @@ func newSha1User(name, encPass string) (*sha1User, error) {
 	return &sha1User{baseUser{name}, token}, nil
 }
 
+// sha1 hashed password in base64, e.g. "{SHA}" entry of htpasswd
+func newSha1Base64User(name, encPass string) (*sha1User, error) {
+	tokenSlice, err := base64.StdEncoding.DecodeString(encPass)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokenSlice) != sha1.Size {
+		return nil, errors.New("unrecognized hash")
+	}
+	token := [sha1.Size]byte{}
+	copy(token[:], tokenSlice)
+	return &sha1User{baseUser{name}, token}, nil
+}
+
 // sha256 hashed password
 type sha256User struct {
 	baseUser
@@ -109,7 +127,7 @@ type sha256User struct {
 
 func (u *sha256User) auth(input string) bool {
 	inputToken := sha256.Sum256([]byte(input))
-	return u.token == inputToken
+	return subtle.ConstantTimeCompare(u.token[:], inputToken[:]) == 1
 }
 
 func newSha256User(name, encPass string) (*sha256User, error) {
@@ -133,7 +151,7 @@ type sha512User struct {
 
 func (u *sha512User) auth(input string) bool {
 	inputToken := sha512.Sum512([]byte(input))
-	return u.token == inputToken
+	return subtle.ConstantTimeCompare(u.token[:], inputToken[:]) == 1
 }
 
 func newSha512User(name, encPass string) (*sha512User, error) {
@@ -148,3 +166,54 @@ func newSha512User(name, encPass string) (*sha512User, error) {
 	copy(token[:], tokenSlice)
 	return &sha512User{baseUser{name}, token}, nil
 }
+
+// verifiedKey is the per-process random key for hashing last verified password in memory,
+// so that plain hash cannot be extracted from memory and brute forced cheaply.
+var verifiedKey = newVerifiedKey()
+
+func newVerifiedKey() []byte {
+	key := make([]byte, sha256.Size)
+	rand.Read(key)
+	return key
+}
+
+// crypt(3) style salted hashed password, e.g. bcrypt, apr1 or sha512 crypt
+type cryptUser struct {
+	baseUser
+	hash  string
+	crypt func(password, hash string) (string, error)
+
+	// slow hash is not computed again for last verified password
+	mu          sync.Mutex
+	verified    [sha256.Size]byte
+	hasVerified bool
+}
+
+func (u *cryptUser) auth(input string) bool {
+	mac := hmac.New(sha256.New, verifiedKey)
+	mac.Write([]byte(input))
+	var inputToken [sha256.Size]byte
+	mac.Sum(inputToken[:0])
+
+	u.mu.Lock()
+	verified, hasVerified := u.verified, u.hasVerified
+	u.mu.Unlock()
+	if hasVerified && subtle.ConstantTimeCompare(verified[:], inputToken[:]) == 1 {
+		return true
+	}
+
+	result, err := u.crypt(input, u.hash)
+	if err != nil || subtle.ConstantTimeCompare([]byte(result), []byte(u.hash)) != 1 {
+		return false
+	}
+
+	u.mu.Lock()
+	u.verified = inputToken
+	u.hasVerified = true
+	u.mu.Unlock()
+	return true
+}
+
+func newCryptUser(name, hash string, crypt func(password, hash string) (string, error)) *cryptUser {
+	return &cryptUser{baseUser: baseUser{name}, hash: hash, crypt: crypt}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+// +build !linux,!darwin,!freebsd,!dragonfly,!windows
+
+package util
+
+// GetDiskFree is not supported on current platform.
+func GetDiskFree(fsPath string) (free uint64, ok bool) {
+	return 0, false
+}
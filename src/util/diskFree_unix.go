@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd || dragonfly
+// +build linux darwin freebsd dragonfly
+
+package util
+
+import "syscall"
+
+// GetDiskFree gets available space in bytes for unprivileged user
+// of file system that contains fsPath.
+func GetDiskFree(fsPath string) (free uint64, ok bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(fsPath, &stat); err != nil {
+		return 0, false
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), true
+}
@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package util
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// GetDiskFree gets available space in bytes for current user
+// of file system that contains fsPath.
+func GetDiskFree(fsPath string) (free uint64, ok bool) {
+	pathPtr, err := syscall.UTF16PtrFromString(fsPath)
+	if err != nil {
+		return 0, false
+	}
+	r, _, _ := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(&free)), 0, 0)
+	return free, r != 0
+}
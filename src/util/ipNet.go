@@ -0,0 +1,45 @@
+package util
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// ParseIPNets parses IP addresses or CIDRs, a single IP address is treated as a network of itself.
+func ParseIPNets(inputs []string) (ipNets []*net.IPNet, errs []error) {
+	for _, input := range inputs {
+		if strings.IndexByte(input, '/') >= 0 {
+			_, ipNet, err := net.ParseCIDR(input)
+			if err != nil {
+				errs = append(errs, errors.New("invalid CIDR: "+input))
+				continue
+			}
+			ipNets = append(ipNets, ipNet)
+			continue
+		}
+
+		ip := net.ParseIP(input)
+		if ip == nil {
+			errs = append(errs, errors.New("invalid IP address: "+input))
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		ipNets = append(ipNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+	}
+	return
+}
+
+func IPNetsContain(ipNets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
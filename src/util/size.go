@@ -0,0 +1,38 @@
+package util
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ParseSize parses human readable size like "512", "100K", "1.5G", "2GiB".
+// Units are based on 1024.
+func ParseSize(input string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(input))
+	str = strings.TrimSuffix(str, "B")
+	str = strings.TrimSuffix(str, "I")
+
+	unit := int64(1)
+	if len(str) > 0 {
+		switch str[len(str)-1] {
+		case 'K':
+			unit = 1 << 10
+		case 'M':
+			unit = 1 << 20
+		case 'G':
+			unit = 1 << 30
+		case 'T':
+			unit = 1 << 40
+		}
+		if unit > 1 {
+			str = str[:len(str)-1]
+		}
+	}
+
+	num, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || num < 0 {
+		return 0, errors.New("invalid size: " + input)
+	}
+	return int64(num * float64(unit)), nil
+}
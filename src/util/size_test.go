@@ -0,0 +1,28 @@
+package util
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	expects := map[string]int64{
+		"0":      0,
+		"512":    512,
+		"100k":   100 * 1024,
+		"1.5M":   3 * 512 * 1024,
+		"2G":     2 << 30,
+		"2GiB":   2 << 30,
+		"1 TB":   1 << 40,
+		" 10KB ": 10 * 1024,
+	}
+	for input, expect := range expects {
+		actual, err := ParseSize(input)
+		if err != nil || actual != expect {
+			t.Error(input, actual, err)
+		}
+	}
+
+	for _, input := range []string{"", "K", "-1", "1X", "abc"} {
+		if _, err := ParseSize(input); err == nil {
+			t.Error(input)
+		}
+	}
+}
@@ -25,7 +25,7 @@ assert "$uploaded" "$content"
 
 # dir file upload to /1 - INVALID
 content='upload/1/dir/file'
-curl_upload_content 'http://127.0.0.1:3003/1?upload' dirfile "$content" dir/uploaded.tmp
+curl_upload_content 'http://127.0.0.1:3003/1?upload' dirfile "$content" dir/uploaded.tmp > /dev/null
 [ ! -e "$fs"/uploaded/1/dir/uploaded.tmp ] || fail "/uploaded/1/dir/uploaded.tmp should not be exists"
 
 # dir file upload to /2 - valid
@@ -45,13 +45,13 @@ assert "$uploaded" "$content"
 
 # inner dir file upload to /1 - valid
 content='upload/1/idir/file'
-curl_upload_content 'http://127.0.0.1:3003/1?upload' innerdirfile "$content" idir/iuploaded.tmp
+curl_upload_content 'http://127.0.0.1:3003/1?upload' innerdirfile "$content" idir/iuploaded.tmp > /dev/null
 uploaded=$(cat "$fs"/uploaded/1/iuploaded.tmp)
 assert "$uploaded" "$content"
 
 # inner sub dir file upload to /1 - INVALID
 content='upload/1/idir/isub/file'
-curl_upload_content 'http://127.0.0.1:3003/1?upload' innerdirfile "$content" idir/isub/iuploaded.tmp
+curl_upload_content 'http://127.0.0.1:3003/1?upload' innerdirfile "$content" idir/isub/iuploaded.tmp > /dev/null
 [ ! -e "$fs"/uploaded/2/isub/iuploaded.tmp ] || fail "/uploaded/2/isub/iuploaded.tmp should not be exists"
 
 # inner dir file upload to /2 - valid
@@ -27,10 +27,10 @@ curl_upload_content 'http://127.0.0.1:3003/?upload' file mycontent my
 curl_upload_content 'http://127.0.0.1:3003/?upload' file mycontent 'dir/to/my'
 [ -e "$fs"/vhost1/my ] && fail "$fs/vhost1/my should not exists"
 
-curl_upload_content 'http://127.0.0.1:3003/?upload' dirfile mycontent 'my/myfile'
+curl_upload_content 'http://127.0.0.1:3003/?upload' dirfile mycontent 'my/myfile' > /dev/null
 [ -e "$fs"/vhost1/my ] && fail "$fs/vhost1/my should not exists"
 
-curl_upload_content 'http://127.0.0.1:3003/?upload' dirfile mycontent 'my/mydir/file'
+curl_upload_content 'http://127.0.0.1:3003/?upload' dirfile mycontent 'my/mydir/file' > /dev/null
 [ -e "$fs"/vhost1/my ] && fail "$fs/vhost1/my should not exists"
 
 cleanup
@@ -0,0 +1,85 @@
+#!/bin/bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/[12]/{*.tmp,*/}
+}
+
+source "$root"/lib.bash
+
+dav_status() {
+	curl -s -k -o /dev/null -w '%{http_code}' "$@"
+}
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --webdav --upload /1 --mkdir /1 --delete /1 -a :/1/alias.tmp:"$fs"/uploaded/2 -E '' &
+sleep 0.05 # wait server ready
+cleanup
+
+# options
+dav=$(curl_get_header -X OPTIONS 'http://127.0.0.1:3003/1/' | grep -i '^DAV:')
+[ -n "$dav" ] || fail "DAV header should exists"
+
+# put
+status=$(dav_status -X PUT --data-binary 'webdav/put' 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '201'
+assert "$(cat "$fs"/uploaded/1/put.tmp)" 'webdav/put'
+
+status=$(dav_status -X PUT --data-binary 'webdav/put2' 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '204'
+assert "$(cat "$fs"/uploaded/1/put.tmp)" 'webdav/put2'
+
+status=$(dav_status -X PUT --data-binary 'webdav/put' 'http://127.0.0.1:3003/2/put.tmp')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/2/put.tmp ] || fail "/uploaded/2/put.tmp should not exists"
+
+# propfind
+body=$(curl -s -k -X PROPFIND -H 'Depth: 1' 'http://127.0.0.1:3003/1/')
+(echo "$body" | grep -q '<D:href>/1/put.tmp</D:href>') || fail "propfind should list put.tmp"
+(echo "$body" | grep -q '<D:href>/1/alias.tmp/</D:href>') || fail "propfind should list alias.tmp"
+
+status=$(dav_status -X PROPFIND -H 'Depth: infinity' 'http://127.0.0.1:3003/1/')
+assert "$status" '403'
+
+# mkcol
+status=$(dav_status -X MKCOL 'http://127.0.0.1:3003/1/dir.tmp')
+assert "$status" '201'
+[ -d "$fs"/uploaded/1/dir.tmp ] || fail "/uploaded/1/dir.tmp should be a directory"
+
+status=$(dav_status -X MKCOL 'http://127.0.0.1:3003/1/alias.tmp')
+assert "$status" '403'
+
+# copy & move
+status=$(dav_status -X COPY -H 'Destination: /1/dir.tmp/copied.tmp' 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '201'
+assert "$(cat "$fs"/uploaded/1/dir.tmp/copied.tmp)" 'webdav/put2'
+
+status=$(dav_status -X MOVE -H 'Destination: http://127.0.0.1:3003/1/moved.tmp' 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '201'
+[ ! -e "$fs"/uploaded/1/put.tmp ] || fail "/uploaded/1/put.tmp should not exists"
+assert "$(cat "$fs"/uploaded/1/moved.tmp)" 'webdav/put2'
+
+status=$(dav_status -X MOVE -H 'Destination: /1/alias.tmp/moved.tmp' 'http://127.0.0.1:3003/1/moved.tmp')
+assert "$status" '403'
+
+status=$(dav_status -X COPY -H 'Overwrite: F' -H 'Destination: /1/moved.tmp' 'http://127.0.0.1:3003/1/dir.tmp/copied.tmp')
+assert "$status" '412'
+
+# lock
+locktoken=$(curl -s -k -i -X LOCK --data-binary '<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>' 'http://127.0.0.1:3003/1/moved.tmp' | grep -i '^Lock-Token:' | sed -e 's/^[^<]*//' -e 's/[\r\n]//g')
+[ -n "$locktoken" ] || fail "Lock-Token header should exists"
+
+status=$(dav_status -X DELETE 'http://127.0.0.1:3003/1/moved.tmp')
+assert "$status" '423'
+
+status=$(dav_status -X UNLOCK -H "Lock-Token: $locktoken" 'http://127.0.0.1:3003/1/moved.tmp')
+assert "$status" '204'
+
+# delete
+status=$(dav_status -X DELETE 'http://127.0.0.1:3003/1/moved.tmp')
+assert "$status" '204'
+[ ! -e "$fs"/uploaded/1/moved.tmp ] || fail "/uploaded/1/moved.tmp should not exists"
+
+status=$(dav_status -X DELETE 'http://127.0.0.1:3003/1/alias.tmp')
+assert "$status" '403'
+
+cleanup
+jobs -p | xargs kill &> /dev/null
@@ -0,0 +1,69 @@
+#!/bin/bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/[12]/{*.tmp,*/}
+}
+
+source "$root"/lib.bash
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --delete /1 --upload /2 -a :/1/alias.tmp:"$fs"/uploaded/2 --dropbox /1/box.tmp --auth /1/auth.tmp --user user1:pass1 -E '' &
+sleep 0.05 # wait server ready
+cleanup
+
+# rename
+echo -n 'uploaded/1/1.tmp' > "$fs"/uploaded/1/1.tmp
+curl_post_status -d 'name=1.tmp&to=renamed.tmp' 'http://127.0.0.1:3003/1?move' > /dev/null
+[ ! -e "$fs"/uploaded/1/1.tmp ] || fail "/uploaded/1/1.tmp should not exists"
+assert "$(cat "$fs"/uploaded/1/renamed.tmp)" 'uploaded/1/1.tmp'
+
+# batch, move into sub directory
+mkdir -p "$fs"/uploaded/1/sub.tmp
+echo -n 'uploaded/1/2.tmp' > "$fs"/uploaded/1/2.tmp
+echo -n 'uploaded/1/3.tmp' > "$fs"/uploaded/1/3.tmp
+curl_post_status -d 'name=2.tmp&to=sub.tmp/2.tmp&name=3.tmp&to=sub.tmp/3.tmp' 'http://127.0.0.1:3003/1?move' > /dev/null
+assert "$(cat "$fs"/uploaded/1/sub.tmp/2.tmp)" 'uploaded/1/2.tmp'
+assert "$(cat "$fs"/uploaded/1/sub.tmp/3.tmp)" 'uploaded/1/3.tmp'
+
+# json
+status=$(curl -s -o /dev/null -w '%{http_code}' -H 'Content-Type: application/json' -d '[{"name":"renamed.tmp","to":"json.tmp"}]' 'http://127.0.0.1:3003/1?move&json')
+assert "$status" '200'
+assert "$(cat "$fs"/uploaded/1/json.tmp)" 'uploaded/1/1.tmp'
+
+# illegal name
+status=$(curl_post_status -d 'name=../2/x.tmp&to=x.tmp' 'http://127.0.0.1:3003/1?move&json')
+assert "$status" '500'
+
+# target exists
+status=$(curl_post_status -d 'name=json.tmp&to=sub.tmp' 'http://127.0.0.1:3003/1?move&json')
+assert "$status" '500'
+[ -e "$fs"/uploaded/1/json.tmp ] || fail "/uploaded/1/json.tmp should exists"
+
+# shadowed by alias
+status=$(curl_post_status -d 'name=json.tmp&to=alias.tmp' 'http://127.0.0.1:3003/1?move&json')
+assert "$status" '500'
+[ -e "$fs"/uploaded/1/json.tmp ] || fail "/uploaded/1/json.tmp should exists"
+
+# target in drop box, not revealing existing item
+mkdir -p "$fs"/uploaded/1/box.tmp
+echo -n 'uploaded/1/box.tmp/exist.tmp' > "$fs"/uploaded/1/box.tmp/exist.tmp
+status=$(curl_post_status -d 'name=json.tmp&to=box.tmp/exist.tmp' 'http://127.0.0.1:3003/1?move&json')
+assert "$status" '500'
+status=$(curl_post_status -d 'name=json.tmp&to=box.tmp/new.tmp' 'http://127.0.0.1:3003/1?move&json')
+assert "$status" '500'
+[ -e "$fs"/uploaded/1/json.tmp ] || fail "/uploaded/1/json.tmp should exists"
+[ ! -e "$fs"/uploaded/1/box.tmp/new.tmp ] || fail "/uploaded/1/box.tmp/new.tmp should not exists"
+
+# target not authorized
+mkdir -p "$fs"/uploaded/1/auth.tmp
+status=$(curl_post_status -d 'name=json.tmp&to=auth.tmp/json.tmp' 'http://127.0.0.1:3003/1?move&json')
+assert "$status" '500'
+[ -e "$fs"/uploaded/1/json.tmp ] || fail "/uploaded/1/json.tmp should exists"
+[ ! -e "$fs"/uploaded/1/auth.tmp/json.tmp ] || fail "/uploaded/1/auth.tmp/json.tmp should not exists"
+
+# delete not allowed
+echo -n 'uploaded/2/2.tmp' > "$fs"/uploaded/2/2.tmp
+curl_post_status -d 'name=2.tmp&to=renamed.tmp' 'http://127.0.0.1:3003/2?move' > /dev/null
+[ -e "$fs"/uploaded/2/2.tmp ] || fail "/uploaded/2/2.tmp should exists"
+
+cleanup
+jobs -p | xargs kill &> /dev/null
@@ -0,0 +1,104 @@
+#!/bin/bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/[12]/{*.tmp,*/,.ghfs-tus}
+}
+
+tus_header() {
+	grep -i "^$1:" | cut -d ' ' -f 2 | tr -d '\r'
+}
+
+source "$root"/lib.bash
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --mkdir /1 -E '' &
+sleep 0.05 # wait server ready
+cleanup
+
+# options
+headers=$(curl -s -i -X OPTIONS 'http://127.0.0.1:3003/1/?tus')
+assert "$(echo "$headers" | tus_header Tus-Version)" '1.0.0'
+
+# missing Tus-Resumable
+status=$(curl -s -o /dev/null -w '%{http_code}' -X POST -H 'Upload-Length: 11' 'http://127.0.0.1:3003/1/?tus')
+assert "$status" '412'
+
+# create
+metadata="filename $(echo -n 'tus.tmp' | base64)"
+headers=$(curl -s -i -X POST -H 'Tus-Resumable: 1.0.0' -H 'Upload-Length: 11' -H "Upload-Metadata: $metadata" 'http://127.0.0.1:3003/1/?tus')
+location=$(echo "$headers" | tus_header Location)
+[ -n "$location" ] || fail "missing upload location"
+[ -d "$fs"/uploaded/1/.ghfs-tus ] || fail "staging area should exists"
+
+# staging area hidden from listing
+(curl -s 'http://127.0.0.1:3003/1/?json' | grep -q 'ghfs-tus') && fail "staging area should be hidden"
+
+# patch first chunk
+status=$(curl -s -o /dev/null -w '%{http_code}' -X PATCH -H 'Tus-Resumable: 1.0.0' -H 'Content-Type: application/offset+octet-stream' -H 'Upload-Offset: 0' --data-binary 'hello' "http://127.0.0.1:3003$location")
+assert "$status" '204'
+
+# offset
+headers=$(curl -s -I -H 'Tus-Resumable: 1.0.0' "http://127.0.0.1:3003$location")
+assert "$(echo "$headers" | tus_header Upload-Offset)" '5'
+assert "$(echo "$headers" | tus_header Upload-Length)" '11'
+
+# wrong offset
+status=$(curl -s -o /dev/null -w '%{http_code}' -X PATCH -H 'Tus-Resumable: 1.0.0' -H 'Content-Type: application/offset+octet-stream' -H 'Upload-Offset: 0' --data-binary 'hello' "http://127.0.0.1:3003$location")
+assert "$status" '409'
+
+# patch rest
+status=$(curl -s -o /dev/null -w '%{http_code}' -X PATCH -H 'Tus-Resumable: 1.0.0' -H 'Content-Type: application/offset+octet-stream' -H 'Upload-Offset: 5' --data-binary ' world' "http://127.0.0.1:3003$location")
+assert "$status" '204'
+assert "$(cat "$fs"/uploaded/1/tus.tmp)" 'hello world'
+[ ! -e "$fs"/uploaded/1/.ghfs-tus ] || fail "staging area should be removed"
+
+# same name is renamed
+headers=$(curl -s -i -X POST -H 'Tus-Resumable: 1.0.0' -H 'Upload-Length: 3' -H "Upload-Metadata: $metadata" 'http://127.0.0.1:3003/1/?tus')
+location=$(echo "$headers" | tus_header Location)
+curl -s -o /dev/null -X PATCH -H 'Tus-Resumable: 1.0.0' -H 'Content-Type: application/offset+octet-stream' -H 'Upload-Offset: 0' --data-binary 'foo' "http://127.0.0.1:3003$location"
+assert "$(cat "$fs"/uploaded/1/tus-1.tmp)" 'foo'
+
+# relative path
+metadata="relativePath $(echo -n 'sub.tmp/inner.tmp' | base64)"
+headers=$(curl -s -i -X POST -H 'Tus-Resumable: 1.0.0' -H 'Upload-Length: 3' -H "Upload-Metadata: $metadata" 'http://127.0.0.1:3003/1/?tus')
+location=$(echo "$headers" | tus_header Location)
+curl -s -o /dev/null -X PATCH -H 'Tus-Resumable: 1.0.0' -H 'Content-Type: application/offset+octet-stream' -H 'Upload-Offset: 0' --data-binary 'bar' "http://127.0.0.1:3003$location"
+assert "$(cat "$fs"/uploaded/1/sub.tmp/inner.tmp)" 'bar'
+
+# terminate
+metadata="filename $(echo -n 'terminated.tmp' | base64)"
+headers=$(curl -s -i -X POST -H 'Tus-Resumable: 1.0.0' -H 'Upload-Length: 3' -H "Upload-Metadata: $metadata" 'http://127.0.0.1:3003/1/?tus')
+location=$(echo "$headers" | tus_header Location)
+status=$(curl -s -o /dev/null -w '%{http_code}' -X DELETE -H 'Tus-Resumable: 1.0.0' "http://127.0.0.1:3003$location")
+assert "$status" '204'
+status=$(curl -s -o /dev/null -w '%{http_code}' -I -H 'Tus-Resumable: 1.0.0' "http://127.0.0.1:3003$location")
+assert "$status" '404'
+[ ! -e "$fs"/uploaded/1/terminated.tmp ] || fail "/uploaded/1/terminated.tmp should not exists"
+
+# upload not allowed
+status=$(curl -s -o /dev/null -w '%{http_code}' -X POST -H 'Tus-Resumable: 1.0.0' -H 'Upload-Length: 3' -H "Upload-Metadata: $metadata" 'http://127.0.0.1:3003/2/?tus')
+assert "$status" '403'
+
+jobs -p | xargs kill &> /dev/null
+
+# upload can only be continued by its creator
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --user alice:AlicePass --user bob:BobPass -E '' &
+sleep 0.05 # wait server ready
+
+metadata="filename $(echo -n 'owned.tmp' | base64)"
+headers=$(curl -s -i -u alice:AlicePass -X POST -H 'Tus-Resumable: 1.0.0' -H 'Upload-Length: 3' -H "Upload-Metadata: $metadata" 'http://127.0.0.1:3003/1/?tus')
+location=$(echo "$headers" | tus_header Location)
+[ -n "$location" ] || fail "missing upload location"
+for auth in '-u bob:BobPass' ''; do
+	status=$(curl -s -o /dev/null -w '%{http_code}' $auth -I -H 'Tus-Resumable: 1.0.0' "http://127.0.0.1:3003$location")
+	assert "$status" '404'
+	status=$(curl -s -o /dev/null -w '%{http_code}' $auth -X PATCH -H 'Tus-Resumable: 1.0.0' -H 'Content-Type: application/offset+octet-stream' -H 'Upload-Offset: 0' --data-binary 'bad' "http://127.0.0.1:3003$location")
+	assert "$status" '404'
+	status=$(curl -s -o /dev/null -w '%{http_code}' $auth -X DELETE -H 'Tus-Resumable: 1.0.0' "http://127.0.0.1:3003$location")
+	assert "$status" '404'
+done
+status=$(curl -s -o /dev/null -w '%{http_code}' -u alice:AlicePass -X PATCH -H 'Tus-Resumable: 1.0.0' -H 'Content-Type: application/offset+octet-stream' -H 'Upload-Offset: 0' --data-binary 'foo' "http://127.0.0.1:3003$location")
+assert "$status" '204'
+assert "$(cat "$fs"/uploaded/1/owned.tmp)" 'foo'
+
+cleanup
+jobs -p | xargs kill &> /dev/null
@@ -0,0 +1,100 @@
+#!/bin/bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/[12]/{*.tmp,*/}
+}
+
+source "$root"/lib.bash
+
+put_status() {
+	curl -s -k -o /dev/null -w '%{http_code}' -X PUT "$@"
+}
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --upload /2 --mkdir /1 --delete /1 -a :/1/alias.tmp:"$fs"/uploaded/2 -E '' &
+sleep 0.05 # wait server ready
+cleanup
+
+# create
+echo -n 'put/1' > "$fs"/uploaded/put-src.tmp
+status=$(put_status -T "$fs"/uploaded/put-src.tmp 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '201'
+assert "$(cat "$fs"/uploaded/1/put.tmp)" 'put/1'
+rm -f "$fs"/uploaded/put-src.tmp
+
+# overwrite
+status=$(put_status --data-binary 'put/2' 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '204'
+assert "$(cat "$fs"/uploaded/1/put.tmp)" 'put/2'
+
+# If-None-Match
+status=$(put_status -H 'If-None-Match: *' --data-binary 'put/3' 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '412'
+assert "$(cat "$fs"/uploaded/1/put.tmp)" 'put/2'
+
+# If-Match
+etag=$(curl -s -I 'http://127.0.0.1:3003/1/put.tmp' | grep -i '^ETag:' | cut -d ' ' -f 2 | tr -d '\r')
+[ -n "$etag" ] || fail "ETag should exists"
+status=$(put_status -H 'If-Match: "0-0"' --data-binary 'put/3' 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '412'
+status=$(put_status -H "If-Match: $etag" --data-binary 'put/3' 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '204'
+assert "$(cat "$fs"/uploaded/1/put.tmp)" 'put/3'
+status=$(put_status -H 'If-Match: *' --data-binary 'put/4' 'http://127.0.0.1:3003/1/missing.tmp')
+assert "$status" '412'
+
+# intermediate directories
+status=$(put_status --data-binary 'put/deep' 'http://127.0.0.1:3003/1/dir.tmp/sub/deep.tmp')
+assert "$status" '201'
+assert "$(cat "$fs"/uploaded/1/dir.tmp/sub/deep.tmp)" 'put/deep'
+
+# shadowed by alias
+status=$(put_status --data-binary 'put/alias' 'http://127.0.0.1:3003/1/alias.tmp')
+assert "$status" '403'
+
+# no mkdir
+status=$(put_status --data-binary 'put/deep' 'http://127.0.0.1:3003/2/dir.tmp/deep.tmp')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/2/dir.tmp ] || fail "/uploaded/2/dir.tmp should not exists"
+
+# no overwrite, rename by default conflict policy
+status=$(put_status --data-binary 'put/2' 'http://127.0.0.1:3003/2/put.tmp')
+assert "$status" '201'
+header=$(curl_get_header -X PUT --data-binary 'put/2/new' 'http://127.0.0.1:3003/2/put.tmp')
+assert "$(echo "$header" | head -n 1 | cut -d ' ' -f 2)" '201'
+(echo "$header" | grep -q '^Location: /2/put-1.tmp') || fail "Location should be renamed file"
+assert "$(cat "$fs"/uploaded/2/put.tmp)" 'put/2'
+assert "$(cat "$fs"/uploaded/2/put-1.tmp)" 'put/2/new'
+status=$(put_status -H 'If-Match: *' --data-binary 'put/2/new' 'http://127.0.0.1:3003/2/put.tmp')
+assert "$status" '403'
+
+# no upload
+status=$(put_status --data-binary 'put' 'http://127.0.0.1:3003/put.tmp')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/put.tmp ] || fail "/uploaded/put.tmp should not exists"
+
+jobs -p | xargs kill &> /dev/null
+sleep 0.05
+cleanup
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --upload /2 --delete /2 --upload-conflict :/1:reject --upload-conflict :/2:rename -E '' &
+sleep 0.05 # wait server ready
+
+# conflict policy
+put_status --data-binary 'first' 'http://127.0.0.1:3003/1/conflict.tmp' > /dev/null
+status=$(put_status --data-binary 'second' 'http://127.0.0.1:3003/1/conflict.tmp')
+assert "$status" '409'
+assert "$(cat "$fs"/uploaded/1/conflict.tmp)" 'first'
+
+put_status --data-binary 'first' 'http://127.0.0.1:3003/2/conflict.tmp' > /dev/null
+status=$(put_status --data-binary 'second' 'http://127.0.0.1:3003/2/conflict.tmp')
+assert "$status" '201'
+assert "$(cat "$fs"/uploaded/2/conflict.tmp)" 'first'
+assert "$(cat "$fs"/uploaded/2/conflict-1.tmp)" 'second'
+
+# preconditions take priority
+status=$(put_status -H 'If-Match: *' --data-binary 'third' 'http://127.0.0.1:3003/2/conflict.tmp')
+assert "$status" '204'
+assert "$(cat "$fs"/uploaded/2/conflict.tmp)" 'third'
+
+cleanup
+jobs -p | xargs kill &> /dev/null
@@ -0,0 +1,30 @@
+#!/bin/bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/1/{*.tmp,.ghfs-upload-*}
+}
+
+source "$root"/lib.bash
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 -E '' &
+sleep 0.05 # wait server ready
+cleanup
+
+# complete upload
+curl_upload_content 'http://127.0.0.1:3003/1/?upload' 'file' 'atomic/1' 'atomic.tmp' > /dev/null
+assert "$(cat "$fs"/uploaded/1/atomic.tmp)" 'atomic/1'
+[ -z "$(ls -A "$fs"/uploaded/1 | grep '^\.ghfs-upload-')" ] || fail "temp file should be removed"
+
+# truncated upload, missing closing boundary
+body=$'--BOUNDARY\r\nContent-Disposition: form-data; name="file"; filename="truncated.tmp"\r\nContent-Type: application/octet-stream\r\n\r\ntruncated content'
+status=$(curl -s -o /dev/null -w '%{http_code}' -H 'Content-Type: multipart/form-data; boundary=BOUNDARY' --data-binary "$body" 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '500'
+[ ! -e "$fs"/uploaded/1/truncated.tmp ] || fail "/uploaded/1/truncated.tmp should not exists"
+[ -z "$(ls -A "$fs"/uploaded/1 | grep '^\.ghfs-upload-')" ] || fail "temp file should be removed"
+
+# temp file hidden from listing
+touch "$fs"/uploaded/1/.ghfs-upload-0123456789abcdef
+(curl -s 'http://127.0.0.1:3003/1/?json' | grep -q 'ghfs-upload') && fail "temp file should be hidden"
+
+cleanup
+jobs -p | xargs kill &> /dev/null
@@ -0,0 +1,57 @@
+#!/bin/bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/[12]/{*.tmp,.ghfs-upload-*}
+}
+
+source "$root"/lib.bash
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --upload /2 --upload-max-file-size 10 --upload-max-body-size 1K --upload-quota-dir :"$fs"/uploaded/2:84 -E '' &
+sleep 0.05 # wait server ready
+cleanup
+
+# file size
+status=$(curl -s -o /dev/null -w '%{http_code}' -F 'file=0123456789;filename=small.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '200'
+assert "$(cat "$fs"/uploaded/1/small.tmp)" '0123456789'
+
+body=$(curl -s -F 'file=0123456789a;filename=large.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$body" '{"success":false,"error":"uploaded file size exceeds limit","items":[{"name":"large.tmp","success":false,"error":"uploaded file size exceeds limit"}]}'
+[ ! -e "$fs"/uploaded/1/large.tmp ] || fail "/uploaded/1/large.tmp should not exists"
+[ -z "$(ls -A "$fs"/uploaded/1 | grep '^\.ghfs-upload-')" ] || fail "temp file should be removed"
+
+status=$(curl -s -o /dev/null -w '%{http_code}' -F 'file=0123456789a;filename=large.tmp' 'http://127.0.0.1:3003/1/?upload')
+assert "$status" '413'
+
+status=$(curl -s -o /dev/null -w '%{http_code}' -X PUT --data-binary '0123456789a' 'http://127.0.0.1:3003/1/large.tmp')
+assert "$status" '413'
+[ ! -e "$fs"/uploaded/1/large.tmp ] || fail "/uploaded/1/large.tmp should not exists"
+
+# body size
+content=$(head -c 2000 /dev/zero | tr '\0' 'a')
+status=$(curl -s -o /dev/null -w '%{http_code}' -F "file=$content;filename=body.tmp" 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '413'
+
+# quota, including existing index.txt of 20 bytes
+for i in 1 2 3 4 5 6; do
+	curl -s -o /dev/null -F "file=0123456789;filename=quota$i.tmp" 'http://127.0.0.1:3003/2/?upload&json'
+done
+[ -e "$fs"/uploaded/2/quota6.tmp ] || fail "/uploaded/2/quota6.tmp should exists"
+body=$(curl -s -F 'file=0123456789;filename=quota7.tmp' 'http://127.0.0.1:3003/2/?upload&json')
+assert "$body" '{"success":false,"error":"upload directory quota exceeded","items":[{"name":"quota7.tmp","success":false,"error":"upload directory quota exceeded"}]}'
+[ ! -e "$fs"/uploaded/2/quota7.tmp ] || fail "/uploaded/2/quota7.tmp should not exists"
+
+cleanup
+jobs -p | xargs kill &> /dev/null
+sleep 0.05
+
+# free space
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --upload-min-free-space 1000000T -E '' &
+sleep 0.05 # wait server ready
+
+status=$(curl -s -o /dev/null -w '%{http_code}' -F 'file=0123456789;filename=space.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '507'
+[ ! -e "$fs"/uploaded/1/space.tmp ] || fail "/uploaded/1/space.tmp should not exists"
+
+cleanup
+jobs -p | xargs kill &> /dev/null
@@ -0,0 +1,87 @@
+#!/bin/bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/1/{*.tmp,*.png,.ghfs-upload-*}
+}
+
+source "$root"/lib.bash
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --delete /1 --webdav --upload-allow '*.tmp' --upload-allow '*.png' --upload-deny 'deny*' --upload-sniff-type --upload-sanitize-name -E '' &
+sleep 0.05 # wait server ready
+cleanup
+
+# allow/deny
+body=$(curl_upload_content 'http://127.0.0.1:3003/1/?upload&json' 'file' 'allowed' 'allowed.tmp')
+assert "$body" '{"success":true,"items":[{"name":"allowed.tmp","to":"/1/allowed.tmp","success":true,"digest":{"sha-256":"eabc01f12ec3e7cb6db0ada0f8f37323b0cfe6d08a2a73479e7d5b62d7e63529"}}]}'
+assert "$(cat "$fs"/uploaded/1/allowed.tmp)" 'allowed'
+
+status=$(curl -s -o /dev/null -w '%{http_code}' -F 'file=deny;filename=deny.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/1/deny.tmp ] || fail "/uploaded/1/deny.tmp should not exists"
+
+status=$(curl -s -o /dev/null -w '%{http_code}' -F 'file=other;filename=other.txt' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/1/other.txt ] || fail "/uploaded/1/other.txt should not exists"
+
+# report each file
+body=$(curl -s -F 'file=ok;filename=ok.tmp' -F 'file=deny;filename=deny.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$body" '{"success":false,"error":"file name not allowed","items":[{"name":"ok.tmp","to":"/1/ok.tmp","success":true,"digest":{"sha-256":"2689367b205c16ce32ed4200942b8b8b1e262dfc70d9bc9fbc77c49699a4f1df"}},{"name":"deny.tmp","success":false,"error":"file name not allowed"}]}'
+assert "$(cat "$fs"/uploaded/1/ok.tmp)" 'ok'
+
+body=$(curl -s -F 'file=deny;filename=deny.tmp' 'http://127.0.0.1:3003/1/?upload')
+assert "$body" 'deny.tmp: file name not allowed'
+
+# sanitize name
+body=$'--BOUNDARY\r\nContent-Disposition: form-data; name="file"; filename*=UTF-8\'\'sani%01tized.tmp.%20\r\n\r\nsanitized\r\n--BOUNDARY--\r\n'
+status=$(curl -s -o /dev/null -w '%{http_code}' -H 'Content-Type: multipart/form-data; boundary=BOUNDARY' --data-binary "$body" 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '200'
+assert "$(cat "$fs"/uploaded/1/sanitized.tmp)" 'sanitized'
+
+# sniff type
+body=$'--BOUNDARY\r\nContent-Disposition: form-data; name="file"; filename="real.png"\r\n\r\n\x89PNG\r\n\x1a\n0000\r\n--BOUNDARY--\r\n'
+status=$(curl -s -o /dev/null -w '%{http_code}' -H 'Content-Type: multipart/form-data; boundary=BOUNDARY' --data-binary "$body" 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '200'
+[ -e "$fs"/uploaded/1/real.png ] || fail "/uploaded/1/real.png should exists"
+
+body=$'--BOUNDARY\r\nContent-Disposition: form-data; name="file"; filename="fake.png"\r\n\r\n\x7fELF0000\r\n--BOUNDARY--\r\n'
+status=$(curl -s -o /dev/null -w '%{http_code}' -H 'Content-Type: multipart/form-data; boundary=BOUNDARY' --data-binary "$body" 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '415'
+[ ! -e "$fs"/uploaded/1/fake.png ] || fail "/uploaded/1/fake.png should not exists"
+[ -z "$(ls -A "$fs"/uploaded/1 | grep '^\.ghfs-upload-')" ] || fail "temp file should be removed"
+
+# PUT
+status=$(curl -s -o /dev/null -w '%{http_code}' -X PUT --data-binary 'deny' 'http://127.0.0.1:3003/1/deny.tmp')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/1/deny.tmp ] || fail "/uploaded/1/deny.tmp should not exists"
+
+status=$(curl -s -o /dev/null -w '%{http_code}' -X PUT --data-binary $'\x7fELF0000' 'http://127.0.0.1:3003/1/fake.png')
+assert "$status" '415'
+[ ! -e "$fs"/uploaded/1/fake.png ] || fail "/uploaded/1/fake.png should not exists"
+
+# move and copy
+status=$(curl -s -o /dev/null -w '%{http_code}' -d 'name=allowed.tmp&to=deny.tmp' 'http://127.0.0.1:3003/1/?move&json')
+assert "$status" '500'
+status=$(curl -s -o /dev/null -w '%{http_code}' -X MOVE -H 'Destination: /1/deny.tmp' 'http://127.0.0.1:3003/1/allowed.tmp')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/1/deny.tmp ] || fail "/uploaded/1/deny.tmp should not exists"
+[ -e "$fs"/uploaded/1/allowed.tmp ] || fail "/uploaded/1/allowed.tmp should exists"
+
+status=$(curl -s -o /dev/null -w '%{http_code}' -X COPY -H 'Destination: /1/other.txt' 'http://127.0.0.1:3003/1/allowed.tmp')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/1/other.txt ] || fail "/uploaded/1/other.txt should not exists"
+
+echo -n 'deny' > "$fs"/uploaded/1/deny-src.tmp
+body=$(curl -s -d 'name=deny-src.tmp&to=/1' 'http://127.0.0.1:3003/1/?copy&json')
+assert "$body" '{"success":false,"items":[{"name":"deny-src.tmp","success":false,"error":"file name not allowed"}]}'
+[ ! -e "$fs"/uploaded/1/deny-src-1.tmp ] || fail "/uploaded/1/deny-src-1.tmp should not exists"
+
+# lock unmapped url
+status=$(curl -s -o /dev/null -w '%{http_code}' -X LOCK --data-binary '<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>' 'http://127.0.0.1:3003/1/deny-lock.tmp')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/1/deny-lock.tmp ] || fail "/uploaded/1/deny-lock.tmp should not exists"
+status=$(curl -s -o /dev/null -w '%{http_code}' -X LOCK --data-binary '<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>' 'http://127.0.0.1:3003/1/lock.tmp')
+assert "$status" '201'
+[ -e "$fs"/uploaded/1/lock.tmp ] || fail "/uploaded/1/lock.tmp should exists"
+
+cleanup
+jobs -p | xargs kill &> /dev/null
@@ -0,0 +1,62 @@
+#!/bin/bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/[12]/{*.tmp,.ghfs-upload-*}
+}
+
+source "$root"/lib.bash
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --upload /2 --delete /2 --upload-conflict :/1:reject --upload-conflict-dir :"$fs"/uploaded/2:newer -E '' &
+sleep 0.05 # wait server ready
+cleanup
+
+# reject
+body=$(curl_upload_content 'http://127.0.0.1:3003/1/?upload&json' 'file' 'first' 'conflict.tmp')
+assert "$body" '{"success":true,"items":[{"name":"conflict.tmp","to":"/1/conflict.tmp","success":true,"digest":{"sha-256":"a7937b64b8caa58f03721bb6bacf5c78cb235febe0e70b1b84cd99541461a08e"}}]}'
+
+body=$(curl -s -w ' %{http_code}' -F 'file=second;filename=conflict.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$body" '{"success":false,"error":"file already exists","items":[{"name":"conflict.tmp","success":false,"error":"file already exists"}]} 409'
+assert "$(cat "$fs"/uploaded/1/conflict.tmp)" 'first'
+
+# newer by client mtime
+curl -s -o /dev/null -F 'mtime=1000000000000' -F 'file=first;filename=conflict.tmp' 'http://127.0.0.1:3003/2/?upload&json'
+assert "$(cat "$fs"/uploaded/2/conflict.tmp)" 'first'
+assert "$(date -r "$fs"/uploaded/2/conflict.tmp +%s)" '1000000000'
+
+body=$(curl -s -F 'mtime=900000000000' -F 'file=older;filename=conflict.tmp' 'http://127.0.0.1:3003/2/?upload&json')
+assert "$body" '{"success":true,"items":[{"name":"conflict.tmp","to":"/2/conflict.tmp","success":true,"skipped":true}]}'
+assert "$(cat "$fs"/uploaded/2/conflict.tmp)" 'first'
+
+body=$(curl -s -F 'mtime=1100000000000' -F 'file=newer;filename=conflict.tmp' 'http://127.0.0.1:3003/2/?upload&json')
+assert "$body" '{"success":true,"items":[{"name":"conflict.tmp","to":"/2/conflict.tmp","success":true,"digest":{"sha-256":"804f51f71254c4081e37e7c887073560f4a6fa6cdad202e9ac67e032c43ed1e1"}}]}'
+assert "$(cat "$fs"/uploaded/2/conflict.tmp)" 'newer'
+assert "$(date -r "$fs"/uploaded/2/conflict.tmp +%s)" '1100000000'
+
+# without mtime, rename
+body=$(curl_upload_content 'http://127.0.0.1:3003/2/?upload&json' 'file' 'unknown' 'conflict.tmp')
+assert "$body" '{"success":true,"items":[{"name":"conflict.tmp","to":"/2/conflict-1.tmp","success":true,"digest":{"sha-256":"b23a6a8439c0dde5515893e7c90c1e3233b8616e634470f20dc4928bcf3609bc"}}]}'
+assert "$(cat "$fs"/uploaded/2/conflict-1.tmp)" 'unknown'
+
+# mtime only applies to next file
+body=$(curl -s -F 'mtime=1' -F 'file=a;filename=a.tmp' -F 'file=b;filename=conflict.tmp' 'http://127.0.0.1:3003/2/?upload&json')
+assert "$body" '{"success":true,"items":[{"name":"a.tmp","to":"/2/a.tmp","success":true,"digest":{"sha-256":"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"}},{"name":"conflict.tmp","to":"/2/conflict-2.tmp","success":true,"digest":{"sha-256":"3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d"}}]}'
+
+jobs -p | xargs kill &> /dev/null
+cleanup
+
+# overwrite without delete permission, rename
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --upload-conflict :/1:overwrite -E '' &
+sleep 0.05 # wait server ready
+
+curl_upload_content 'http://127.0.0.1:3003/1/?upload&json' 'file' 'first' 'conflict.tmp' > /dev/null
+body=$(curl_upload_content 'http://127.0.0.1:3003/1/?upload&json' 'file' 'second' 'conflict.tmp')
+assert "$(echo "$body" | grep -o '"to":"[^"]*"')" '"to":"/1/conflict-1.tmp"'
+assert "$(cat "$fs"/uploaded/1/conflict.tmp)" 'first'
+assert "$(cat "$fs"/uploaded/1/conflict-1.tmp)" 'second'
+
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X PUT --data-binary 'put' 'http://127.0.0.1:3003/1/conflict.tmp')" '201'
+assert "$(cat "$fs"/uploaded/1/conflict.tmp)" 'first'
+assert "$(cat "$fs"/uploaded/1/conflict-2.tmp)" 'put'
+
+jobs -p | xargs kill &> /dev/null
+cleanup
@@ -0,0 +1,55 @@
+#!/bin/bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/1/{*.tmp,.ghfs-upload-*}
+}
+
+source "$root"/lib.bash
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --delete /1 -E '' &
+sleep 0.05 # wait server ready
+cleanup
+
+sha256='2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824'
+md5='5d41402abc4b2a76b9719d911017c592'
+
+# part header
+body=$(curl -s -F 'file=hello;filename=header.tmp;headers="Content-Digest: sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:"' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$body" '{"success":true,"items":[{"name":"header.tmp","to":"/1/header.tmp","success":true,"digest":{"sha-256":"'$sha256'"}}]}'
+assert "$(cat "$fs"/uploaded/1/header.tmp)" 'hello'
+
+# companion form field
+body=$(curl -s -F "digest=md5=$md5" -F 'file=hello;filename=field.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$body" '{"success":true,"items":[{"name":"field.tmp","to":"/1/field.tmp","success":true,"digest":{"md5":"'$md5'","sha-256":"'$sha256'"}}]}'
+
+# mismatch
+body=$(curl -s -w ' %{http_code}' -F "digest=sha256=$md5$md5" -F 'file=bad;filename=bad.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$body" '{"success":false,"error":"file digest mismatch","items":[{"name":"bad.tmp","success":false,"error":"file digest mismatch"}]} 400'
+[ ! -e "$fs"/uploaded/1/bad.tmp ] || fail "/uploaded/1/bad.tmp should not exists"
+[ -z "$(ls -A "$fs"/uploaded/1 | grep '^\.ghfs-upload-')" ] || fail "temp file should be removed"
+
+# mismatch when overwriting
+body=$(curl -s -w ' %{http_code}' -F "digest=md5=$md5" -F 'file=bad;filename=header.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$body" '{"success":false,"error":"file digest mismatch","items":[{"name":"header.tmp","success":false,"error":"file digest mismatch"}]} 400'
+assert "$(cat "$fs"/uploaded/1/header.tmp)" 'hello'
+
+# invalid
+status=$(curl -s -o /dev/null -w '%{http_code}' -F 'digest=sha-256=:aGVsbG8=:' -F 'file=hello;filename=invalid.tmp' 'http://127.0.0.1:3003/1/?upload&json')
+assert "$status" '400'
+[ ! -e "$fs"/uploaded/1/invalid.tmp ] || fail "/uploaded/1/invalid.tmp should not exists"
+
+# PUT
+header=$(curl -s -D - -o /dev/null -X PUT -H 'Repr-Digest: sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:' --data-binary 'hello' 'http://127.0.0.1:3003/1/put.tmp' | grep -i '^Repr-Digest:' | tr -d '\r')
+assert "$header" 'Repr-Digest: sha-256=:LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=:'
+assert "$(cat "$fs"/uploaded/1/put.tmp)" 'hello'
+
+status=$(curl -s -o /dev/null -w '%{http_code}' -X PUT -H "Content-Digest: md5=$md5" --data-binary 'bad' 'http://127.0.0.1:3003/1/bad.tmp')
+assert "$status" '400'
+[ ! -e "$fs"/uploaded/1/bad.tmp ] || fail "/uploaded/1/bad.tmp should not exists"
+
+status=$(curl -s -o /dev/null -w '%{http_code}' -X PUT -H "Content-Digest: md5=$md5" --data-binary 'bad' 'http://127.0.0.1:3003/1/put.tmp')
+assert "$status" '400'
+assert "$(cat "$fs"/uploaded/1/put.tmp)" 'hello'
+
+cleanup
+jobs -p | xargs kill &> /dev/null
@@ -0,0 +1,79 @@
+#!/bin/bash
+
+source "$root"/lib.bash
+
+tokenfile="$fs"/../tokens.tmp
+accesslog="$fs"/../access.log.tmp
+
+cleanup() {
+	rm -rf "$fs"/uploaded/[12]/{*.tmp,.ghfs-upload-*} "$tokenfile" "$accesslog"
+}
+
+cleanup
+cat > "$tokenfile" <<-'EOT'
+	# name token scopes
+	reader ReaderToken perm=read
+	expired ExpiredToken expires=2000-01-01
+EOT
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --global-auth --user alice:AlicePass \
+	--token :ci:CiToken:path=/1:perm=upload \
+	--token-file "$tokenfile" \
+	--upload /1 /2 --mkdir /1 --delete /1 \
+	-L "$accesslog" \
+	-E '' &
+sleep 0.05 # wait server ready
+
+bearer_status() {
+	token="$1"
+	shift
+	curl -s -k -o /dev/null -w '%{http_code}' -H "Authorization: Bearer $token" "$@"
+}
+
+# read
+status=$(bearer_status ReaderToken 'http://127.0.0.1:3003/1/')
+assert "$status" '200'
+status=$(bearer_status BadToken 'http://127.0.0.1:3003/1/')
+assert "$status" '401'
+status=$(bearer_status ExpiredToken 'http://127.0.0.1:3003/1/')
+assert "$status" '401'
+status=$(bearer_status CiToken 'http://127.0.0.1:3003/1/')
+assert "$status" '403'
+
+# read-only token
+(curl -s -H 'Authorization: Bearer ReaderToken' 'http://127.0.0.1:3003/1/?json' | grep -q '"canUpload":false') || fail "canUpload should be false for reader token"
+status=$(bearer_status ReaderToken -T - 'http://127.0.0.1:3003/1/reader.tmp' <<< 'reader')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/1/reader.tmp ] || fail "/uploaded/1/reader.tmp should not be exists"
+
+# upload token
+status=$(bearer_status CiToken -T - 'http://127.0.0.1:3003/1/ci.tmp' <<< 'ci')
+assert "$status" '201'
+assert "$(cat "$fs"/uploaded/1/ci.tmp)" 'ci'
+
+# path scope
+status=$(bearer_status CiToken -T - 'http://127.0.0.1:3003/2/ci.tmp' <<< 'ci')
+assert "$status" '403'
+[ ! -e "$fs"/uploaded/2/ci.tmp ] || fail "/uploaded/2/ci.tmp should not be exists"
+
+# no delete permission
+status=$(bearer_status CiToken -X POST -d 'name=ci.tmp' 'http://127.0.0.1:3003/1?delete')
+[ -e "$fs"/uploaded/1/ci.tmp ] || fail "/uploaded/1/ci.tmp should not be deleted by ci token"
+
+# reload token file
+echo 'reader ReaderToken2' > "$tokenfile"
+touch -d '+2 seconds' "$tokenfile"
+sleep 1.1
+status=$(bearer_status ReaderToken 'http://127.0.0.1:3003/1/')
+assert "$status" '401'
+status=$(bearer_status ReaderToken2 -X POST -d 'name=ci.tmp' 'http://127.0.0.1:3003/1?delete')
+[ ! -e "$fs"/uploaded/1/ci.tmp ] || fail "/uploaded/1/ci.tmp should be deleted by reader token"
+
+# access log
+sleep 0.05
+grep -q '(ci) 201 PUT /1/ci.tmp' "$accesslog" || fail "access log should contain token name"
+grep -q '(reader) delete: ' "$accesslog" || fail "mutate log should contain token name"
+
+jobs -p | xargs kill &> /dev/null
+
+cleanup
@@ -0,0 +1,88 @@
+#!/bin/bash
+
+source "$root"/lib.bash
+
+cookie="$fs"/uploaded/cookie.tmp
+accesslog="$fs"/../access.log.tmp
+
+cleanup() {
+	rm -rf "$cookie" "$accesslog"
+}
+
+cleanup
+
+"$ghfs" -l 3003 -r "$fs"/vhost1 --trusted-proxy 127.0.0.0/8 --trusted-proxy 192.0.2.10 \
+	--force-dir-slash --form-login --user alice:AlicePass \
+	-L "$accesslog" \
+	-E '' &
+sleep 0.05 # wait server ready
+
+# client ip
+curl -s -o /dev/null -H 'X-Forwarded-For: 198.51.100.1, 192.0.2.10' http://127.0.0.1:3003/yes/
+curl -s -o /dev/null -H 'Forwarded: for="[2001:db8::1]:4711";proto=https' http://127.0.0.1:3003/hello/
+sleep 0.05
+grep -q ' 198\.51\.100\.1:0 200 GET /yes/$' "$accesslog" || fail "should log client ip from X-Forwarded-For"
+grep -q ' \[2001:db8::1\]:4711 200 GET /hello/$' "$accesslog" || fail "should log client ip from Forwarded"
+
+# prefix
+(curl -s -i -H 'X-Forwarded-Prefix: /files' http://127.0.0.1:3003/hello | grep -q -i 'location:\s*/files/hello/') ||
+	fail "redirect location should contain forwarded prefix"
+
+# scheme
+(curl -s -i -H 'X-Forwarded-Proto: https' -d 'username=alice&password=AlicePass' 'http://127.0.0.1:3003/?login' | grep -q -i '^set-cookie: ghfs_session=.*; Secure') ||
+	fail "session cookie should be secure for forwarded https"
+(curl -s -i -d 'username=alice&password=AlicePass' 'http://127.0.0.1:3003/?login' | grep -q -i '^set-cookie: ghfs_session=.*; Secure') &&
+	fail "session cookie should not be secure for plain http"
+(curl -s -i -H 'X-Forwarded-For: 198.51.100.1' -H 'X-Forwarded-Proto: https, http' -d 'username=alice&password=AlicePass' 'http://127.0.0.1:3003/?login' | grep -q -i '^set-cookie: ghfs_session=.*; Secure') &&
+	fail "scheme should be from entry appended by trusted proxy"
+(curl -s -i -H 'Forwarded: proto=https, for=198.51.100.1' -d 'username=alice&password=AlicePass' 'http://127.0.0.1:3003/?login' | grep -q -i '^set-cookie: ghfs_session=.*; Secure') &&
+	fail "scheme should be from Forwarded element appended by trusted proxy"
+
+jobs -p | xargs kill &> /dev/null
+cleanup
+
+# untrusted peer
+"$ghfs" -l 3003 -r "$fs"/vhost1 --trusted-proxy 192.0.2.0/24 -L "$accesslog" -E '' &
+sleep 0.05 # wait server ready
+
+curl -s -o /dev/null -H 'X-Forwarded-For: 198.51.100.1' http://127.0.0.1:3003/yes/
+sleep 0.05
+grep -q ' 127\.0\.0\.1:[0-9]* 200 GET /yes/$' "$accesslog" || fail "should ignore X-Forwarded-For from untrusted peer"
+
+jobs -p | xargs kill &> /dev/null
+cleanup
+
+# PROXY protocol
+"$ghfs" -l 3003 -r "$fs"/vhost1 --proxy-protocol --trusted-proxy 127.0.0.0/8 -L "$accesslog" -E '' &
+sleep 0.05 # wait server ready
+
+assert "$(curl -s -o /dev/null -w '%{http_code}' --haproxy-protocol http://127.0.0.1:3003/yes/)" '200'
+assert "$(curl -s -o /dev/null -w '%{http_code}' http://127.0.0.1:3003/yes/)" '400'
+
+exec 3<>/dev/tcp/127.0.0.1/3003
+printf 'PROXY TCP4 198.51.100.2 127.0.0.1 56324 3003\r\nGET /hello/ HTTP/1.0\r\n\r\n' >&3
+status=$(head -n 1 <&3 | cut -d ' ' -f 2)
+exec 3<&-
+assert "$status" '200'
+sleep 0.05
+grep -q ' 198\.51\.100\.2:56324 200 GET /hello/$' "$accesslog" || fail "should log client address from PROXY protocol"
+
+jobs -p | xargs kill &> /dev/null
+cleanup
+
+# PROXY protocol from untrusted peer
+"$ghfs" -l 3003 -r "$fs"/vhost1 --proxy-protocol --trusted-proxy 192.0.2.0/24 -L "$accesslog" -E '' &
+sleep 0.05 # wait server ready
+
+assert "$(curl -s -o /dev/null -w '%{http_code}' http://127.0.0.1:3003/yes/)" '200'
+
+exec 3<>/dev/tcp/127.0.0.1/3003
+printf 'PROXY TCP4 198.51.100.2 127.0.0.1 56324 3003\r\nGET /hello/ HTTP/1.0\r\n\r\n' >&3
+status=$(head -n 1 <&3 | cut -d ' ' -f 2)
+exec 3<&-
+assert "$status" '400'
+sleep 0.05
+grep -q ' 198\.51\.100\.2' "$accesslog" && fail "should not trust PROXY protocol header from untrusted peer"
+
+jobs -p | xargs kill &> /dev/null
+cleanup
@@ -0,0 +1,59 @@
+#!/bin/bash
+
+source "$root"/lib.bash
+
+errorlog="$fs"/../error.log.tmp
+
+cleanup() {
+	rm -rf "$errorlog" "$fs"/uploaded/[12]/*.tmp
+}
+
+cleanup
+
+# client IP is provided by X-Forwarded-For from trusted local proxy
+"$ghfs" -l 3003 -r "$fs"/uploaded --trusted-proxy 127.0.0.1 --global-upload --global-mkdir --global-delete \
+	--global-deny-ip 192.0.2.66 \
+	--allow-ip :/2:10.0.0.0/8 \
+	--global-mutate-allow-ip 10.1.0.0/16 \
+	--mutate-deny-ip :/1:10.1.2.3 \
+	-E "$errorlog" &
+sleep 0.05 # wait server ready
+
+from() {
+	echo "-H X-Forwarded-For:$1"
+}
+
+upload_status() {
+	curl -s -o /dev/null -w '%{http_code}' -H "X-Forwarded-For: $1" -F "file=content;filename=$3" "$2?upload"
+}
+
+# access
+assert "$(curl_get_status $(from 192.0.2.1) http://127.0.0.1:3003/1/index.txt)" '200'
+assert "$(curl_get_status $(from 192.0.2.66) http://127.0.0.1:3003/1/index.txt)" '403'
+assert "$(curl_get_status $(from 192.0.2.1) http://127.0.0.1:3003/2/index.txt)" '403'
+assert "$(curl_get_status $(from 10.0.0.1) http://127.0.0.1:3003/2/index.txt)" '200'
+assert "$(curl_get_status http://127.0.0.1:3003/2/index.txt)" '403'
+
+# mutate
+assert "$(upload_status 192.0.2.1 http://127.0.0.1:3003/1/ a.tmp)" '403'
+[ -e "$fs"/uploaded/1/a.tmp ] && fail "/1/a.tmp should not be uploaded"
+assert "$(upload_status 10.1.0.1 http://127.0.0.1:3003/1/ a.tmp)" '302'
+[ -e "$fs"/uploaded/1/a.tmp ] || fail "/1/a.tmp should be uploaded"
+assert "$(upload_status 10.1.2.3 http://127.0.0.1:3003/1/ b.tmp)" '403'
+[ -e "$fs"/uploaded/1/b.tmp ] && fail "/1/b.tmp should not be uploaded"
+assert "$(upload_status 10.1.2.3 http://127.0.0.1:3003/2/ b.tmp)" '302'
+[ -e "$fs"/uploaded/2/b.tmp ] || fail "/2/b.tmp should be uploaded"
+
+assert "$(curl_get_status $(from 192.0.2.1) 'http://127.0.0.1:3003/1/?delete&name=a.tmp')" '403'
+[ -e "$fs"/uploaded/1/a.tmp ] || fail "/1/a.tmp should not be deleted"
+
+(curl_get_body $(from 192.0.2.1) 'http://127.0.0.1:3003/1/?json' | grep -q '"canUpload":true') &&
+	fail "upload should not be available"
+(curl_get_body $(from 10.1.0.1) 'http://127.0.0.1:3003/1/?json' | grep -q '"canUpload":true') ||
+	fail "upload should be available"
+
+# log
+grep -q '192\.0\.2\.66:0 blocked by rule: connect /1/index.txt' "$errorlog" || fail "blocked request should be logged"
+
+jobs -p | xargs kill &> /dev/null
+cleanup
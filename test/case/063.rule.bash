@@ -0,0 +1,93 @@
+#!/bin/bash
+
+source "$root"/lib.bash
+
+errorlog="$fs"/../error.log.tmp
+
+cleanup() {
+	rm -rf "$errorlog" "$fs"/uploaded/[12]/*.tmp
+}
+
+cleanup
+
+# client IP is provided by X-Forwarded-For from trusted local proxy
+"$ghfs" -l 3003 -r "$fs"/uploaded --trusted-proxy 127.0.0.1 \
+	--user alice:AlicePass --user bob:BobPass \
+	--rule 'deny access url=/2/** cidr=192.0.2.0/24' \
+	--rule 'allow upload,mkdir url=/1/** user=alice' \
+	--rule 'allow archive url=/1/** time=00:00-24:00' \
+	--auth-user :/2:bob \
+	--restrict-access :/2 \
+	--rule-explain \
+	-E "$errorlog" &
+sleep 0.05 # wait server ready
+
+from() {
+	echo "-H X-Forwarded-For:$1"
+}
+
+upload_status() {
+	curl -s -o /dev/null -w '%{http_code}' -u "$1" -F "file=content;filename=$3" "$2?upload"
+}
+
+# access
+assert "$(curl_get_status $(from 10.0.0.1) http://127.0.0.1:3003/1/index.txt)" '200'
+assert "$(curl_get_status $(from 192.0.2.1) http://127.0.0.1:3003/2/index.txt)" '403'
+assert "$(curl_get_status $(from 192.0.2.1) -u bob:BobPass http://127.0.0.1:3003/2/index.txt)" '403'
+assert "$(curl_get_status $(from 10.0.0.1) http://127.0.0.1:3003/2/index.txt)" '401'
+assert "$(curl_get_status $(from 10.0.0.1) -u alice:AlicePass http://127.0.0.1:3003/2/index.txt)" '401'
+assert "$(curl_get_status $(from 10.0.0.1) -u bob:BobPass --referer http://127.0.0.1:3003/2/ http://127.0.0.1:3003/2/index.txt)" '200'
+assert "$(curl_get_status $(from 10.0.0.1) -u bob:BobPass http://127.0.0.1:3003/2/index.txt)" '403'
+
+# upload
+assert "$(upload_status alice:AlicePass http://127.0.0.1:3003/1/ a.tmp)" '302'
+[ -e "$fs"/uploaded/1/a.tmp ] || fail "/1/a.tmp should be uploaded"
+upload_status bob:BobPass http://127.0.0.1:3003/1/ b.tmp > /dev/null
+[ -e "$fs"/uploaded/1/b.tmp ] && fail "/1/b.tmp should not be uploaded"
+
+(curl_get_body -u alice:AlicePass 'http://127.0.0.1:3003/1/?json' | grep -q '"canUpload":true') ||
+	fail "upload should be available for alice"
+(curl_get_body -u bob:BobPass 'http://127.0.0.1:3003/1/?json' | grep -q '"canUpload":true') &&
+	fail "upload should not be available for bob"
+(curl_get_body 'http://127.0.0.1:3003/1/?json' | grep -q '"canArchive":true') ||
+	fail "archive should be available"
+
+# explain
+explain=$(curl_get_body -u alice:AlicePass 'http://127.0.0.1:3003/1/?explain')
+echo "$explain" | grep -q '^user: alice$' || fail "explain should show user"
+echo "$explain" | grep -qF 'upload: allow by rule #2 allow upload,mkdir url=/1/** user=alice (--rule)' || fail "explain should show upload rule"
+echo "$explain" | grep -qF 'delete: deny by default' || fail "explain should show delete default"
+
+explain=$(curl_get_body $(from 10.0.0.1) -u bob:BobPass 'http://127.0.0.1:3003/2/?explain')
+echo "$explain" | grep -q '^ip: 10\.0\.0\.1$' || fail "explain should show client ip"
+echo "$explain" | grep -qF 'access: allow by default' || fail "explain should show access default"
+echo "$explain" | grep -q '^referer: (none)$' || fail "explain should show empty referer"
+echo "$explain" | grep -qF 'referer: deny by rule' || fail "explain should show restrict access rule"
+echo "$explain" | grep -qF 'deny referer url=/2/** (--restrict-access)' || fail "explain should show restrict access option"
+
+explain=$(curl_get_body --referer 'http://127.0.0.1:3003/2/' -u bob:BobPass 'http://127.0.0.1:3003/2/?explain')
+echo "$explain" | grep -q '^referer: 127\.0\.0\.1:3003$' || fail "explain should show referer"
+echo "$explain" | grep -qF 'referer: allow by rule' || fail "explain should allow current host"
+echo "$explain" | grep -qF 'allow referer referer=self (--restrict-access)' || fail "explain should show self referer rule"
+
+# no explain if access is denied
+(curl_get_body $(from 192.0.2.1) -u bob:BobPass 'http://127.0.0.1:3003/2/?explain' | grep -qF 'access:') &&
+	fail "explain should not be served to blocked client"
+assert "$(curl_get_status $(from 192.0.2.1) -u bob:BobPass 'http://127.0.0.1:3003/2/?explain')" '403'
+(curl_get_body 'http://127.0.0.1:3003/2/?explain' | grep -qF 'access:') &&
+	fail "explain should not be served to unauthorized client"
+assert "$(curl_get_status 'http://127.0.0.1:3003/2/?explain')" '401'
+
+# log
+grep -q '192\.0\.2\.1:0 blocked by rule: access /2/index.txt' "$errorlog" || fail "blocked request should be logged"
+
+jobs -p | xargs kill &> /dev/null
+cleanup
+
+# explain is disabled by default
+"$ghfs" -l 3003 -r "$fs"/uploaded -E '' &
+sleep 0.05 # wait server ready
+
+curl_get_body 'http://127.0.0.1:3003/1/?explain' | grep -q 'by default' && fail "explain should be disabled"
+
+jobs -p | xargs kill &> /dev/null
@@ -0,0 +1,79 @@
+#!/bin/bash
+
+source "$root"/lib.bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/[12]/*.tmp
+}
+
+cleanup
+mkdir -p "$fs"/uploaded/2/outer.tmp/box
+echo -n 'secret' > "$fs"/uploaded/2/outer.tmp/box/secret.txt
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --global-upload --global-delete --global-archive --global-mkdir --webdav \
+	--dropbox /1 --dropbox /2/outer.tmp/box \
+	-E '' &
+sleep 0.05 # wait server ready
+
+upload_name() {
+	curl -s -F "file=$2;filename=$3" "$1?upload&json" | grep -o '"to":"[^"]*"'
+}
+
+# listing and reading
+(curl_get_body 'http://127.0.0.1:3003/1/?json' | grep -q '"subItems":\[\]') || fail "drop box items should not be listed"
+(curl_get_body 'http://127.0.0.1:3003/1/?json' | grep -q '"canUpload":true') || fail "upload should be available"
+(curl_get_body 'http://127.0.0.1:3003/1/?json' | grep -q '"canDelete":true') && fail "delete should not be available"
+(curl_get_body 'http://127.0.0.1:3003/1/?json' | grep -q '"canArchive":true') && fail "archive should not be available"
+curl_get_body 'http://127.0.0.1:3003/1/' | grep -q 'index.txt' && fail "drop box items should not be shown on page"
+assert "$(curl_get_status 'http://127.0.0.1:3003/1/index.txt')" '403'
+assert "$(curl_get_status 'http://127.0.0.1:3003/1/?zip')" '400'
+assert "$(curl_get_status 'http://127.0.0.1:3003/2/index.txt')" '200'
+curl -s -X PROPFIND -H 'Depth: 1' 'http://127.0.0.1:3003/1/' | grep -q 'index.txt' && fail "drop box items should not be listed by WebDAV"
+
+# upload
+assert "$(upload_name http://127.0.0.1:3003/1/ content1 a.tmp)" '"to":"/1/a-1.tmp"'
+assert "$(upload_name http://127.0.0.1:3003/1/ content2 a.tmp)" '"to":"/1/a-2.tmp"'
+assert "$(cat "$fs"/uploaded/1/a-1.tmp)" 'content1'
+assert "$(cat "$fs"/uploaded/1/a-2.tmp)" 'content2'
+assert "$(upload_name http://127.0.0.1:3003/2/ content3 a.tmp)" '"to":"/2/a.tmp"'
+
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X PUT --data-binary 'put' 'http://127.0.0.1:3003/1/put.tmp')" '201'
+assert "$(cat "$fs"/uploaded/1/put-1.tmp)" 'put'
+[ -e "$fs"/uploaded/1/put.tmp ] && fail "/1/put.tmp should be renamed"
+
+# mutate
+curl -s -o /dev/null -X POST -d 'name=a-1.tmp' 'http://127.0.0.1:3003/1/?delete'
+[ -e "$fs"/uploaded/1/a-1.tmp ] || fail "/1/a-1.tmp should not be deleted"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X DELETE 'http://127.0.0.1:3003/1/a-1.tmp')" '403'
+[ -e "$fs"/uploaded/1/a-1.tmp ] || fail "/1/a-1.tmp should not be deleted by WebDAV"
+curl -s -o /dev/null -X POST -d 'name=a-1.tmp&to=/2/' 'http://127.0.0.1:3003/1/?copy'
+[ -e "$fs"/uploaded/2/a-1.tmp ] && fail "/1/a-1.tmp should not be copied out"
+curl -s -o /dev/null -X COPY -H 'Destination: /2/b.tmp' 'http://127.0.0.1:3003/1/a-1.tmp'
+[ -e "$fs"/uploaded/2/b.tmp ] && fail "/1/a-1.tmp should not be copied out by WebDAV"
+echo -n 'outside' > "$fs"/uploaded/2/c.tmp
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X COPY -H 'Overwrite: F' -H 'Destination: /1/a-1.tmp' 'http://127.0.0.1:3003/2/c.tmp')" '403'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X COPY -H 'Overwrite: F' -H 'Destination: /1/c.tmp' 'http://127.0.0.1:3003/2/c.tmp')" '403'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X MOVE -H 'Destination: /1/a-1.tmp' 'http://127.0.0.1:3003/2/c.tmp')" '403'
+assert "$(cat "$fs"/uploaded/1/a-1.tmp)" 'content1'
+[ -e "$fs"/uploaded/1/c.tmp ] && fail "/2/c.tmp should not be copied into drop box by WebDAV"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X LOCK --data-binary '<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>' 'http://127.0.0.1:3003/1/a-1.tmp')" '403'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X LOCK --data-binary '<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>' 'http://127.0.0.1:3003/1/d.tmp')" '403'
+[ -e "$fs"/uploaded/1/d.tmp ] && fail "LOCK should not create item in drop box"
+
+# tree containing drop box
+(curl_get_body 'http://127.0.0.1:3003/2/?json' | grep -q '"canArchive":true') &&
+	fail "archive should not be available for tree containing drop box"
+assert "$(curl_get_status 'http://127.0.0.1:3003/2/outer.tmp/?tar')" '400'
+curl -s -o /dev/null -X POST -d 'name=outer.tmp' 'http://127.0.0.1:3003/2/?delete'
+[ -e "$fs"/uploaded/2/outer.tmp/box/secret.txt ] || fail "tree containing drop box should not be deleted"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X DELETE 'http://127.0.0.1:3003/2/outer.tmp')" '403'
+[ -e "$fs"/uploaded/2/outer.tmp/box/secret.txt ] || fail "tree containing drop box should not be deleted by WebDAV"
+curl -s -o /dev/null -X POST -d 'name=outer.tmp&to=moved.tmp' 'http://127.0.0.1:3003/2/?move'
+[ -e "$fs"/uploaded/2/moved.tmp ] && fail "tree containing drop box should not be moved"
+curl -s -o /dev/null -X POST -d 'name=outer.tmp&to=/2/' 'http://127.0.0.1:3003/2/?copy'
+[ -e "$fs"/uploaded/2/outer-1.tmp ] && fail "tree containing drop box should not be copied"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X COPY -H 'Destination: /2/copied.tmp' 'http://127.0.0.1:3003/2/outer.tmp')" '403'
+[ -e "$fs"/uploaded/2/copied.tmp ] && fail "tree containing drop box should not be copied by WebDAV"
+
+jobs -p | xargs kill &> /dev/null
+cleanup
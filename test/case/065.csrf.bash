@@ -0,0 +1,65 @@
+#!/bin/bash
+
+source "$root"/lib.bash
+
+jar="$fs"/../csrf.cookie.tmp
+
+cleanup() {
+	rm -rf "$jar" "$fs"/uploaded/1/*.tmp "$fs"/uploaded/1/*.tmp.d "$fs"/uploaded/1/.ghfs-tus
+}
+
+cleanup
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --global-upload --global-mkdir --global-delete --webdav \
+	--user alice:AlicePass --share --share-secret ShareSecret \
+	-E '' &
+sleep 0.05 # wait server ready
+
+mkdir_status() {
+	curl -s -o /dev/null -w '%{http_code}' -b "$jar" -X POST "$@"
+}
+
+# token
+token=$(curl -s -c "$jar" 'http://127.0.0.1:3003/1/?json' | grep -o '"csrfToken":"[^"]*"' | cut -d '"' -f 4)
+[ -n "$token" ] || fail "csrf token should be provided"
+grep -q 'ghfs_csrf' "$jar" || fail "csrf cookie should be set"
+curl -s -b "$jar" 'http://127.0.0.1:3003/1/' | grep -qF "name=\"csrf\" value=\"$token\"" || fail "csrf token should be embedded in page"
+
+# browser
+assert "$(mkdir_status -d "name=a.tmp.d&csrf=$token" 'http://127.0.0.1:3003/1/?mkdir')" '302'
+[ -d "$fs"/uploaded/1/a.tmp.d ] || fail "/1/a.tmp.d should be created"
+assert "$(mkdir_status -H "X-CSRF-Token: $token" -H 'Sec-Fetch-Site: same-origin' -d 'name=b.tmp.d' 'http://127.0.0.1:3003/1/?mkdir')" '302'
+[ -d "$fs"/uploaded/1/b.tmp.d ] || fail "/1/b.tmp.d should be created"
+assert "$(mkdir_status -F 'file=content;filename=a.tmp' "http://127.0.0.1:3003/1/?upload&csrf=$token")" '302'
+[ -e "$fs"/uploaded/1/a.tmp ] || fail "/1/a.tmp should be uploaded"
+
+assert "$(mkdir_status -d 'name=c.tmp.d' 'http://127.0.0.1:3003/1/?mkdir')" '403'
+assert "$(mkdir_status -d 'name=c.tmp.d&csrf=invalid' 'http://127.0.0.1:3003/1/?mkdir')" '403'
+assert "$(mkdir_status -H 'Sec-Fetch-Site: cross-site' -d "name=c.tmp.d&csrf=$token" 'http://127.0.0.1:3003/1/?mkdir')" '403'
+assert "$(mkdir_status -H 'Origin: http://example.com' -d "name=c.tmp.d&csrf=$token" 'http://127.0.0.1:3003/1/?mkdir')" '403'
+assert "$(mkdir_status -d 'name=a.tmp.d' 'http://127.0.0.1:3003/1/?delete')" '403'
+[ -e "$fs"/uploaded/1/c.tmp.d ] && fail "/1/c.tmp.d should not be created"
+[ -d "$fs"/uploaded/1/a.tmp.d ] || fail "/1/a.tmp.d should not be deleted"
+
+# other mutating methods
+assert "$(curl -s -o /dev/null -w '%{http_code}' -b "$jar" -X PUT --data-binary 'put' 'http://127.0.0.1:3003/1/put.tmp')" '403'
+[ -e "$fs"/uploaded/1/put.tmp ] && fail "/1/put.tmp should not be created"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -b "$jar" -H "X-CSRF-Token: $token" -X PUT --data-binary 'csrf=put' 'http://127.0.0.1:3003/1/put.tmp')" '201'
+assert "$(cat "$fs"/uploaded/1/put.tmp)" 'csrf=put'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -b "$jar" -X MKCOL 'http://127.0.0.1:3003/1/d.tmp.d')" '403'
+[ -e "$fs"/uploaded/1/d.tmp.d ] && fail "/1/d.tmp.d should not be created by WebDAV"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -b "$jar" -X DELETE 'http://127.0.0.1:3003/1/a.tmp.d')" '403'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -b "$jar" -X MOVE -H 'Destination: /1/e.tmp.d' 'http://127.0.0.1:3003/1/a.tmp.d')" '403'
+[ -d "$fs"/uploaded/1/a.tmp.d ] || fail "/1/a.tmp.d should not be deleted or moved by WebDAV"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -b "$jar" -X POST -H 'Tus-Resumable: 1.0.0' -H 'Upload-Length: 3' 'http://127.0.0.1:3003/1/?tus')" '403'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -b "$jar" -u alice:AlicePass -X POST 'http://127.0.0.1:3003/1/?share')" '403'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -b "$jar" -u alice:AlicePass -X POST -d "csrf=$token" 'http://127.0.0.1:3003/1/?share')" '403'
+alice_token=$(curl -s -b "$jar" -u alice:AlicePass 'http://127.0.0.1:3003/1/?json' | grep -o '"csrfToken":"[^"]*"' | cut -d '"' -f 4)
+assert "$(curl -s -o /dev/null -w '%{http_code}' -b "$jar" -u alice:AlicePass -X POST -d "csrf=$alice_token" 'http://127.0.0.1:3003/1/?share')" '200'
+
+# command line tool without browser context
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X POST -d 'name=c.tmp.d' 'http://127.0.0.1:3003/1/?mkdir')" '302'
+[ -d "$fs"/uploaded/1/c.tmp.d ] || fail "/1/c.tmp.d should be created"
+
+jobs -p | xargs kill &> /dev/null
+cleanup
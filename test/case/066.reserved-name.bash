@@ -0,0 +1,77 @@
+#!/bin/bash
+
+source "$root"/lib.bash
+
+cleanup() {
+	rm -rf "$fs"/uploaded/1/{*.tmp,.ghfs-*}
+}
+
+cleanup
+
+"$ghfs" -l 3003 -r "$fs"/uploaded --upload /1 --mkdir /1 --delete /1 --webdav -E '' &
+sleep 0.05 # wait server ready
+
+mkdir -p "$fs"/uploaded/1/.ghfs-tus "$fs"/uploaded/1/.ghfs-upload-dir
+echo -n 'staging' > "$fs"/uploaded/1/.ghfs-tus/staging
+
+# hidden from listing
+(curl_get_body 'http://127.0.0.1:3003/1/?json' | grep -q 'ghfs-') && fail "reserved items should be hidden"
+
+# upload
+assert "$(curl -s -o /dev/null -w '%{http_code}' -F 'file=content;filename=.ghfs-upload-a.tmp' 'http://127.0.0.1:3003/1/?upload&json')" '403'
+[ ! -e "$fs"/uploaded/1/.ghfs-upload-a.tmp ] || fail "/1/.ghfs-upload-a.tmp should not be uploaded"
+curl_upload_content 'http://127.0.0.1:3003/1/.ghfs-tus/?upload' file content a.tmp > /dev/null
+[ ! -e "$fs"/uploaded/1/.ghfs-tus/a.tmp ] || fail "/1/.ghfs-tus/a.tmp should not be uploaded"
+
+# put
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X PUT --data-binary 'content' 'http://127.0.0.1:3003/1/.ghfs-upload-b.tmp')" '403'
+[ ! -e "$fs"/uploaded/1/.ghfs-upload-b.tmp ] || fail "/1/.ghfs-upload-b.tmp should not be put"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X PUT --data-binary 'content' 'http://127.0.0.1:3003/1/.ghfs-tus/staging')" '403'
+assert "$(cat "$fs"/uploaded/1/.ghfs-tus/staging)" 'staging'
+
+# tus
+metadata="filename $(echo -n '.ghfs-tus/c.tmp' | base64)"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X POST -H 'Tus-Resumable: 1.0.0' -H 'Upload-Length: 7' -H "Upload-Metadata: $metadata" 'http://127.0.0.1:3003/1/?tus')" '403'
+
+# mkdir
+curl_post_status -d 'name=.ghfs-upload-mkdir' 'http://127.0.0.1:3003/1/?mkdir' > /dev/null
+[ ! -e "$fs"/uploaded/1/.ghfs-upload-mkdir ] || fail "/1/.ghfs-upload-mkdir should not be created"
+curl_post_status -d 'name=.ghfs-tus/sub' 'http://127.0.0.1:3003/1/?mkdir' > /dev/null
+[ ! -e "$fs"/uploaded/1/.ghfs-tus/sub ] || fail "/1/.ghfs-tus/sub should not be created"
+
+# delete
+curl_post_status -d 'name=.ghfs-tus' 'http://127.0.0.1:3003/1/?delete' > /dev/null
+[ -e "$fs"/uploaded/1/.ghfs-tus/staging ] || fail "/1/.ghfs-tus should not be deleted"
+
+# move
+echo -n 'content' > "$fs"/uploaded/1/a.tmp
+mkdir -p "$fs"/uploaded/1/dir.tmp
+curl_post_status -d 'name=a.tmp&to=.ghfs-upload-a.tmp' 'http://127.0.0.1:3003/1/?move' > /dev/null
+[ -e "$fs"/uploaded/1/a.tmp ] || fail "/1/a.tmp should not be moved"
+curl_post_status -d 'name=a.tmp&to=.ghfs-tus/a.tmp' 'http://127.0.0.1:3003/1/?move' > /dev/null
+[ -e "$fs"/uploaded/1/a.tmp ] || fail "/1/a.tmp should not be moved"
+curl_post_status -d 'name=dir.tmp&to=.ghfs-upload-dir2' 'http://127.0.0.1:3003/1/?move' > /dev/null
+[ -e "$fs"/uploaded/1/dir.tmp ] || fail "/1/dir.tmp should not be moved"
+curl_post_status -d 'name=.ghfs-tus&to=staging.tmp' 'http://127.0.0.1:3003/1/?move' > /dev/null
+[ -e "$fs"/uploaded/1/.ghfs-tus/staging ] || fail "/1/.ghfs-tus should not be moved"
+
+# copy
+curl_post_status -d 'name=a.tmp&to=.ghfs-tus' 'http://127.0.0.1:3003/1/?copy' > /dev/null
+[ ! -e "$fs"/uploaded/1/.ghfs-tus/a.tmp ] || fail "/1/a.tmp should not be copied"
+
+# webdav
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X MKCOL 'http://127.0.0.1:3003/1/.ghfs-upload-mkcol')" '403'
+[ ! -e "$fs"/uploaded/1/.ghfs-upload-mkcol ] || fail "/1/.ghfs-upload-mkcol should not be created"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X COPY -H 'Destination: /1/.ghfs-upload-c.tmp' 'http://127.0.0.1:3003/1/a.tmp')" '403'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X MOVE -H 'Destination: /1/.ghfs-tus/a.tmp' 'http://127.0.0.1:3003/1/a.tmp')" '403'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X MOVE -H 'Destination: /1/.ghfs-upload-dir2' 'http://127.0.0.1:3003/1/dir.tmp')" '403'
+[ -e "$fs"/uploaded/1/a.tmp ] || fail "/1/a.tmp should not be moved by WebDAV"
+[ -e "$fs"/uploaded/1/dir.tmp ] || fail "/1/dir.tmp should not be moved by WebDAV"
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X DELETE 'http://127.0.0.1:3003/1/.ghfs-tus')" '403'
+[ -e "$fs"/uploaded/1/.ghfs-tus/staging ] || fail "/1/.ghfs-tus should not be deleted by WebDAV"
+lockbody='<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>'
+assert "$(curl -s -o /dev/null -w '%{http_code}' -X LOCK --data-binary "$lockbody" 'http://127.0.0.1:3003/1/.ghfs-upload-lock.tmp')" '403'
+[ ! -e "$fs"/uploaded/1/.ghfs-upload-lock.tmp ] || fail "/1/.ghfs-upload-lock.tmp should not be created by WebDAV"
+
+cleanup
+jobs -p | xargs kill &> /dev/null